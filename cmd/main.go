@@ -2,24 +2,276 @@ package main
 
 //https://github.com/ramkmr4587/url-shortener.git
 import (
-	"log"
-	"net/http"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 
+	"url-shortener/internal/auth"
+	"url-shortener/internal/config"
 	"url-shortener/internal/handler"
+	"url-shortener/internal/health"
+	"url-shortener/internal/logging"
+	"url-shortener/internal/ratelimit"
+	"url-shortener/internal/server"
 	"url-shortener/internal/service"
 	"url-shortener/internal/storage"
+	"url-shortener/internal/tenant"
 
 	restful "github.com/emicklei/go-restful/v3"
 )
 
+const (
+	// keysFileName is the API key file kept in the data directory.
+	keysFileName = "keys.json"
+	// tenantsDirName holds one data directory per tenant inside the data
+	// directory.
+	tenantsDirName = "tenants"
+)
+
 func main() {
-	store := storage.NewStore()
-	svc := service.NewURLService(store)
-	api := handler.NewHandler(svc)
+	cfg, printConfig, err := config.Load(os.Args[1:], os.LookupEnv, os.Stderr)
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		return
+	case err != nil:
+		slog.Error("invalid configuration", "err", err)
+		os.Exit(2)
+	case printConfig:
+		out, err := cfg.YAML()
+		if err != nil {
+			slog.Error("printing configuration", "err", err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(out)
+		return
+	}
+
+	if err := run(cfg); err != nil {
+		slog.Error("exiting", "err", err)
+		os.Exit(1)
+	}
+}
+
+// run starts the service and blocks until it has shut down after SIGINT or
+// SIGTERM. The probes are served while the stores are opened, so a long log
+// replay shows as not ready rather than dead. Stores and analytics are
+// flushed before it returns.
+func run(cfg config.Config) error {
+	logger, err := logging.New(os.Stderr, cfg.Log.Level, cfg.Log.Format)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	checks := health.NewRegistry()
+	started := checks.Pending("startup", "opening stores")
+	opts := cfg.ServerOptions()
+	opts.Draining = checks.Drain
+	boot := restful.NewContainer()
+	boot.Add(handler.HealthService(checks))
+	srv := server.New(opts, boot, logger)
+
+	serveCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Run(serveCtx) }()
+
+	container, closeStores, err := open(cfg, logger, checks)
+	if err != nil {
+		cancel()
+		<-errc
+		return err
+	}
+	defer closeStores()
+	srv.SetHandler(container)
+	started()
+	return <-errc
+}
+
+// open loads the tenants, keys and stores and returns the API. The returned
+// function closes every store.
+func open(cfg config.Config, logger *slog.Logger, checks *health.Registry) (*restful.Container, func(), error) {
+	var closers []func()
+	closeAll := func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+	}
+	fail := func(err error) (*restful.Container, func(), error) {
+		closeAll()
+		return nil, nil, err
+	}
+
+	var tenants []tenant.Tenant
+	var err error
+	if cfg.Tenants != "" {
+		if tenants, err = tenant.Load(cfg.Tenants); err != nil {
+			return fail(err)
+		}
+	}
+
+	dataDir := cfg.Storage.DataDir
+	keys := auth.NewKeyring()
+	if dataDir != "" {
+		if err := os.MkdirAll(dataDir, 0o755); err != nil {
+			return fail(err)
+		}
+		if keys, err = auth.OpenKeyring(filepath.Join(dataDir, keysFileName)); err != nil {
+			return fail(err)
+		}
+	}
+	if !keys.HasAdmin() {
+		if err := bootstrapAdmin(keys, cfg.AdminKeyFile()); err != nil {
+			return fail(err)
+		}
+	}
+
+	svc, closeDefault, err := openNamespace(dataDir, 0, cfg, checks, "storage")
+	if err != nil {
+		return fail(err)
+	}
+	closers = append(closers, closeDefault)
+	api := handler.NewHandler(svc, keys)
+	api.BaseURL = cfg.Server.BaseURL
+	api.Logger = logger
+	api.Health = checks
+	api.TopDomainsLimit = cfg.Analytics.TopDomains
+	api.MaxBatchSize = cfg.Links.MaxBatchSize
+	serviceMetrics := service.NewMetrics(api.Registry)
+	serviceMetrics.Add("", svc)
+	for _, t := range tenants {
+		var dir string
+		if dataDir != "" {
+			dir = filepath.Join(dataDir, tenantsDirName, t.ID)
+		}
+		tenantSvc, closeTenant, err := openNamespace(dir, t.MaxLinks, cfg, checks, "tenant."+t.ID+".storage")
+		if err != nil {
+			return fail(err)
+		}
+		closers = append(closers, closeTenant)
+		api.Tenants.Add(t, tenantSvc)
+		serviceMetrics.Add(t.ID, tenantSvc)
+	}
+
+	if api.TrustedProxies, err = ratelimit.ParseTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		return fail(err)
+	}
+	api.RateLimits.Shorten = newLimiter(cfg.RateLimit.ShortenRate, cfg.RateLimit.ShortenBurst)
+	api.RateLimits.Redirect = newLimiter(cfg.RateLimit.RedirectRate, cfg.RateLimit.RedirectBurst)
 
 	container := restful.NewContainer()
 	api.Register(container)
+	return container, closeAll, nil
+}
+
+// openNamespace opens the store, janitor and service for one namespace,
+// keeping links in dir or in memory when dir is empty. maxLinks caps the
+// links it may hold; zero means no cap. A durable store's health checks are
+// added to checks under prefix. The returned function flushes pending
+// analytics into the store, syncs it to disk and releases everything.
+func openNamespace(dir string, maxLinks int, cfg config.Config, checks *health.Registry, prefix string) (*service.URLService, func(), error) {
+	var store storage.Repository = storage.NewStoreWithRetention(cfg.Storage.ExpiredRetention)
+	var fileStore *storage.FileStore
+	if dir != "" {
+		var err error
+		fileStore, err = storage.OpenFileStore(cfg.FileOptions(dir))
+		if err != nil {
+			return nil, nil, err
+		}
+		store = fileStore
+		slog.Info("loaded links", "links", fileStore.Len(), "dir", dir)
+	}
+
+	codesConfig := cfg.GeneratorConfig()
+	if codesConfig.Kind == service.GeneratorCounter {
+		codesConfig.CounterStart = service.CounterStart(store.List())
+	}
+	codes, err := service.NewCodeGenerator(codesConfig)
+	if err != nil {
+		if fileStore != nil {
+			fileStore.Close()
+		}
+		return nil, nil, err
+	}
+
+	if fileStore != nil {
+		checks.Add(prefix, fileStore)
+	}
+	janitor := storage.StartJanitor(store, cfg.Storage.ReapInterval, storage.DefaultReapBatch)
+	opts := append(cfg.ServiceOptions(), service.WithCodeGenerator(codes), service.WithMaxLinks(maxLinks))
+	svc := service.NewURLService(store, opts...)
+	return svc, func() {
+		svc.Close()
+		janitor.Stop()
+		if fileStore != nil {
+			if err := fileStore.Close(); err != nil {
+				slog.Error("closing store", "dir", dir, "err", err)
+			}
+		}
+	}, nil
+}
+
+// bootstrapAdmin creates the first admin key from the secret in path. An
+// operator can provision the file beforehand, for example from a secret
+// store; otherwise a new secret is written there, readable by the owner
+// only. The secret never goes to a stream that is logged, since logs are
+// shipped and kept elsewhere.
+func bootstrapAdmin(keys *auth.Keyring, path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return createAdmin(keys, path)
+	}
+	if err != nil {
+		return err
+	}
+	key, err := keys.Import("bootstrap", auth.RoleAdmin, "", strings.TrimSpace(string(data)))
+	if err != nil {
+		return fmt.Errorf("admin key file %s: %w", path, err)
+	}
+	slog.Info("created admin API key from file", "id", key.ID, "file", path)
+	return nil
+}
+
+// createAdmin writes a new secret to path and only then adds it, so a
+// failed write never leaves an admin key nobody knows.
+func createAdmin(keys *auth.Keyring, path string) error {
+	secret, err := auth.NewSecret()
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(secret + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	key, err := keys.Import("bootstrap", auth.RoleAdmin, "", secret)
+	if err != nil {
+		return err
+	}
+	slog.Warn("created admin API key; move its secret somewhere safe and delete the file", "id", key.ID, "file", path)
+	return nil
+}
 
-	log.Println("Server running on :8080")
-	log.Fatal(http.ListenAndServe(":8080", container))
+// newLimiter returns a per-client limiter, or nil when rate disables it.
+func newLimiter(rate float64, burst int) *ratelimit.Limiter {
+	if rate <= 0 {
+		return nil
+	}
+	return ratelimit.New(ratelimit.Limit{Rate: rate, Burst: max(burst, 1)})
 }
@@ -5,10 +5,12 @@ go 1.24.2
 require (
 	github.com/emicklei/go-restful/v3 v3.12.2
 	github.com/stretchr/testify v1.10.0
+	golang.org/x/net v0.42.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	golang.org/x/text v0.27.0 // indirect
 )
@@ -0,0 +1,342 @@
+// Package auth issues and checks API keys. Only the SHA-256 digest of a key
+// is kept, in memory and on disk, so the key file cannot be used to call the
+// API; the key itself is shown once, when it is created.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Role decides what a key may do.
+type Role string
+
+const (
+	// RoleUser may create links and manage the links it owns.
+	RoleUser Role = "user"
+	// RoleAdmin may manage every link and read domain statistics. Admin
+	// keys not confined to a tenant also read instance metrics and manage
+	// keys.
+	RoleAdmin Role = "admin"
+	// RoleMetrics may only scrape instance metrics, so a monitoring system
+	// needs no key that can touch links or keys. It is never confined to a
+	// tenant.
+	RoleMetrics Role = "metrics"
+)
+
+// Valid reports whether r is one of the defined roles.
+func (r Role) Valid() bool {
+	return r == RoleUser || r == RoleAdmin || r == RoleMetrics
+}
+
+// keyPrefix marks strings as API keys so they are easy to spot in logs and
+// by secret scanners.
+const keyPrefix = "usk_"
+
+// minSecretLength is the fewest characters Import accepts after keyPrefix,
+// a little under the 43 that NewSecret produces.
+const minSecretLength = 32
+
+var (
+	// ErrKeyNotFound is returned when revoking an unknown key.
+	ErrKeyNotFound = errors.New("auth: api key not found")
+	// ErrInvalidRole is returned when creating a key with an unknown role.
+	ErrInvalidRole = errors.New("auth: role must be user, admin or metrics")
+	// ErrTenantMetrics is returned when creating a metrics key for a tenant;
+	// metrics cover the whole instance.
+	ErrTenantMetrics = errors.New("auth: metrics keys cannot belong to a tenant")
+	// ErrInvalidSecret is returned when importing a secret that does not
+	// look like one NewSecret would make.
+	ErrInvalidSecret = errors.New("auth: key must be usk_ followed by at least 32 characters")
+	// ErrDuplicateSecret is returned when importing a secret that already
+	// belongs to a key.
+	ErrDuplicateSecret = errors.New("auth: key already exists")
+)
+
+// Key describes an API key. ID is what links record as their owner.
+type Key struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Role Role   `json:"role"`
+	// Tenant confines the key to one tenant's links. Empty means the key
+	// is not tied to a tenant; admin keys without one manage the instance.
+	Tenant string `json:"tenant,omitempty"`
+	// Hash is the hex SHA-256 digest of the key.
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"created_at"`
+	// RevokedAt is set once the key stops authenticating. Revoked keys are
+	// kept so their ID is never reused for another owner.
+	RevokedAt time.Time `json:"revoked_at,omitzero"`
+}
+
+// Revoked reports whether the key has been revoked.
+func (k Key) Revoked() bool {
+	return !k.RevokedAt.IsZero()
+}
+
+// Admin reports whether the key has the admin role.
+func (k Key) Admin() bool {
+	return k.Role == RoleAdmin
+}
+
+// Metrics reports whether the key may do nothing but scrape metrics.
+func (k Key) Metrics() bool {
+	return k.Role == RoleMetrics
+}
+
+// keyFile is the on-disk layout of a Keyring.
+type keyFile struct {
+	Keys []Key `json:"keys"`
+}
+
+// Keyring holds API keys, optionally persisted to a JSON file that is
+// rewritten on every change. Keys change rarely, so there is no log.
+type Keyring struct {
+	mu     sync.RWMutex
+	path   string
+	keys   map[string]Key
+	byHash map[string]string
+	now    func() time.Time
+}
+
+// NewKeyring returns an empty keyring kept in memory only.
+func NewKeyring() *Keyring {
+	return &Keyring{
+		keys:   make(map[string]Key),
+		byHash: make(map[string]string),
+		now:    time.Now,
+	}
+}
+
+// OpenKeyring loads the keyring stored at path, starting empty if the file
+// does not exist yet. Changes are written back to path.
+func OpenKeyring(path string) (*Keyring, error) {
+	k := NewKeyring()
+	k.path = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return k, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("auth: read keys: %w", err)
+	}
+	var file keyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("auth: decode keys: %w", err)
+	}
+	for _, key := range file.Keys {
+		k.keys[key.ID] = key
+		k.byHash[key.Hash] = key.ID
+	}
+	return k, nil
+}
+
+// NewSecret returns a fresh API key secret.
+func NewSecret() (string, error) {
+	secret, err := randomString(32)
+	if err != nil {
+		return "", err
+	}
+	return keyPrefix + secret, nil
+}
+
+// Create issues a new key for tenant and returns it with its description.
+// The key is not stored and cannot be recovered later.
+func (k *Keyring) Create(name string, role Role, tenant string) (string, Key, error) {
+	secret, err := NewSecret()
+	if err != nil {
+		return "", Key{}, err
+	}
+	key, err := k.add(name, role, tenant, secret)
+	if err != nil {
+		return "", Key{}, err
+	}
+	return secret, key, nil
+}
+
+// Import adds a key whose secret was made elsewhere, such as a bootstrap
+// admin key an operator provisions from a secret store.
+func (k *Keyring) Import(name string, role Role, tenant, secret string) (Key, error) {
+	if !strings.HasPrefix(secret, keyPrefix) || len(secret)-len(keyPrefix) < minSecretLength {
+		return Key{}, ErrInvalidSecret
+	}
+	return k.add(name, role, tenant, secret)
+}
+
+func (k *Keyring) add(name string, role Role, tenant, secret string) (Key, error) {
+	if !role.Valid() {
+		return Key{}, ErrInvalidRole
+	}
+	if role == RoleMetrics && tenant != "" {
+		return Key{}, ErrTenantMetrics
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.byHash[hash(secret)]; ok {
+		return Key{}, ErrDuplicateSecret
+	}
+	var id string
+	var err error
+	for id == "" || k.keys[id].ID != "" {
+		if id, err = randomHex(8); err != nil {
+			return Key{}, err
+		}
+	}
+	key := Key{ID: id, Name: name, Role: role, Tenant: tenant, Hash: hash(secret), CreatedAt: k.now().UTC()}
+	k.keys[id] = key
+	k.byHash[key.Hash] = id
+	if err := k.persist(); err != nil {
+		delete(k.keys, id)
+		delete(k.byHash, key.Hash)
+		return Key{}, err
+	}
+	return key, nil
+}
+
+// Authenticate returns the live key matching secret.
+func (k *Keyring) Authenticate(secret string) (Key, bool) {
+	if !strings.HasPrefix(secret, keyPrefix) {
+		return Key{}, false
+	}
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, exists := k.keys[k.byHash[hash(secret)]]
+	if !exists || key.Revoked() {
+		return Key{}, false
+	}
+	return key, true
+}
+
+// Revoke stops the key with the given ID from authenticating. Revoking a
+// key twice is not an error.
+func (k *Keyring) Revoke(id string) (Key, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	key, exists := k.keys[id]
+	if !exists {
+		return Key{}, ErrKeyNotFound
+	}
+	if key.Revoked() {
+		return key, nil
+	}
+	key.RevokedAt = k.now().UTC()
+	k.keys[id] = key
+	if err := k.persist(); err != nil {
+		key.RevokedAt = time.Time{}
+		k.keys[id] = key
+		return Key{}, err
+	}
+	return key, nil
+}
+
+// List returns every key, revoked ones included, oldest first.
+func (k *Keyring) List() []Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	keys := make([]Key, 0, len(k.keys))
+	for _, key := range k.keys {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b Key) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return keys
+}
+
+// HasAdmin reports whether any live key administers the whole instance.
+func (k *Keyring) HasAdmin() bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	for _, key := range k.keys {
+		if key.Admin() && key.Tenant == "" && !key.Revoked() {
+			return true
+		}
+	}
+	return false
+}
+
+// persist rewrites the key file. The file is replaced atomically so a crash
+// leaves either the old or the new set of keys. Callers hold k.mu.
+func (k *Keyring) persist() error {
+	if k.path == "" {
+		return nil
+	}
+	file := keyFile{Keys: make([]Key, 0, len(k.keys))}
+	for _, key := range k.keys {
+		file.Keys = append(file.Keys, key)
+	}
+	slices.SortFunc(file.Keys, func(a, b Key) int { return strings.Compare(a.ID, b.ID) })
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := k.path + ".tmp"
+	if err := writeFileSync(tmp, data); err != nil {
+		return fmt.Errorf("auth: write keys: %w", err)
+	}
+	if err := os.Rename(tmp, k.path); err != nil {
+		return fmt.Errorf("auth: install keys: %w", err)
+	}
+	return syncDir(filepath.Dir(k.path))
+}
+
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func writeFileSync(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type KeyringTestSuite struct {
+	suite.Suite
+	Path string
+	Keys *Keyring
+}
+
+func TestKeyringTestSuite(t *testing.T) {
+	suite.Run(t, new(KeyringTestSuite))
+}
+
+func (suite *KeyringTestSuite) SetupTest() {
+	suite.Path = filepath.Join(suite.T().TempDir(), "keys.json")
+	keys, err := OpenKeyring(suite.Path)
+	require.NoError(suite.T(), err)
+	suite.Keys = keys
+}
+
+func (suite *KeyringTestSuite) TestCreateAndAuthenticate() {
+	secret, key, err := suite.Keys.Create("marketing", RoleUser, "")
+	require.NoError(suite.T(), err)
+
+	got, ok := suite.Keys.Authenticate(secret)
+
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), key, got)
+	assert.Equal(suite.T(), "marketing", got.Name)
+	assert.False(suite.T(), got.Admin())
+	_, ok = suite.Keys.Authenticate(secret + "x")
+	assert.False(suite.T(), ok)
+	_, ok = suite.Keys.Authenticate("")
+	assert.False(suite.T(), ok)
+}
+
+func (suite *KeyringTestSuite) TestInvalidRole() {
+	_, _, err := suite.Keys.Create("ops", Role("root"), "")
+
+	assert.ErrorIs(suite.T(), err, ErrInvalidRole)
+	assert.Empty(suite.T(), suite.Keys.List())
+}
+
+func (suite *KeyringTestSuite) TestMetricsRole() {
+	_, key, err := suite.Keys.Create("prometheus", RoleMetrics, "")
+	require.NoError(suite.T(), err)
+	assert.True(suite.T(), key.Metrics())
+	assert.False(suite.T(), key.Admin())
+	assert.False(suite.T(), suite.Keys.HasAdmin(), "A metrics key is no admin")
+
+	_, _, err = suite.Keys.Create("prometheus", RoleMetrics, "acme")
+	assert.ErrorIs(suite.T(), err, ErrTenantMetrics)
+}
+
+func (suite *KeyringTestSuite) TestRevoke() {
+	secret, key, err := suite.Keys.Create("marketing", RoleUser, "")
+	require.NoError(suite.T(), err)
+
+	revoked, err := suite.Keys.Revoke(key.ID)
+
+	require.NoError(suite.T(), err)
+	assert.True(suite.T(), revoked.Revoked())
+	_, ok := suite.Keys.Authenticate(secret)
+	assert.False(suite.T(), ok)
+	assert.Len(suite.T(), suite.Keys.List(), 1, "Revoked keys stay listed")
+	_, err = suite.Keys.Revoke("missing")
+	assert.ErrorIs(suite.T(), err, ErrKeyNotFound)
+}
+
+func (suite *KeyringTestSuite) TestImport() {
+	secret, err := NewSecret()
+	require.NoError(suite.T(), err)
+
+	key, err := suite.Keys.Import("bootstrap", RoleAdmin, "", secret)
+
+	require.NoError(suite.T(), err)
+	got, ok := suite.Keys.Authenticate(secret)
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), key, got)
+	_, err = suite.Keys.Import("again", RoleUser, "", secret)
+	assert.ErrorIs(suite.T(), err, ErrDuplicateSecret)
+	for _, bad := range []string{"", "hunter2", keyPrefix + "short", strings.TrimPrefix(secret, keyPrefix)} {
+		_, err = suite.Keys.Import("ops", RoleAdmin, "", bad)
+		assert.ErrorIs(suite.T(), err, ErrInvalidSecret, bad)
+	}
+	assert.Len(suite.T(), suite.Keys.List(), 1)
+}
+
+func (suite *KeyringTestSuite) TestHasAdmin() {
+	assert.False(suite.T(), suite.Keys.HasAdmin())
+	_, key, err := suite.Keys.Create("ops", RoleAdmin, "")
+	require.NoError(suite.T(), err)
+	assert.True(suite.T(), suite.Keys.HasAdmin())
+
+	_, err = suite.Keys.Revoke(key.ID)
+	require.NoError(suite.T(), err)
+
+	assert.False(suite.T(), suite.Keys.HasAdmin())
+}
+
+func (suite *KeyringTestSuite) TestPersistsOnlyHashes() {
+	secret, key, err := suite.Keys.Create("ops", RoleAdmin, "")
+	require.NoError(suite.T(), err)
+
+	data, err := os.ReadFile(suite.Path)
+	require.NoError(suite.T(), err)
+	assert.NotContains(suite.T(), string(data), strings.TrimPrefix(secret, keyPrefix))
+	assert.Contains(suite.T(), string(data), key.Hash)
+
+	reopened, err := OpenKeyring(suite.Path)
+	require.NoError(suite.T(), err)
+	got, ok := reopened.Authenticate(secret)
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), key.ID, got.ID)
+	assert.True(suite.T(), got.Admin())
+}
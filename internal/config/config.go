@@ -0,0 +1,335 @@
+// Package config holds every setting of the service. Settings are layered:
+// built-in defaults, then a YAML or JSON file, then URLSHORTENER_*
+// environment variables, then command-line flags, each overriding the one
+// before.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"url-shortener/internal/logging"
+	"url-shortener/internal/ratelimit"
+	"url-shortener/internal/server"
+	"url-shortener/internal/service"
+	"url-shortener/internal/storage"
+)
+
+// Config is the complete configuration. Field names in files are the yaml
+// tags; see Bind for the matching flags and environment variables.
+type Config struct {
+	Server    Server    `yaml:"server"`
+	Log       Log       `yaml:"log"`
+	Storage   Storage   `yaml:"storage"`
+	Auth      Auth      `yaml:"auth"`
+	Codes     Codes     `yaml:"codes"`
+	URLs      URLs      `yaml:"urls"`
+	Links     Links     `yaml:"links"`
+	Analytics Analytics `yaml:"analytics"`
+	RateLimit RateLimit `yaml:"rate_limit"`
+	// Tenants is a JSON file listing tenant namespaces; see the tenant
+	// package.
+	Tenants string `yaml:"tenants"`
+}
+
+// Server configures the HTTP server and how public URLs are built.
+type Server struct {
+	Addr              string        `yaml:"addr"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout"`
+	// DrainDelay is how long the server keeps serving after SIGTERM with
+	// /readyz failing, so load balancers stop routing to it first.
+	DrainDelay time.Duration `yaml:"drain_delay"`
+	// BaseURL is the public address short URLs are built on. Empty uses
+	// each request's Host.
+	BaseURL string `yaml:"base_url"`
+	// TrustedProxies are the IPs or CIDRs whose X-Forwarded-* headers are
+	// believed.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// Log configures logging.
+type Log struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+// Storage configures where links are kept.
+type Storage struct {
+	// DataDir holds the durable store. Empty keeps links in memory only.
+	DataDir      string        `yaml:"data_dir"`
+	Fsync        string        `yaml:"fsync"`
+	CompactAfter int           `yaml:"compact_after"`
+	ReapInterval time.Duration `yaml:"reap_interval"`
+	// ExpiredRetention is how long expired links keep answering 410 Gone,
+	// and keep their codes and quota, before they are reaped.
+	ExpiredRetention time.Duration `yaml:"expired_retention"`
+}
+
+// Auth configures API keys.
+type Auth struct {
+	// AdminKeyFile holds the secret of the bootstrap admin key, created
+	// while no admin key exists. An operator can provision the file, for
+	// example from a secret store; otherwise a new secret is written there,
+	// readable by its owner only. Empty means admin.key in the data
+	// directory, or url-shortener-admin.key in the temporary directory
+	// when links are only kept in memory.
+	AdminKeyFile string `yaml:"admin_key_file"`
+}
+
+// Codes configures short code generation.
+type Codes struct {
+	Generator string `yaml:"generator"`
+	Length    int    `yaml:"length"`
+	NodeID    int64  `yaml:"node_id"`
+}
+
+// URLs configures which destinations are accepted and how they are
+// deduplicated.
+type URLs struct {
+	AllowedSchemes   []string `yaml:"allowed_schemes"`
+	MaxLength        int      `yaml:"max_length"`
+	SortQuery        bool     `yaml:"sort_query"`
+	Fragment         string   `yaml:"fragment"`
+	StripParams      []string `yaml:"strip_params"`
+	PreserveOriginal bool     `yaml:"preserve_original"`
+}
+
+// Links configures link behaviour.
+type Links struct {
+	RedirectStatus  int           `yaml:"redirect_status"`
+	PermanentMaxAge time.Duration `yaml:"permanent_max_age"`
+	RestoreWindow   time.Duration `yaml:"restore_window"`
+	MaxBatchSize    int           `yaml:"max_batch_size"`
+}
+
+// Analytics configures click recording and the domain leaderboard.
+type Analytics struct {
+	ClickBuffer int `yaml:"click_buffer"`
+	// TopDomains is how many domains the leaderboard returns without ?limit.
+	TopDomains int `yaml:"top_domains"`
+}
+
+// RateLimit configures per-client budgets. A zero rate disables a limit.
+type RateLimit struct {
+	ShortenRate   float64 `yaml:"shorten_rate"`
+	ShortenBurst  int     `yaml:"shorten_burst"`
+	RedirectRate  float64 `yaml:"redirect_rate"`
+	RedirectBurst int     `yaml:"redirect_burst"`
+}
+
+// Default returns the built-in configuration.
+func Default() Config {
+	return Config{
+		Server: Server{
+			Addr:              server.DefaultAddr,
+			ReadHeaderTimeout: server.DefaultReadHeaderTimeout,
+			ReadTimeout:       server.DefaultReadTimeout,
+			WriteTimeout:      server.DefaultWriteTimeout,
+			IdleTimeout:       server.DefaultIdleTimeout,
+			ShutdownTimeout:   server.DefaultShutdownTimeout,
+		},
+		Log: Log{Level: "info", Format: logging.FormatText},
+		Storage: Storage{
+			Fsync:            string(storage.SyncAlways),
+			CompactAfter:     10000,
+			ReapInterval:     time.Minute,
+			ExpiredRetention: storage.DefaultExpiredRetention,
+		},
+		Codes: Codes{
+			Generator: service.GeneratorHash,
+			Length:    6,
+			NodeID:    defaultNodeID(),
+		},
+		URLs: URLs{
+			AllowedSchemes: service.DefaultAllowedSchemes,
+			MaxLength:      service.DefaultMaxURLLength,
+			Fragment:       string(service.FragmentKeep),
+		},
+		Links: Links{
+			RedirectStatus:  service.DefaultRedirectStatus,
+			PermanentMaxAge: service.DefaultPermanentMaxAge,
+			RestoreWindow:   service.DefaultRestoreWindow,
+			MaxBatchSize:    service.DefaultMaxBatchSize,
+		},
+		Analytics: Analytics{
+			ClickBuffer: service.DefaultClickBuffer,
+			TopDomains:  service.DefaultTopDomains,
+		},
+		RateLimit: RateLimit{
+			ShortenRate:   5,
+			ShortenBurst:  20,
+			RedirectRate:  50,
+			RedirectBurst: 100,
+		},
+	}
+}
+
+// defaultNodeID derives a snowflake node id from the hostname. Two pods can
+// still hash to the same id, so set the node id explicitly (for example from
+// a StatefulSet ordinal) when replicas must never collide.
+func defaultNodeID() int64 {
+	host, err := os.Hostname()
+	if err != nil {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(host))
+	return int64(h.Sum32() % (service.MaxSnowflakeNode + 1))
+}
+
+// Validate reports every invalid setting at once.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("config: "+format, args...))
+		}
+	}
+
+	check(c.Server.Addr != "", "server.addr must be set")
+	for _, t := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"read_header_timeout", c.Server.ReadHeaderTimeout},
+		{"read_timeout", c.Server.ReadTimeout},
+		{"write_timeout", c.Server.WriteTimeout},
+		{"idle_timeout", c.Server.IdleTimeout},
+		{"shutdown_timeout", c.Server.ShutdownTimeout},
+		{"drain_delay", c.Server.DrainDelay},
+	} {
+		check(t.d >= 0, "server.%s must not be negative", t.name)
+	}
+	if c.Server.BaseURL != "" {
+		u, err := url.Parse(c.Server.BaseURL)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"server.base_url %q must be an absolute http or https URL", c.Server.BaseURL)
+	}
+	if _, err := ratelimit.ParseTrustedProxies(c.Server.TrustedProxies); err != nil {
+		errs = append(errs, fmt.Errorf("config: server.trusted_proxies: %w", err))
+	}
+
+	if _, err := logging.New(io.Discard, c.Log.Level, c.Log.Format); err != nil {
+		errs = append(errs, fmt.Errorf("config: %w", err))
+	}
+
+	if _, err := storage.ParseSyncPolicy(c.Storage.Fsync); err != nil {
+		errs = append(errs, fmt.Errorf("config: storage.fsync: %w", err))
+	}
+	check(c.Storage.CompactAfter > 0, "storage.compact_after must be positive")
+	check(c.Storage.ReapInterval > 0, "storage.reap_interval must be positive")
+	check(c.Storage.ExpiredRetention > 0, "storage.expired_retention must be positive")
+
+	switch c.Codes.Generator {
+	case service.GeneratorHash, service.GeneratorCounter, service.GeneratorRandom, service.GeneratorSnowflake:
+	default:
+		check(false, "codes.generator %q must be hash, counter, random or snowflake", c.Codes.Generator)
+	}
+	check(c.Codes.Length > 0, "codes.length must be positive")
+	check(c.Codes.NodeID >= 0 && c.Codes.NodeID <= service.MaxSnowflakeNode, "codes.node_id must be 0 to %d", service.MaxSnowflakeNode)
+
+	check(len(c.URLs.AllowedSchemes) > 0, "urls.allowed_schemes must not be empty")
+	check(c.URLs.MaxLength > 0, "urls.max_length must be positive")
+	check(c.URLs.Fragment == string(service.FragmentKeep) || c.URLs.Fragment == string(service.FragmentStrip),
+		"urls.fragment %q must be keep or strip", c.URLs.Fragment)
+
+	check(service.IsRedirectStatus(c.Links.RedirectStatus), "links.redirect_status %d must be 301, 302, 307 or 308", c.Links.RedirectStatus)
+	check(c.Links.PermanentMaxAge >= 0, "links.permanent_max_age must not be negative")
+	check(c.Links.RestoreWindow >= 0, "links.restore_window must not be negative")
+	check(c.Links.MaxBatchSize > 0, "links.max_batch_size must be positive")
+
+	check(c.Analytics.ClickBuffer >= 0, "analytics.click_buffer must not be negative")
+	check(c.Analytics.TopDomains > 0, "analytics.top_domains must be positive")
+
+	check(c.RateLimit.ShortenRate >= 0 && c.RateLimit.RedirectRate >= 0, "rate_limit rates must not be negative")
+	check(c.RateLimit.ShortenBurst >= 0 && c.RateLimit.RedirectBurst >= 0, "rate_limit bursts must not be negative")
+
+	return errors.Join(errs...)
+}
+
+// FileOptions returns the options of a durable store kept in dir.
+func (c Config) FileOptions(dir string) storage.FileOptions {
+	policy, _ := storage.ParseSyncPolicy(c.Storage.Fsync)
+	return storage.FileOptions{
+		Dir:              dir,
+		Sync:             policy,
+		CompactAfter:     c.Storage.CompactAfter,
+		ExpiredRetention: c.Storage.ExpiredRetention,
+	}
+}
+
+// AdminKeyFile returns the file holding the bootstrap admin secret.
+func (c Config) AdminKeyFile() string {
+	switch {
+	case c.Auth.AdminKeyFile != "":
+		return c.Auth.AdminKeyFile
+	case c.Storage.DataDir != "":
+		return filepath.Join(c.Storage.DataDir, "admin.key")
+	default:
+		return filepath.Join(os.TempDir(), "url-shortener-admin.key")
+	}
+}
+
+// GeneratorConfig returns the short code generator settings. The caller
+// sets CounterStart.
+func (c Config) GeneratorConfig() service.GeneratorConfig {
+	return service.GeneratorConfig{
+		Kind:   c.Codes.Generator,
+		Length: c.Codes.Length,
+		NodeID: c.Codes.NodeID,
+	}
+}
+
+// ServiceOptions returns the options every namespace's service shares.
+func (c Config) ServiceOptions() []service.Option {
+	return []service.Option{
+		service.WithAllowedSchemes(c.URLs.AllowedSchemes),
+		service.WithMaxURLLength(c.URLs.MaxLength),
+		service.WithCanonicalOptions(service.CanonicalOptions{
+			SortQuery:        c.URLs.SortQuery,
+			Fragment:         service.FragmentPolicy(c.URLs.Fragment),
+			StripParams:      c.URLs.StripParams,
+			PreserveOriginal: c.URLs.PreserveOriginal,
+		}),
+		service.WithRedirectStatus(c.Links.RedirectStatus),
+		service.WithPermanentMaxAge(c.Links.PermanentMaxAge),
+		service.WithRestoreWindow(c.Links.RestoreWindow),
+		service.WithClickBuffer(c.Analytics.ClickBuffer),
+		service.WithMaxBatchSize(c.Links.MaxBatchSize),
+	}
+}
+
+// ServerOptions returns the HTTP server settings.
+func (c Config) ServerOptions() server.Options {
+	return server.Options{
+		Addr:              c.Server.Addr,
+		ReadHeaderTimeout: c.Server.ReadHeaderTimeout,
+		ReadTimeout:       c.Server.ReadTimeout,
+		WriteTimeout:      c.Server.WriteTimeout,
+		IdleTimeout:       c.Server.IdleTimeout,
+		ShutdownTimeout:   c.Server.ShutdownTimeout,
+		DrainDelay:        c.Server.DrainDelay,
+	}
+}
+
+// splitList parses a comma-separated value, ignoring empty entries.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
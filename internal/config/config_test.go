@@ -0,0 +1,180 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type ConfigTestSuite struct {
+	suite.Suite
+	Env map[string]string
+}
+
+func TestConfigTestSuite(t *testing.T) {
+	suite.Run(t, new(ConfigTestSuite))
+}
+
+func (suite *ConfigTestSuite) SetupTest() {
+	suite.Env = map[string]string{}
+}
+
+func (suite *ConfigTestSuite) lookupEnv(name string) (string, bool) {
+	value, ok := suite.Env[name]
+	return value, ok
+}
+
+func (suite *ConfigTestSuite) load(args ...string) (Config, bool, error) {
+	return Load(args, suite.lookupEnv, io.Discard)
+}
+
+func (suite *ConfigTestSuite) writeFile(name, content string) string {
+	path := filepath.Join(suite.T().TempDir(), name)
+	require.NoError(suite.T(), os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func (suite *ConfigTestSuite) TestDefaults() {
+	cfg, print, err := suite.load()
+	require.NoError(suite.T(), err)
+	assert.False(suite.T(), print)
+	assert.Equal(suite.T(), Default(), cfg)
+	assert.NoError(suite.T(), Default().Validate())
+}
+
+func (suite *ConfigTestSuite) TestYAMLFile() {
+	path := suite.writeFile("config.yaml", `
+server:
+  addr: ":9000"
+  shutdown_timeout: 45s
+storage:
+  expired_retention: 168h
+codes:
+  length: 8
+analytics:
+  top_domains: 5
+urls:
+  strip_params: [utm_*, fbclid]
+`)
+	cfg, _, err := suite.load("-config", path)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), ":9000", cfg.Server.Addr)
+	assert.Equal(suite.T(), 45*time.Second, cfg.Server.ShutdownTimeout)
+	assert.Equal(suite.T(), 7*24*time.Hour, cfg.Storage.ExpiredRetention)
+	assert.Equal(suite.T(), 8, cfg.Codes.Length)
+	assert.Equal(suite.T(), 5, cfg.Analytics.TopDomains)
+	assert.Equal(suite.T(), []string{"utm_*", "fbclid"}, cfg.URLs.StripParams)
+	assert.Equal(suite.T(), Default().Server.ReadTimeout, cfg.Server.ReadTimeout, "Settings the file leaves out keep their defaults")
+}
+
+func (suite *ConfigTestSuite) TestJSONFile() {
+	path := suite.writeFile("config.json", `{"log": {"level": "debug", "format": "json"}, "links": {"redirect_status": 302}}`)
+	cfg, _, err := suite.load("-config", path)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "debug", cfg.Log.Level)
+	assert.Equal(suite.T(), "json", cfg.Log.Format)
+	assert.Equal(suite.T(), 302, cfg.Links.RedirectStatus)
+}
+
+func (suite *ConfigTestSuite) TestEmptyFile() {
+	cfg, _, err := suite.load("-config", suite.writeFile("config.yaml", ""))
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), Default(), cfg)
+}
+
+func (suite *ConfigTestSuite) TestUnknownKeyRejected() {
+	_, _, err := suite.load("-config", suite.writeFile("config.yaml", "codes:\n  lenght: 8\n"))
+	assert.ErrorContains(suite.T(), err, "lenght")
+}
+
+func (suite *ConfigTestSuite) TestMissingFile() {
+	_, _, err := suite.load("-config", filepath.Join(suite.T().TempDir(), "missing.yaml"))
+	assert.ErrorIs(suite.T(), err, os.ErrNotExist)
+}
+
+func (suite *ConfigTestSuite) TestPrecedence() {
+	path := suite.writeFile("config.yaml", "codes:\n  length: 7\nserver:\n  addr: \":9000\"\nrate_limit:\n  shorten_rate: 1\n")
+	suite.Env["URLSHORTENER_CODE_LENGTH"] = "9"
+	suite.Env["URLSHORTENER_SHORTEN_RATE"] = "2"
+
+	cfg, _, err := suite.load("-config", path, "-shorten-rate", "3")
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), ":9000", cfg.Server.Addr, "The file overrides the defaults")
+	assert.Equal(suite.T(), 9, cfg.Codes.Length, "The environment overrides the file")
+	assert.Equal(suite.T(), 3.0, cfg.RateLimit.ShortenRate, "Flags override the environment")
+}
+
+func (suite *ConfigTestSuite) TestConfigFileFromEnv() {
+	suite.Env["URLSHORTENER_CONFIG"] = suite.writeFile("config.yaml", "codes:\n  length: 7\n")
+	cfg, _, err := suite.load()
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 7, cfg.Codes.Length)
+}
+
+func (suite *ConfigTestSuite) TestListFromEnv() {
+	suite.Env["URLSHORTENER_TRUSTED_PROXIES"] = "10.0.0.0/8, 127.0.0.1"
+	cfg, _, err := suite.load()
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{"10.0.0.0/8", "127.0.0.1"}, cfg.Server.TrustedProxies)
+}
+
+func (suite *ConfigTestSuite) TestInvalidEnv() {
+	suite.Env["URLSHORTENER_CODE_LENGTH"] = "eight"
+	_, _, err := suite.load()
+	assert.ErrorContains(suite.T(), err, "URLSHORTENER_CODE_LENGTH")
+}
+
+func (suite *ConfigTestSuite) TestValidateReportsEverything() {
+	_, _, err := suite.load("-redirect-status", "200", "-fragment", "drop", "-code-length", "0", "-base-url", "sho.rt")
+	require.Error(suite.T(), err)
+	for _, want := range []string{"links.redirect_status", "urls.fragment", "codes.length", "server.base_url"} {
+		assert.ErrorContains(suite.T(), err, want)
+	}
+}
+
+func (suite *ConfigTestSuite) TestHelp() {
+	_, _, err := suite.load("-h")
+	assert.True(suite.T(), errors.Is(err, flag.ErrHelp))
+}
+
+func (suite *ConfigTestSuite) TestPrintConfigRoundTrips() {
+	suite.Env["URLSHORTENER_ALLOWED_SCHEMES"] = "https"
+	cfg, print, err := suite.load("-print-config", "-idle-timeout", "90s", "-log-format", "json")
+	require.NoError(suite.T(), err)
+	assert.True(suite.T(), print)
+
+	out, err := cfg.YAML()
+	require.NoError(suite.T(), err)
+	reloaded, _, err := Load([]string{"-config", suite.writeFile("printed.yaml", string(out))}, func(string) (string, bool) { return "", false }, io.Discard)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "https", reloaded.URLs.AllowedSchemes[0])
+	assert.Equal(suite.T(), 90*time.Second, reloaded.Server.IdleTimeout)
+	again, err := reloaded.YAML()
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), string(out), string(again))
+}
+
+func (suite *ConfigTestSuite) TestAdminKeyFile() {
+	cfg := Default()
+	assert.Equal(suite.T(), filepath.Join(os.TempDir(), "url-shortener-admin.key"), cfg.AdminKeyFile())
+
+	cfg.Storage.DataDir = "/var/lib/url-shortener"
+	assert.Equal(suite.T(), "/var/lib/url-shortener/admin.key", cfg.AdminKeyFile())
+
+	suite.Env["URLSHORTENER_ADMIN_KEY_FILE"] = "/run/secrets/admin.key"
+	cfg, _, err := suite.load("-data-dir", "/var/lib/url-shortener")
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "/run/secrets/admin.key", cfg.AdminKeyFile())
+}
+
+func (suite *ConfigTestSuite) TestEnvName() {
+	assert.Equal(suite.T(), "URLSHORTENER_READ_HEADER_TIMEOUT", EnvName("read-header-timeout"))
+}
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts the environment variable of every flag: -shorten-rate
+// is read from URLSHORTENER_SHORTEN_RATE.
+const EnvPrefix = "URLSHORTENER_"
+
+// Bind registers a flag for every setting on fs, writing into c. The flags
+// default to c's current values.
+func Bind(fs *flag.FlagSet, c *Config) {
+	fs.StringVar(&c.Server.Addr, "addr", c.Server.Addr, "address to listen on")
+	fs.DurationVar(&c.Server.ReadHeaderTimeout, "read-header-timeout", c.Server.ReadHeaderTimeout, "time allowed to read request headers")
+	fs.DurationVar(&c.Server.ReadTimeout, "read-timeout", c.Server.ReadTimeout, "time allowed to read a whole request")
+	fs.DurationVar(&c.Server.WriteTimeout, "write-timeout", c.Server.WriteTimeout, "time allowed to write a response")
+	fs.DurationVar(&c.Server.IdleTimeout, "idle-timeout", c.Server.IdleTimeout, "how long idle keep-alive connections are kept open")
+	fs.DurationVar(&c.Server.ShutdownTimeout, "shutdown-timeout", c.Server.ShutdownTimeout, "how long in-flight requests may run after SIGTERM before they are cut off")
+	fs.DurationVar(&c.Server.DrainDelay, "drain-delay", c.Server.DrainDelay, "how long to keep serving with /readyz failing after SIGTERM before closing the listener")
+	fs.StringVar(&c.Server.BaseURL, "base-url", c.Server.BaseURL, "public address short URLs are built on, such as https://sho.rt; empty uses the request's Host")
+	fs.Var((*list)(&c.Server.TrustedProxies), "trusted-proxies", "comma-separated proxy IPs or CIDRs whose X-Forwarded-For, -Host and -Proto are believed")
+
+	fs.StringVar(&c.Log.Level, "log-level", c.Log.Level, "lowest level logged: debug, info, warn or error")
+	fs.StringVar(&c.Log.Format, "log-format", c.Log.Format, "log output: text or json")
+
+	fs.StringVar(&c.Storage.DataDir, "data-dir", c.Storage.DataDir, "directory for the durable store; empty keeps links in memory only")
+	fs.StringVar(&c.Storage.Fsync, "fsync", c.Storage.Fsync, "wal fsync policy: always, interval or never")
+	fs.IntVar(&c.Storage.CompactAfter, "compact-after", c.Storage.CompactAfter, "wal records written before compacting into a snapshot")
+	fs.DurationVar(&c.Storage.ReapInterval, "reap-interval", c.Storage.ReapInterval, "how often expired links are removed from the store")
+	fs.DurationVar(&c.Storage.ExpiredRetention, "expired-retention", c.Storage.ExpiredRetention, "how long expired links answer 410 Gone and keep their codes before they are removed")
+
+	fs.StringVar(&c.Auth.AdminKeyFile, "admin-key-file", c.Auth.AdminKeyFile, "file holding the bootstrap admin key secret, read if present and written otherwise; empty uses admin.key in -data-dir")
+
+	fs.StringVar(&c.Codes.Generator, "codegen", c.Codes.Generator, "short code generator: hash, counter, random or snowflake")
+	fs.IntVar(&c.Codes.Length, "code-length", c.Codes.Length, "length of hash and random short codes")
+	fs.Int64Var(&c.Codes.NodeID, "node-id", c.Codes.NodeID, "replica id for snowflake codes (0-1023)")
+
+	fs.Var((*list)(&c.URLs.AllowedSchemes), "allowed-schemes", "comma-separated destination URL schemes")
+	fs.IntVar(&c.URLs.MaxLength, "max-url-length", c.URLs.MaxLength, "longest destination URL accepted")
+	fs.BoolVar(&c.URLs.SortQuery, "sort-query", c.URLs.SortQuery, "sort query parameters when deduplicating URLs")
+	fs.StringVar(&c.URLs.Fragment, "fragment", c.URLs.Fragment, "fragment policy when deduplicating URLs: keep or strip")
+	fs.Var((*list)(&c.URLs.StripParams), "strip-params", "comma-separated query parameters to drop when deduplicating (e.g. utm_*,fbclid)")
+	fs.BoolVar(&c.URLs.PreserveOriginal, "preserve-original", c.URLs.PreserveOriginal, "redirect to the URL as submitted rather than its canonical form")
+
+	fs.IntVar(&c.Links.RedirectStatus, "redirect-status", c.Links.RedirectStatus, "default redirect status for links that do not pick one: 301, 302, 307 or 308")
+	fs.DurationVar(&c.Links.PermanentMaxAge, "permanent-max-age", c.Links.PermanentMaxAge, "how long clients may cache 301 and 308 redirects")
+	fs.DurationVar(&c.Links.RestoreWindow, "restore-window", c.Links.RestoreWindow, "how long a deleted link can be restored before it is purged")
+	fs.IntVar(&c.Links.MaxBatchSize, "max-batch-size", c.Links.MaxBatchSize, "most URLs accepted by one POST /shorten/batch request")
+
+	fs.IntVar(&c.Analytics.ClickBuffer, "click-buffer", c.Analytics.ClickBuffer, "clicks queued for analytics before new ones are dropped")
+	fs.IntVar(&c.Analytics.TopDomains, "top-domains", c.Analytics.TopDomains, "domains the leaderboard returns when the request sets no limit")
+
+	fs.Float64Var(&c.RateLimit.ShortenRate, "shorten-rate", c.RateLimit.ShortenRate, "shorten requests per second allowed per client; 0 disables the limit")
+	fs.IntVar(&c.RateLimit.ShortenBurst, "shorten-burst", c.RateLimit.ShortenBurst, "shorten requests a client may make at once before -shorten-rate applies")
+	fs.Float64Var(&c.RateLimit.RedirectRate, "redirect-rate", c.RateLimit.RedirectRate, "redirects per second allowed per client; 0 disables the limit")
+	fs.IntVar(&c.RateLimit.RedirectBurst, "redirect-burst", c.RateLimit.RedirectBurst, "redirects a client may make at once before -redirect-rate applies")
+
+	fs.StringVar(&c.Tenants, "tenants", c.Tenants, "JSON file listing tenant namespaces; see internal/tenant")
+}
+
+// Load builds the configuration from, in rising precedence, the defaults,
+// the file named by -config or URLSHORTENER_CONFIG, the environment and
+// args, the command line without the program name. It reports whether
+// -print-config was given, and returns flag.ErrHelp after printing usage
+// for -h. The result is validated.
+func Load(args []string, lookupEnv func(string) (string, bool), usage io.Writer) (Config, bool, error) {
+	// The first pass only finds the file; its settings are discarded.
+	scratch := Default()
+	fs, path, print := newFlagSet(&scratch, usage)
+	if err := applyEnv(fs, lookupEnv); err != nil {
+		return Config{}, false, err
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, false, err
+	}
+
+	cfg := Default()
+	if *path != "" {
+		if err := cfg.readFile(*path); err != nil {
+			return Config{}, false, err
+		}
+	}
+	fs, _, _ = newFlagSet(&cfg, io.Discard)
+	if err := applyEnv(fs, lookupEnv); err != nil {
+		return Config{}, false, err
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, false, err
+	}
+	return cfg, *print, cfg.Validate()
+}
+
+// newFlagSet returns the flags Load understands: every setting plus
+// -config and -print-config.
+func newFlagSet(c *Config, usage io.Writer) (fs *flag.FlagSet, path *string, print *bool) {
+	fs = flag.NewFlagSet("url-shortener", flag.ContinueOnError)
+	fs.SetOutput(usage)
+	path = fs.String("config", "", "YAML or JSON configuration file; flags and URLSHORTENER_* variables override it")
+	print = fs.Bool("print-config", false, "print the effective configuration as YAML and exit")
+	Bind(fs, c)
+	return fs, path, print
+}
+
+// applyEnv sets every flag whose environment variable is present.
+func applyEnv(fs *flag.FlagSet, lookupEnv func(string) (string, bool)) error {
+	var errs []error
+	fs.VisitAll(func(f *flag.Flag) {
+		name := EnvName(f.Name)
+		if value, ok := lookupEnv(name); ok {
+			if err := f.Value.Set(value); err != nil {
+				errs = append(errs, fmt.Errorf("config: %s: %w", name, err))
+			}
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// EnvName returns the environment variable read for a flag.
+func EnvName(flagName string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// readFile overlays the settings in a YAML or JSON file on c. Unknown keys
+// are rejected so that typos do not go unnoticed.
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+	return nil
+}
+
+// YAML renders c in the file format Load reads.
+func (c Config) YAML() ([]byte, error) {
+	return yaml.Marshal(c)
+}
+
+// list is a comma-separated flag value.
+type list []string
+
+func (l *list) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *list) Set(s string) error {
+	*l = splitList(s)
+	return nil
+}
@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"url-shortener/internal/auth"
+	"url-shortener/internal/service"
+	"url-shortener/model"
+
+	restful "github.com/emicklei/go-restful/v3"
+)
+
+// keyAttribute is the request attribute Authenticate stores the caller's
+// auth.Key under.
+const keyAttribute = "apiKey"
+
+// Authenticate admits requests carrying a live API key, sent as
+// "Authorization: Bearer <key>" or "X-API-Key: <key>", and records the key
+// for the handlers. Anything else is rejected with 401.
+func (h *Handler) Authenticate(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+	key, ok := h.Keys.Authenticate(apiKey(req.Request))
+	if !ok {
+		resp.AddHeader("WWW-Authenticate", `Bearer realm="url-shortener"`)
+		writeErrorCode(req, resp, http.StatusUnauthorized, CodeUnauthorized, "missing or invalid API key")
+		return
+	}
+	req.SetAttribute(keyAttribute, key)
+	chain.ProcessFilter(req, resp)
+}
+
+// RequireAdmin rejects callers without the admin role with 403. It runs
+// after Authenticate.
+func (h *Handler) RequireAdmin(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+	if !requestKey(req).Admin() {
+		writeErrorCode(req, resp, http.StatusForbidden, CodeForbidden, "admin role required")
+		return
+	}
+	chain.ProcessFilter(req, resp)
+}
+
+// RequireInstanceAdmin is RequireAdmin for admin keys not confined to a
+// tenant.
+func (h *Handler) RequireInstanceAdmin(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+	if !instanceAdmin(requestKey(req)) {
+		writeErrorCode(req, resp, http.StatusForbidden, CodeForbidden, "instance admin role required")
+		return
+	}
+	chain.ProcessFilter(req, resp)
+}
+
+// RequireLinkAccess rejects metrics keys, which may only scrape /metrics,
+// with 403. It runs after Authenticate.
+func (h *Handler) RequireLinkAccess(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+	if requestKey(req).Metrics() {
+		writeErrorCode(req, resp, http.StatusForbidden, CodeForbidden, "metrics keys may only scrape metrics")
+		return
+	}
+	chain.ProcessFilter(req, resp)
+}
+
+// RequireScraper admits metrics keys and instance admins with 403 for the
+// rest. It runs after Authenticate.
+func (h *Handler) RequireScraper(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+	if key := requestKey(req); !key.Metrics() && !instanceAdmin(key) {
+		writeErrorCode(req, resp, http.StatusForbidden, CodeForbidden, "metrics or instance admin role required")
+		return
+	}
+	chain.ProcessFilter(req, resp)
+}
+
+func apiKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+// requestKey returns the key Authenticate admitted the request with, or the
+// zero Key on routes without authentication.
+func requestKey(req *restful.Request) auth.Key {
+	key, _ := req.Attribute(keyAttribute).(auth.Key)
+	return key
+}
+
+// caller is who link operations on req act for.
+func caller(req *restful.Request) service.Caller {
+	key := requestKey(req)
+	return service.Caller{Owner: key.ID, Admin: key.Admin()}
+}
+
+// CreateKey serves POST /admin/keys. The key itself is only ever returned
+// in this response.
+func (h *Handler) CreateKey(req *restful.Request, resp *restful.Response) {
+	var in model.APIKeyRequest
+	if err := req.ReadEntity(&in); err != nil {
+		writeErrorCode(req, resp, http.StatusBadRequest, CodeMalformedBody, err.Error())
+		return
+	}
+	role := auth.Role(in.Role)
+	if role == "" {
+		role = auth.RoleUser
+	}
+	if in.Tenant != "" && !h.Tenants.Exists(in.Tenant) {
+		writeErrorCode(req, resp, http.StatusBadRequest, CodeTenantNotFound, "tenant not found")
+		return
+	}
+	secret, key, err := h.Keys.Create(in.Name, role, in.Tenant)
+	if err != nil {
+		writeError(req, resp, err)
+		return
+	}
+	out := keyResponse(key)
+	out.Key = secret
+	resp.WriteHeaderAndEntity(http.StatusCreated, out)
+}
+
+// ListKeys serves GET /admin/keys.
+func (h *Handler) ListKeys(req *restful.Request, resp *restful.Response) {
+	keys := h.Keys.List()
+	out := make([]model.APIKeyResponse, len(keys))
+	for i, key := range keys {
+		out[i] = keyResponse(key)
+	}
+	resp.WriteEntity(out)
+}
+
+// RevokeKey serves DELETE /admin/keys/{id}.
+func (h *Handler) RevokeKey(req *restful.Request, resp *restful.Response) {
+	key, err := h.Keys.Revoke(req.PathParameter("id"))
+	if err != nil {
+		writeError(req, resp, err)
+		return
+	}
+	resp.WriteEntity(keyResponse(key))
+}
+
+func keyResponse(key auth.Key) model.APIKeyResponse {
+	return model.APIKeyResponse{
+		ID:        key.ID,
+		Name:      key.Name,
+		Role:      string(key.Role),
+		Tenant:    key.Tenant,
+		CreatedAt: key.CreatedAt,
+		RevokedAt: key.RevokedAt,
+	}
+}
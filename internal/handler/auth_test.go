@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"url-shortener/internal/auth"
+	"url-shortener/model"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type AuthTestSuite struct {
+	suite.Suite
+	Service   *urlServiceMock
+	Keys      *auth.Keyring
+	Container *restful.Container
+	Admin     string
+	User      string
+	UserKey   auth.Key
+}
+
+func TestAuthTestSuite(t *testing.T) {
+	suite.Run(t, new(AuthTestSuite))
+}
+
+func (suite *AuthTestSuite) SetupTest() {
+	suite.Service = &urlServiceMock{}
+	suite.Keys = auth.NewKeyring()
+	suite.Container = restful.NewContainer()
+	NewHandler(suite.Service, suite.Keys).Register(suite.Container)
+
+	var err error
+	suite.Admin, _, err = suite.Keys.Create("ops", auth.RoleAdmin, "")
+	require.NoError(suite.T(), err)
+	suite.User, suite.UserKey, err = suite.Keys.Create("marketing", auth.RoleUser, "")
+	require.NoError(suite.T(), err)
+}
+
+func (suite *AuthTestSuite) serve(method, target, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", restful.MIME_JSON)
+	}
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	recorder := httptest.NewRecorder()
+	suite.Container.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func (suite *AuthTestSuite) TestMissingOrInvalidKey() {
+	for _, key := range []string{"", "usk_nope"} {
+		recorder := suite.serve("POST", "/shorten", key, `{"original_url":"https://example.com"}`)
+		assert.Equal(suite.T(), http.StatusUnauthorized, recorder.Code, key)
+		assert.NotEmpty(suite.T(), recorder.Header().Get("WWW-Authenticate"))
+	}
+}
+
+func (suite *AuthTestSuite) TestRedirectIsPublic() {
+	recorder := suite.serve("GET", "/r/abc123", "", "")
+
+	assert.Equal(suite.T(), http.StatusMovedPermanently, recorder.Code)
+}
+
+func (suite *AuthTestSuite) TestShortenRecordsOwner() {
+	recorder := suite.serve("POST", "/shorten", suite.User, `{"original_url":"https://example.com"}`)
+
+	assert.Equal(suite.T(), http.StatusOK, recorder.Code)
+	assert.Equal(suite.T(), suite.UserKey.ID, suite.Service.owner)
+}
+
+func (suite *AuthTestSuite) TestAPIKeyHeader() {
+	req := httptest.NewRequest("GET", "/links", nil)
+	req.Header.Set("X-API-Key", suite.User)
+	recorder := httptest.NewRecorder()
+
+	suite.Container.ServeHTTP(recorder, req)
+
+	assert.Equal(suite.T(), http.StatusOK, recorder.Code)
+	assert.Equal(suite.T(), suite.UserKey.ID, suite.Service.listCaller.Owner)
+	assert.False(suite.T(), suite.Service.listCaller.Admin)
+}
+
+func (suite *AuthTestSuite) TestAdminOnlyRoutes() {
+	for _, route := range []struct{ method, target string }{
+		{"GET", "/api/v1/analytics/top-domains"},
+		{"GET", "/admin/keys"},
+	} {
+		recorder := suite.serve(route.method, route.target, suite.User, "")
+		assert.Equal(suite.T(), http.StatusForbidden, recorder.Code, route.target)
+
+		recorder = suite.serve(route.method, route.target, suite.Admin, "")
+		assert.Equal(suite.T(), http.StatusOK, recorder.Code, route.target)
+	}
+}
+
+func (suite *AuthTestSuite) TestMetricsKeyOnlyScrapes() {
+	recorder := suite.serve("POST", "/admin/keys", suite.Admin, `{"name":"prometheus","role":"metrics"}`)
+	require.Equal(suite.T(), http.StatusCreated, recorder.Code)
+	var created model.APIKeyResponse
+	require.NoError(suite.T(), json.Unmarshal(recorder.Body.Bytes(), &created))
+	assert.Equal(suite.T(), string(auth.RoleMetrics), created.Role)
+
+	assert.Equal(suite.T(), http.StatusOK, suite.serve("GET", "/metrics", created.Key, "").Code)
+	assert.Equal(suite.T(), http.StatusOK, suite.serve("GET", "/metrics", suite.Admin, "").Code)
+	assert.Equal(suite.T(), http.StatusForbidden, suite.serve("GET", "/metrics", suite.User, "").Code)
+	for _, route := range []struct{ method, target, body string }{
+		{"POST", "/shorten", `{"original_url":"https://example.com"}`},
+		{"POST", "/shorten/batch", `[{"original_url":"https://example.com"}]`},
+		{"GET", "/links", ""},
+		{"GET", "/links/abc123/stats", ""},
+		{"DELETE", "/links/abc123", ""},
+		{"GET", "/api/v1/analytics/top-domains", ""},
+		{"GET", "/admin/keys", ""},
+	} {
+		recorder := suite.serve(route.method, route.target, created.Key, route.body)
+		assert.Equal(suite.T(), http.StatusForbidden, recorder.Code, route.target)
+	}
+	assert.Empty(suite.T(), suite.Service.owner, "A metrics key must not create links")
+}
+
+func (suite *AuthTestSuite) TestCreateAndRevokeKey() {
+	recorder := suite.serve("POST", "/admin/keys", suite.Admin, `{"name":"partner"}`)
+	require.Equal(suite.T(), http.StatusCreated, recorder.Code)
+	var created model.APIKeyResponse
+	require.NoError(suite.T(), json.Unmarshal(recorder.Body.Bytes(), &created))
+	assert.Equal(suite.T(), "partner", created.Name)
+	assert.Equal(suite.T(), string(auth.RoleUser), created.Role)
+	require.NotEmpty(suite.T(), created.Key)
+	assert.Equal(suite.T(), http.StatusOK, suite.serve("GET", "/links", created.Key, "").Code)
+
+	recorder = suite.serve("GET", "/admin/keys", suite.Admin, "")
+	assert.NotContains(suite.T(), recorder.Body.String(), created.Key, "Listings never reveal keys")
+
+	recorder = suite.serve("DELETE", "/admin/keys/"+created.ID, suite.Admin, "")
+	assert.Equal(suite.T(), http.StatusOK, recorder.Code)
+	assert.Equal(suite.T(), http.StatusUnauthorized, suite.serve("GET", "/links", created.Key, "").Code)
+	assert.Equal(suite.T(), http.StatusNotFound, suite.serve("DELETE", "/admin/keys/missing", suite.Admin, "").Code)
+}
+
+func (suite *AuthTestSuite) TestCreateKeyInvalidRole() {
+	recorder := suite.serve("POST", "/admin/keys", suite.Admin, `{"name":"x","role":"root"}`)
+
+	assert.Equal(suite.T(), http.StatusBadRequest, recorder.Code)
+}
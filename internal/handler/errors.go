@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strings"
+
+	"url-shortener/internal/auth"
+	"url-shortener/internal/service"
+	"url-shortener/model"
+
+	restful "github.com/emicklei/go-restful/v3"
+)
+
+// Error codes the handlers report themselves. Rejected URLs use the
+// validation codes of the service package, and errorCodes lists the rest.
+const (
+	CodeMalformedBody    = "malformed_body"
+	CodeInvalidParameter = "invalid_parameter"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeTenantNotFound   = "tenant_not_found"
+	CodeRateLimited      = "rate_limited"
+	CodeInternal         = "internal_error"
+)
+
+// errorCodes maps the errors of the service and auth packages to the status
+// and code they are reported with. Anything else is a 500.
+var errorCodes = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{service.ErrInvalidAlias, http.StatusBadRequest, "invalid_alias"},
+	{service.ErrReservedAlias, http.StatusBadRequest, "reserved_alias"},
+	{service.ErrAliasTaken, http.StatusConflict, "alias_taken"},
+	{service.ErrQuotaExceeded, http.StatusForbidden, "quota_exceeded"},
+	{service.ErrBatchTooLarge, http.StatusRequestEntityTooLarge, "batch_too_large"},
+	{service.ErrLinkNotFound, http.StatusNotFound, "link_not_found"},
+	{service.ErrLinkExpired, http.StatusGone, "link_expired"},
+	{service.ErrVersionConflict, http.StatusConflict, "version_conflict"},
+	{service.ErrLinkNotDeleted, http.StatusConflict, "link_not_deleted"},
+	{service.ErrInvalidWindow, http.StatusBadRequest, "invalid_window"},
+	{auth.ErrKeyNotFound, http.StatusNotFound, "key_not_found"},
+	{auth.ErrInvalidRole, http.StatusBadRequest, "invalid_role"},
+	{auth.ErrTenantMetrics, http.StatusBadRequest, "invalid_role"},
+}
+
+// describe returns the status and error body err is reported with. The
+// details of unexpected errors are not sent to the client; see writeError.
+func describe(err error) (int, model.Error) {
+	var verr *service.ValidationError
+	if errors.As(err, &verr) {
+		return http.StatusBadRequest, model.Error{Code: verr.Code, Message: verr.Message}
+	}
+	for _, c := range errorCodes {
+		if errors.Is(err, c.err) {
+			return c.status, model.Error{Code: c.code, Message: message(err)}
+		}
+	}
+	return http.StatusInternalServerError, model.Error{Code: CodeInternal, Message: "internal server error"}
+}
+
+// message drops the package prefix from an error message.
+func message(err error) string {
+	msg := err.Error()
+	for _, prefix := range []string{"service: ", "auth: "} {
+		msg = strings.TrimPrefix(msg, prefix)
+	}
+	return msg
+}
+
+// writeError answers req with the status and code describe picks for err,
+// logging the errors it hides from the client.
+func writeError(req *restful.Request, resp *restful.Response, err error) {
+	status, body := describe(err)
+	if status == http.StatusInternalServerError {
+		requestLogger(req).Error("request failed", "err", err)
+	}
+	writeErrorBody(req, resp, status, body)
+}
+
+// writeErrorCode answers req with an error the handler detected itself.
+func writeErrorCode(req *restful.Request, resp *restful.Response, status int, code, message string) {
+	writeErrorBody(req, resp, status, model.Error{Code: code, Message: message})
+}
+
+func writeErrorBody(req *restful.Request, resp *restful.Response, status int, body model.Error) {
+	body.RequestID = requestID(req)
+	resp.WriteHeaderAndJson(status, model.ErrorResponse{Error: body}, restful.MIME_JSON)
+}
+
+// Recover is a container filter turning a panic in any filter or handler
+// into a 500, whatever value was panicked with.
+func (h *Handler) Recover(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		if r == http.ErrAbortHandler {
+			panic(r)
+		}
+		requestLogger(req).Error("panic serving request", "panic", fmt.Sprint(r), "stack", string(debug.Stack()))
+		writeErrorCode(req, resp, http.StatusInternalServerError, CodeInternal, "internal server error")
+	}()
+	chain.ProcessFilter(req, resp)
+}
+
+// RouteError reports the requests go-restful rejects before reaching a
+// route, such as unknown paths or unsupported content types, in the shared
+// error format. Their code is the snake-cased status text, e.g. not_found.
+func (h *Handler) RouteError(err restful.ServiceError, req *restful.Request, resp *restful.Response) {
+	code := strings.ReplaceAll(strings.ToLower(http.StatusText(err.Code)), " ", "_")
+	writeErrorCode(req, resp, err.Code, code, err.Message)
+}
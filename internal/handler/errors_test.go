@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"url-shortener/internal/auth"
+	"url-shortener/internal/service"
+	"url-shortener/model"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type ErrorsTestSuite struct {
+	suite.Suite
+	Container *restful.Container
+}
+
+func TestErrorsTestSuite(t *testing.T) {
+	suite.Run(t, new(ErrorsTestSuite))
+}
+
+func (suite *ErrorsTestSuite) SetupTest() {
+	suite.Container = restful.NewContainer()
+	NewHandler(&urlServiceMock{}, auth.NewKeyring()).Register(suite.Container)
+}
+
+func (suite *ErrorsTestSuite) TestDescribe() {
+	for _, tc := range []struct {
+		err    error
+		status int
+		want   model.Error
+	}{
+		{service.ErrLinkNotFound, http.StatusNotFound, model.Error{Code: "link_not_found", Message: "short URL not found"}},
+		{fmt.Errorf("%w: %q", service.ErrReservedAlias, "admin"), http.StatusBadRequest, model.Error{Code: "reserved_alias", Message: `alias is reserved: "admin"`}},
+		{service.ErrVersionConflict, http.StatusConflict, model.Error{Code: "version_conflict", Message: "link was modified concurrently"}},
+		{service.ErrQuotaExceeded, http.StatusForbidden, model.Error{Code: "quota_exceeded", Message: "link quota exceeded"}},
+		{auth.ErrKeyNotFound, http.StatusNotFound, model.Error{Code: "key_not_found", Message: "api key not found"}},
+		{&service.ValidationError{Code: service.CodeEmptyURL, Message: "url is empty"}, http.StatusBadRequest, model.Error{Code: service.CodeEmptyURL, Message: "url is empty"}},
+		{errors.New("disk on fire"), http.StatusInternalServerError, model.Error{Code: CodeInternal, Message: "internal server error"}},
+	} {
+		status, body := describe(tc.err)
+		assert.Equal(suite.T(), tc.status, status, tc.err.Error())
+		assert.Equal(suite.T(), tc.want, body, tc.err.Error())
+	}
+}
+
+func (suite *ErrorsTestSuite) TestRequestID() {
+	req := httptest.NewRequest("POST", "/shorten", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", restful.MIME_JSON)
+	req.Header.Set("X-Request-ID", "req-42")
+	recorder := httptest.NewRecorder()
+
+	suite.Container.ServeHTTP(recorder, req)
+
+	assert.Equal(suite.T(), http.StatusUnauthorized, recorder.Code)
+	assert.Equal(suite.T(), restful.MIME_JSON, recorder.Header().Get("Content-Type"))
+	assert.Equal(suite.T(), model.Error{Code: CodeUnauthorized, Message: "missing or invalid API key", RequestID: "req-42"}, errorBody(recorder))
+}
+
+func (suite *ErrorsTestSuite) TestRouteErrors() {
+	for _, tc := range []struct {
+		method, target, contentType string
+		status                      int
+		code                        string
+	}{
+		{"GET", "/nope/nope", "", http.StatusNotFound, "not_found"},
+		{"PUT", "/shorten", restful.MIME_JSON, http.StatusMethodNotAllowed, "method_not_allowed"},
+		{"POST", "/shorten", "text/plain", http.StatusUnsupportedMediaType, "unsupported_media_type"},
+	} {
+		req := httptest.NewRequest(tc.method, tc.target, strings.NewReader("x"))
+		if tc.contentType != "" {
+			req.Header.Set("Content-Type", tc.contentType)
+		}
+		recorder := httptest.NewRecorder()
+
+		suite.Container.ServeHTTP(recorder, req)
+
+		assert.Equal(suite.T(), tc.status, recorder.Code, tc.target)
+		assert.Equal(suite.T(), tc.code, errorBody(recorder).Code, tc.target)
+	}
+}
+
+func (suite *ErrorsTestSuite) TestRecoverAnyPanicValue() {
+	for _, value := range []any{"boom", errors.New("boom"), 42, nil} {
+		ws := new(restful.WebService).Path("/panic")
+		ws.Route(ws.GET("").To(func(*restful.Request, *restful.Response) { panic(value) }))
+		container := restful.NewContainer()
+		container.Add(ws)
+		container.Filter(new(Handler).Recover)
+		recorder := httptest.NewRecorder()
+
+		assert.NotPanics(suite.T(), func() {
+			container.ServeHTTP(recorder, httptest.NewRequest("GET", "/panic", nil))
+		})
+		assert.Equal(suite.T(), http.StatusInternalServerError, recorder.Code, value)
+		assert.Equal(suite.T(), CodeInternal, errorBody(recorder).Code, value)
+	}
+}
@@ -1,98 +1,670 @@
 package handler
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"math"
 	"net/http"
-	"sort"
+	"net/netip"
+	"strconv"
 	"strings"
+	"time"
 
+	"url-shortener/internal/auth"
+	"url-shortener/internal/health"
+	"url-shortener/internal/logging"
+	"url-shortener/internal/metrics"
+	"url-shortener/internal/openapi"
 	"url-shortener/internal/service"
+	"url-shortener/internal/storage"
 	"url-shortener/model"
 
 	restful "github.com/emicklei/go-restful/v3"
 )
 
+// MIME_NDJSON is newline-delimited JSON, accepted and produced by
+// POST /shorten/batch.
+const MIME_NDJSON = "application/x-ndjson"
+
+// maxNDJSONLine bounds one line of an NDJSON batch.
+const maxNDJSONLine = 1 << 20
+
+// maxBatchItemBytes is the body size a batch may use per item it is allowed,
+// generous for a URL of service.DefaultMaxURLLength with tags and alias.
+const maxBatchItemBytes = 16 << 10
+
+// maxTTLSeconds is the longest ttl_seconds that fits a time.Duration.
+const maxTTLSeconds = math.MaxInt64 / int64(time.Second)
+
+// URLService is the subset of service.URLService the handlers depend on.
+type URLService interface {
+	ShortenURL(original string, opts service.ShortenOptions) (string, error)
+	ShortenBatch(items []service.BatchItem) ([]service.BatchResult, error)
+	Resolve(short string) (service.Target, error)
+	GetTopDomains(limit int, window time.Duration) ([]service.DomainCount, error)
+	RecordClick(short, referrer, userAgent string)
+	GetLinkStats(caller service.Caller, short string) (storage.LinkStats, error)
+	UpdateLink(caller service.Caller, short, original string, version int64) (storage.Link, error)
+	DeleteLink(caller service.Caller, short string, version int64) (storage.Link, error)
+	RestoreLink(caller service.Caller, short string) (storage.Link, error)
+	ListLinks(caller service.Caller, opts service.ListOptions) (service.LinkListing, error)
+}
+
 type Handler struct {
-	URLService *service.URLService
+	URLService URLService
+	// Keys authenticates callers; see Authenticate.
+	Keys *auth.Keyring
+	// Tenants are the namespaces besides the default one served by
+	// URLService; see SelectTenant.
+	Tenants Tenants
+	// BaseURL is the public address short URLs of the default namespace are
+	// built on, such as "https://sho.rt". Empty uses each request's Host.
+	BaseURL string
+	// TrustedProxies may set X-Forwarded-For, X-Forwarded-Host and
+	// X-Forwarded-Proto; see ratelimit.ClientIP and baseURL.
+	TrustedProxies []netip.Prefix
+	// RateLimits are enforced by the RateLimit filter; zero means no limits.
+	RateLimits RateLimits
+	// Registry collects the metrics served on /metrics.
+	Registry *metrics.Registry
+	// Health holds the readiness checks behind /readyz.
+	Health *health.Registry
+	// Logger receives the access log and request errors.
+	Logger *slog.Logger
+	// TopDomainsLimit is how many domains the leaderboard returns without
+	// ?limit; zero means service.DefaultTopDomains.
+	TopDomainsLimit int
+	// MaxBatchSize is the most items POST /shorten/batch reads before
+	// rejecting the request; zero means service.DefaultMaxBatchSize. It
+	// should match the services' WithMaxBatchSize.
+	MaxBatchSize int
+
+	spec      *openapi.Document
+	requests  *metrics.CounterVec
+	latency   *metrics.HistogramVec
+	redirects *metrics.CounterVec
+	limited   *metrics.CounterVec
 }
 
-func NewHandler(svc *service.URLService) *Handler {
-	return &Handler{URLService: svc}
+func NewHandler(svc URLService, keys *auth.Keyring) *Handler {
+	registry := metrics.NewRegistry()
+	return &Handler{
+		URLService: svc,
+		Keys:       keys,
+		Registry:   registry,
+		Health:     health.NewRegistry(),
+		Logger:     slog.Default(),
+		requests:   registry.Counter("http_requests_total", "HTTP requests served, by route, method and status code.", "route", "method", "code"),
+		latency:    registry.Histogram("http_request_duration_seconds", "HTTP request latency, by route and method.", metrics.DefBuckets, "route", "method"),
+		redirects:  registry.Counter("urlshortener_redirects_total", "Redirect lookups, by result (hit, miss or expired).", "result"),
+		limited:    registry.Counter("urlshortener_rate_limited_total", "Requests rejected by rate limiting, by budget (shorten or redirect).", "budget"),
+	}
 }
 
+// Register adds the API to container. Link routes are served at the root
+// and again under /t/{tenant}; instance administration, the probes and the
+// OpenAPI document only at the root.
 func (h *Handler) Register(container *restful.Container) {
+	ws := h.webService("/")
+	addProbes(ws, probes{h.Health})
+	ws.Route(ws.GET("/openapi.json").Operation("OpenAPI").To(h.OpenAPI).
+		Doc("Describe the API").
+		Notes("An OpenAPI 3.0 document generated from the routes themselves.").
+		Returns(http.StatusOK, "The OpenAPI document", map[string]any{}))
+	ws.Route(ws.GET("/metrics").Operation("Metrics").Do(h.scraper).Produces(metrics.ContentType, "text/plain").To(h.Metrics).
+		Doc("Scrape metrics").
+		Notes("Needs a key with the metrics role, which can do nothing else, or an instance admin key.").
+		Returns(http.StatusOK, "Prometheus text exposition", ""))
+	ws.Route(ws.GET("/admin/keys").Operation("ListKeys").Do(h.instanceAdmin).To(h.ListKeys).
+		Doc("List API keys").
+		Notes("Secrets are never included.").
+		Returns(http.StatusOK, "Every key, revoked ones included", []model.APIKeyResponse{}))
+	ws.Route(ws.POST("/admin/keys").Operation("CreateKey").Do(h.instanceAdmin).To(h.CreateKey).
+		Doc("Create an API key").
+		Notes("The key itself is only ever returned in this response.").
+		Reads(model.APIKeyRequest{}).
+		Returns(http.StatusCreated, "The new key with its secret", model.APIKeyResponse{}).
+		Returns(http.StatusBadRequest, "Malformed body, unknown role or unknown tenant", model.ErrorResponse{}))
+	ws.Route(ws.DELETE("/admin/keys/{id}").Operation("RevokeKey").Do(h.instanceAdmin).To(h.RevokeKey).
+		Doc("Revoke an API key").
+		Param(ws.PathParameter("id", "Key id")).
+		Returns(http.StatusOK, "The revoked key", model.APIKeyResponse{}).
+		Returns(http.StatusNotFound, "No such key", model.ErrorResponse{}))
+
+	container.Add(ws)
+	container.Add(h.webService(tenantPrefix))
+	container.ServiceErrorHandler(h.RouteError)
+	container.Filter(h.RequestID)
+	container.Filter(h.AccessLog)
+	container.Filter(h.Recover)
+	container.Filter(h.RateLimit)
+	h.spec = openapi.Build(apiDescription, container.RegisteredWebServices())
+}
+
+// webService returns the routes that act in a tenant, rooted at path.
+// Under /t/{tenant} their operations are named with a Tenant prefix.
+func (h *Handler) webService(path string) *restful.WebService {
 	ws := new(restful.WebService)
-	ws.Path("/").Consumes(restful.MIME_JSON).Produces(restful.MIME_JSON)
+	ws.Path(path).Consumes(restful.MIME_JSON).Produces(restful.MIME_JSON)
+	ws.Filter(h.Instrument)
+	op := func(name string) string { return name }
+	if path == tenantPrefix {
+		ws.Param(ws.PathParameter("tenant", "Tenant id"))
+		op = func(name string) string { return "Tenant" + name }
+	}
+	short := ws.PathParameter("short", "Short code")
 
-	ws.Route(ws.POST("/shorten").To(h.Shorten))
-	ws.Route(ws.GET("/r/{short}").To(h.Redirect))
-	ws.Route(ws.GET("/metrics").To(h.Metrics))
+	ws.Route(ws.POST("/shorten").Operation(op("Shorten")).Do(h.authenticated).To(h.Shorten).
+		Doc("Shorten a URL").
+		Notes("Shortening a URL again returns the same code unless the request sets an alias, expiry, redirect status or tags.").
+		Reads(model.URLRequest{}).
+		Returns(http.StatusOK, "The short URL", model.URLResponse{}).
+		Returns(http.StatusBadRequest, "Malformed body, invalid URL or invalid alias", model.ErrorResponse{}).
+		Returns(http.StatusForbidden, "Metrics key, key of another tenant, or the tenant's link quota is used up", model.ErrorResponse{}).
+		Returns(http.StatusConflict, "The alias is taken", model.ErrorResponse{}).
+		Returns(http.StatusTooManyRequests, "Rate limited", model.ErrorResponse{}))
+	ws.Route(ws.POST("/shorten/batch").Operation(op("ShortenBatch")).Do(h.authenticated).Consumes(restful.MIME_JSON, MIME_NDJSON).Produces(restful.MIME_JSON, MIME_NDJSON).To(h.ShortenBatch).
+		Doc("Shorten many URLs").
+		Notes("The body is a JSON array or, as application/x-ndjson, one request per line. Results come back in input order in the same format; an item that fails carries an error without failing the others.").
+		Reads([]model.URLRequest{}).
+		Returns(http.StatusOK, "One result per item", []model.BatchResult{}).
+		Returns(http.StatusBadRequest, "Malformed body", model.ErrorResponse{}).
+		Returns(http.StatusRequestEntityTooLarge, "Too many items or too large a body", model.ErrorResponse{}).
+		Returns(http.StatusTooManyRequests, "Rate limited", model.ErrorResponse{}))
+	ws.Route(ws.GET("/r/{short}").Operation(op("Redirect")).Do(h.public, redirects).Param(short).To(h.Redirect).
+		Doc("Follow a short link"))
+	ws.Route(ws.GET("/{short}").Operation(op("RedirectShort")).Do(h.public, redirects).Param(short).To(h.Redirect).
+		Doc("Follow a short link").
+		Notes("Same as /r/{short}; fixed routes such as /links take precedence."))
+	ws.Route(ws.GET("/api/v1/analytics/top-domains").Operation(op("TopDomains")).Do(h.admin).To(h.TopDomains).
+		Doc("Rank the most shortened domains").
+		Param(ws.QueryParameter("limit", "Domains to return").DataType("integer")).
+		Param(ws.QueryParameter("window", "Only count links shortened this long ago or later, such as 24h")).
+		Returns(http.StatusOK, "Domains by links shortened, most first", []service.DomainCount{}).
+		Returns(http.StatusBadRequest, "Invalid limit or window", model.ErrorResponse{}))
+	ws.Route(ws.GET("/links/{short}/stats").Operation(op("LinkStats")).Do(h.authenticated).Param(short).To(h.LinkStats).
+		Doc("Report how often a link was followed").
+		Returns(http.StatusOK, "Click statistics", model.LinkStatsResponse{}).
+		Returns(http.StatusNotFound, "No such link, or not the caller's", model.ErrorResponse{}))
+	ws.Route(ws.GET("/links").Operation(op("ListLinks")).Do(h.authenticated).To(h.ListLinks).
+		Doc("List links").
+		Notes("Callers see their own links; admins see all and may filter by owner.").
+		Param(ws.QueryParameter("domain", "Destination domain")).
+		Param(ws.QueryParameter("tag", "Tag")).
+		Param(ws.QueryParameter("owner", "Owning key id; admins only")).
+		Param(ws.QueryParameter("q", "Substring of the destination")).
+		Param(ws.QueryParameter("created_after", "RFC 3339 time").DataFormat("date-time")).
+		Param(ws.QueryParameter("created_before", "RFC 3339 time").DataFormat("date-time")).
+		Param(ws.QueryParameter("sort", "Order of the listing").PossibleValues([]string{
+			string(storage.SortCreatedAsc), string(storage.SortCreatedDesc), string(storage.SortClicksAsc), string(storage.SortClicksDesc),
+		})).
+		Param(ws.QueryParameter("limit", "Links per page").DataType("integer")).
+		Param(ws.QueryParameter("cursor", "next_cursor of the previous page")).
+		Returns(http.StatusOK, "One page of links", model.LinkListResponse{}).
+		Returns(http.StatusBadRequest, "Invalid parameter", model.ErrorResponse{}))
+	ws.Route(ws.PATCH("/links/{short}").Operation(op("UpdateLink")).Do(h.authenticated).Param(short).To(h.UpdateLink).
+		Doc("Retarget a link").
+		Reads(model.LinkUpdateRequest{}).
+		Returns(http.StatusOK, "The updated link", model.LinkResponse{}).
+		Returns(http.StatusBadRequest, "Malformed body or invalid URL", model.ErrorResponse{}).
+		Returns(http.StatusNotFound, "No such link, or not the caller's", model.ErrorResponse{}).
+		Returns(http.StatusConflict, "The link changed since the given version", model.ErrorResponse{}))
+	ws.Route(ws.DELETE("/links/{short}").Operation(op("DeleteLink")).Do(h.authenticated).Param(short).To(h.DeleteLink).
+		Doc("Delete a link").
+		Notes("The link stops redirecting but can be restored until its restore window ends.").
+		Param(ws.QueryParameter("version", "Only delete if the link is still at this version").DataType("integer")).
+		Returns(http.StatusOK, "The deleted link", model.LinkResponse{}).
+		Returns(http.StatusBadRequest, "Invalid version", model.ErrorResponse{}).
+		Returns(http.StatusNotFound, "No such link, or not the caller's", model.ErrorResponse{}).
+		Returns(http.StatusConflict, "The link changed since the given version", model.ErrorResponse{}))
+	ws.Route(ws.POST("/links/{short}/restore").Operation(op("RestoreLink")).Do(h.authenticated).AllowedMethodsWithoutContentType([]string{http.MethodPost}).Param(short).To(h.RestoreLink).
+		Doc("Restore a deleted link").
+		Returns(http.StatusOK, "The restored link", model.LinkResponse{}).
+		Returns(http.StatusNotFound, "No such link, or not the caller's", model.ErrorResponse{}).
+		Returns(http.StatusConflict, "The link is not deleted", model.ErrorResponse{}))
+	return ws
+}
 
-	container.Add(ws)
+// redirects documents the responses of a redirect route.
+func redirects(b *restful.RouteBuilder) {
+	headers := map[string]restful.Header{
+		"Location":      {Items: &restful.Items{Type: "string"}, Description: "The destination"},
+		"Cache-Control": {Items: &restful.Items{Type: "string"}, Description: "How long the redirect may be cached"},
+	}
+	for _, status := range []int{http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect} {
+		b.ReturnsWithHeaders(status, "Redirect to the destination, with the link's status", nil, headers)
+	}
+	b.Returns(http.StatusNotFound, "No such link", model.ErrorResponse{}).
+		Returns(http.StatusGone, "The link has expired", model.ErrorResponse{}).
+		Returns(http.StatusTooManyRequests, "Rate limited", model.ErrorResponse{})
+}
+
+// Access levels for routes: redirects are public, link routes need an API
+// key, tenant statistics an admin key, and instance administration an admin
+// key not confined to a tenant.
+
+func (h *Handler) public(b *restful.RouteBuilder) {
+	b.Filter(h.SelectTenant).
+		DefaultReturns("Error", model.ErrorResponse{})
+}
+
+func (h *Handler) authenticated(b *restful.RouteBuilder) {
+	b.Filter(h.Authenticate).Filter(h.RequireLinkAccess).Filter(h.SelectTenant).
+		Metadata(openapi.MetaSecurity, true).
+		Returns(http.StatusUnauthorized, "Missing or unknown API key", model.ErrorResponse{}).
+		Returns(http.StatusForbidden, "Metrics key or key of another tenant", model.ErrorResponse{}).
+		DefaultReturns("Error", model.ErrorResponse{})
+}
+
+func (h *Handler) admin(b *restful.RouteBuilder) {
+	b.Filter(h.Authenticate).Filter(h.RequireAdmin).Filter(h.SelectTenant).
+		Metadata(openapi.MetaSecurity, true).
+		Returns(http.StatusUnauthorized, "Missing or unknown API key", model.ErrorResponse{}).
+		Returns(http.StatusForbidden, "Admin key required", model.ErrorResponse{}).
+		DefaultReturns("Error", model.ErrorResponse{})
+}
+
+func (h *Handler) scraper(b *restful.RouteBuilder) {
+	b.Filter(h.Authenticate).Filter(h.RequireScraper).
+		Metadata(openapi.MetaSecurity, true).
+		Returns(http.StatusUnauthorized, "Missing or unknown API key", model.ErrorResponse{}).
+		Returns(http.StatusForbidden, "Metrics or instance admin key required", model.ErrorResponse{}).
+		DefaultReturns("Error", model.ErrorResponse{})
+}
+
+func (h *Handler) instanceAdmin(b *restful.RouteBuilder) {
+	b.Filter(h.Authenticate).Filter(h.RequireInstanceAdmin).
+		Metadata(openapi.MetaSecurity, true).
+		Returns(http.StatusUnauthorized, "Missing or unknown API key", model.ErrorResponse{}).
+		Returns(http.StatusForbidden, "Admin key not confined to a tenant required", model.ErrorResponse{}).
+		DefaultReturns("Error", model.ErrorResponse{})
 }
 
 func (h *Handler) Shorten(req *restful.Request, resp *restful.Response) {
-	defer func() {
-		if r := recover(); r != nil {
-			fmt.Printf("Panic in Shorten: %v\n", r) // Debug log
-			resp.WriteError(http.StatusInternalServerError, r.(error))
-		}
-	}()
 	var in model.URLRequest
 	if err := req.ReadEntity(&in); err != nil {
-		fmt.Printf("ReadEntity error: %v\n", err) // Debug log
-		resp.WriteError(http.StatusBadRequest, err)
+		writeErrorCode(req, resp, http.StatusBadRequest, CodeMalformedBody, err.Error())
+		return
+	}
+	opts, err := shortenOptions(in)
+	if err != nil {
+		writeError(req, resp, err)
 		return
 	}
-	fmt.Printf("Parsed URLRequest: %+v\n", in) // Debug log
-	short := h.URLService.ShortenURL(in.OriginalURL)
-	fmt.Printf("Shortened URL: %s\n", short) // Debug log
-	resp.WriteEntity(model.URLResponse{ShortURL: short})
+	opts.Owner = requestKey(req).ID
+	ns := h.namespace(req)
+	short, err := ns.Service.ShortenURL(in.OriginalURL, opts)
+	if err != nil {
+		writeError(req, resp, err)
+		return
+	}
+	requestLogger(req).Debug("shortened", "short", short, "url", logging.URL(in.OriginalURL))
+	resp.WriteEntity(model.URLResponse{
+		ShortURL:    h.baseURL(req.Request, ns) + "/" + short,
+		Code:        short,
+		OriginalURL: in.OriginalURL,
+	})
 }
 
-func (h *Handler) Redirect(req *restful.Request, resp *restful.Response) {
-	defer func() {
-		if r := recover(); r != nil {
-			fmt.Printf("Panic in Redirect: %v\n", r) // Debug log
-			resp.WriteError(http.StatusInternalServerError, r.(error))
+// shortenOptions converts a shorten request to the options of the service.
+// It rejects a ttl_seconds that does not fit a time.Duration, which would
+// otherwise wrap around into an arbitrary expiry.
+func shortenOptions(in model.URLRequest) (service.ShortenOptions, error) {
+	switch {
+	case in.TTLSeconds < 0:
+		return service.ShortenOptions{}, &service.ValidationError{Code: service.CodeInvalidExpiry, Message: "ttl_seconds must be positive"}
+	case in.TTLSeconds > maxTTLSeconds:
+		return service.ShortenOptions{}, &service.ValidationError{Code: service.CodeInvalidExpiry, Message: fmt.Sprintf("ttl_seconds must be at most %d", maxTTLSeconds)}
+	}
+	opts := service.ShortenOptions{
+		Alias:    in.Alias,
+		TTL:      time.Duration(in.TTLSeconds) * time.Second,
+		Redirect: in.RedirectStatus,
+		Tags:     in.Tags,
+	}
+	if in.ExpiresAt != nil {
+		opts.ExpiresAt = *in.ExpiresAt
+	}
+	return opts, nil
+}
+
+// ShortenBatch serves POST /shorten/batch. The body is a JSON array of
+// shorten requests or, sent as application/x-ndjson, one request per line.
+// Results come back in input order in the same format; an item that fails
+// carries an error instead of a short URL without failing the others.
+//
+// Batches over MaxBatchSize items, or bodies over maxBatchItemBytes per
+// allowed item, are rejected with 413 before all of them is read.
+func (h *Handler) ShortenBatch(req *restful.Request, resp *restful.Response) {
+	limit := h.MaxBatchSize
+	if limit <= 0 {
+		limit = service.DefaultMaxBatchSize
+	}
+	maxBytes := int64(limit) * maxBatchItemBytes
+	req.Request.Body = http.MaxBytesReader(resp, req.Request.Body, maxBytes)
+
+	ndjson := strings.HasPrefix(req.HeaderParameter("Content-Type"), MIME_NDJSON)
+	var in []model.URLRequest
+	var err error
+	if ndjson {
+		in, err = readNDJSON(req.Request.Body, limit)
+	} else {
+		err = req.ReadEntity(&in)
+	}
+	var tooLong *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLong):
+		writeError(req, resp, fmt.Errorf("%w: body exceeds %d bytes", service.ErrBatchTooLarge, maxBytes))
+		return
+	case errors.Is(err, service.ErrBatchTooLarge):
+		writeError(req, resp, err)
+		return
+	case err != nil:
+		writeErrorCode(req, resp, http.StatusBadRequest, CodeMalformedBody, "malformed batch: "+err.Error())
+		return
+	case len(in) > limit:
+		writeError(req, resp, fmt.Errorf("%w: %d exceeds the limit of %d", service.ErrBatchTooLarge, len(in), limit))
+		return
+	}
+
+	// Items whose options are invalid fail on their own; positions maps the
+	// rest, sent to the service, back to their index in the input.
+	owner := requestKey(req).ID
+	out := make([]model.BatchResult, len(in))
+	items := make([]service.BatchItem, 0, len(in))
+	positions := make([]int, 0, len(in))
+	for i, r := range in {
+		opts, err := shortenOptions(r)
+		if err != nil {
+			_, body := describe(err)
+			out[i] = model.BatchResult{Index: i, OriginalURL: r.OriginalURL, Error: &body}
+			continue
 		}
-	}()
-	short := strings.TrimSpace(req.PathParameter("short"))
-	original, ok := h.URLService.GetOriginalURL(short)
-	if !ok {
-		resp.WriteErrorString(http.StatusNotFound, "short URL not found")
+		opts.Owner = owner
+		items = append(items, service.BatchItem{URL: r.OriginalURL, Options: opts})
+		positions = append(positions, i)
+	}
+	ns := h.namespace(req)
+	results, err := ns.Service.ShortenBatch(items)
+	if err != nil {
+		writeError(req, resp, err)
 		return
 	}
-	resp.AddHeader("Location", original)
-	resp.WriteHeader(http.StatusMovedPermanently)
+
+	base := h.baseURL(req.Request, ns)
+	for j, r := range results {
+		i := positions[j]
+		out[i] = model.BatchResult{Index: i, ShortURL: base + "/" + r.Short, Code: r.Short, OriginalURL: in[i].OriginalURL}
+		if r.Err != nil {
+			status, body := describe(r.Err)
+			if status == http.StatusInternalServerError {
+				requestLogger(req).Error("batch item failed", "index", i, "err", r.Err)
+			}
+			out[i] = model.BatchResult{Index: i, OriginalURL: in[i].OriginalURL, Error: &body}
+		}
+	}
+	if !ndjson {
+		resp.WriteEntity(out)
+		return
+	}
+	resp.AddHeader("Content-Type", MIME_NDJSON)
+	resp.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(resp)
+	for _, r := range out {
+		if err := enc.Encode(r); err != nil {
+			return
+		}
+	}
 }
 
-func (h *Handler) Metrics(req *restful.Request, resp *restful.Response) {
-	defer func() {
-		if r := recover(); r != nil {
-			fmt.Printf("Panic in Metrics: %v\n", r) // Debug log
-			resp.WriteError(http.StatusInternalServerError, r.(error))
+// readNDJSON decodes one shorten request per non-blank line of r. It stops
+// with service.ErrBatchTooLarge at the first request past limit.
+func readNDJSON(r io.Reader, limit int) ([]model.URLRequest, error) {
+	var in []model.URLRequest
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxNDJSONLine)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
 		}
-	}()
-	domains := h.URLService.GetTopDomains(3)
+		var item model.URLRequest
+		if err := json.Unmarshal(text, &item); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if len(in) == limit {
+			return nil, fmt.Errorf("%w: more than %d", service.ErrBatchTooLarge, limit)
+		}
+		in = append(in, item)
+	}
+	return in, scanner.Err()
+}
 
-	type stat struct {
-		Domain string `json:"domain"`
-		Count  int    `json:"count"`
+func (h *Handler) Redirect(req *restful.Request, resp *restful.Response) {
+	short := strings.TrimSpace(req.PathParameter("short"))
+	svc := h.namespace(req).Service
+	target, err := svc.Resolve(short)
+	switch {
+	case errors.Is(err, service.ErrLinkNotFound):
+		h.redirects.With("miss").Inc()
+	case errors.Is(err, service.ErrLinkExpired):
+		h.redirects.With("expired").Inc()
+	}
+	if err != nil {
+		writeError(req, resp, err)
+		return
 	}
+	h.redirects.With("hit").Inc()
+	svc.RecordClick(short, req.Request.Referer(), req.Request.UserAgent())
+	resp.AddHeader("Location", target.URL)
+	resp.AddHeader("Cache-Control", cacheControl(target.MaxAge))
+	resp.WriteHeader(target.Status)
+}
 
-	var list []stat
-	for k, v := range domains {
-		list = append(list, stat{k, v})
+// cacheControl lets shared caches keep a redirect for maxAge, or forbids
+// caching so every click reaches the server.
+func cacheControl(maxAge time.Duration) string {
+	if seconds := int64(maxAge / time.Second); seconds > 0 {
+		return "public, max-age=" + strconv.FormatInt(seconds, 10)
 	}
+	return "no-store"
+}
 
-	sort.Slice(list, func(i, j int) bool {
-		return list[i].Count > list[j].Count
+func (h *Handler) LinkStats(req *restful.Request, resp *restful.Response) {
+	short := strings.TrimSpace(req.PathParameter("short"))
+	stats, err := h.namespace(req).Service.GetLinkStats(caller(req), short)
+	if err != nil {
+		writeError(req, resp, err)
+		return
+	}
+	resp.WriteEntity(model.LinkStatsResponse{
+		Short:      short,
+		Clicks:     stats.Clicks,
+		FirstClick: stats.FirstClick,
+		LastClick:  stats.LastClick,
+		Referrers:  stats.Referrers,
+		UserAgents: stats.UserAgents,
 	})
+}
+
+// ListLinks serves GET /links. Filters: domain, tag, owner (admins only;
+// other callers always get their own links), q (substring of the
+// destination), created_after and created_before (RFC 3339). Paging: sort
+// (created, -created, clicks, -clicks), limit and cursor.
+func (h *Handler) ListLinks(req *restful.Request, resp *restful.Response) {
+	opts := service.ListOptions{
+		Domain: req.QueryParameter("domain"),
+		Tag:    req.QueryParameter("tag"),
+		Owner:  req.QueryParameter("owner"),
+		Search: req.QueryParameter("q"),
+		Sort:   storage.SortOrder(req.QueryParameter("sort")),
+		Cursor: req.QueryParameter("cursor"),
+	}
+	var err error
+	if opts.CreatedFrom, err = timeParameter(req, "created_after"); err != nil {
+		writeErrorCode(req, resp, http.StatusBadRequest, CodeInvalidParameter, err.Error())
+		return
+	}
+	if opts.CreatedTo, err = timeParameter(req, "created_before"); err != nil {
+		writeErrorCode(req, resp, http.StatusBadRequest, CodeInvalidParameter, err.Error())
+		return
+	}
+	if raw := req.QueryParameter("limit"); raw != "" {
+		if opts.Limit, err = strconv.Atoi(raw); err != nil || opts.Limit <= 0 {
+			writeErrorCode(req, resp, http.StatusBadRequest, CodeInvalidParameter, "limit must be a positive integer")
+			return
+		}
+	}
+
+	listing, err := h.namespace(req).Service.ListLinks(caller(req), opts)
+	if err != nil {
+		writeError(req, resp, err)
+		return
+	}
+	out := model.LinkListResponse{Links: make([]model.LinkResponse, len(listing.Links)), NextCursor: listing.NextCursor}
+	for i, link := range listing.Links {
+		out.Links[i] = linkResponse(link.Link)
+		out.Links[i].Clicks = &link.Clicks
+	}
+	resp.WriteEntity(out)
+}
+
+// timeParameter parses an optional RFC 3339 query parameter.
+func timeParameter(req *restful.Request, name string) (time.Time, error) {
+	raw := req.QueryParameter(name)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC 3339 time", name)
+	}
+	return t, nil
+}
+
+func (h *Handler) UpdateLink(req *restful.Request, resp *restful.Response) {
+	var in model.LinkUpdateRequest
+	if err := req.ReadEntity(&in); err != nil {
+		writeErrorCode(req, resp, http.StatusBadRequest, CodeMalformedBody, err.Error())
+		return
+	}
+	version := service.AnyVersion
+	if in.Version != nil {
+		version = *in.Version
+	}
+	link, err := h.namespace(req).Service.UpdateLink(caller(req), strings.TrimSpace(req.PathParameter("short")), in.OriginalURL, version)
+	writeLink(req, resp, link, err)
+}
+
+func (h *Handler) DeleteLink(req *restful.Request, resp *restful.Response) {
+	version := service.AnyVersion
+	if raw := req.QueryParameter("version"); raw != "" {
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || v < 0 {
+			writeErrorCode(req, resp, http.StatusBadRequest, CodeInvalidParameter, "version must be a non-negative integer")
+			return
+		}
+		version = v
+	}
+	link, err := h.namespace(req).Service.DeleteLink(caller(req), strings.TrimSpace(req.PathParameter("short")), version)
+	writeLink(req, resp, link, err)
+}
+
+func (h *Handler) RestoreLink(req *restful.Request, resp *restful.Response) {
+	link, err := h.namespace(req).Service.RestoreLink(caller(req), strings.TrimSpace(req.PathParameter("short")))
+	writeLink(req, resp, link, err)
+}
+
+// writeLink answers a link management request with the resulting link or
+// the error describing err.
+func writeLink(req *restful.Request, resp *restful.Response, link storage.Link, err error) {
+	if err != nil {
+		writeError(req, resp, err)
+		return
+	}
+	resp.WriteEntity(linkResponse(link))
+}
 
-	if len(list) > 3 {
-		list = list[:3]
+func linkResponse(link storage.Link) model.LinkResponse {
+	return model.LinkResponse{
+		Short:           link.Short,
+		OriginalURL:     link.Original,
+		CreatedAt:       link.CreatedAt,
+		ExpiresAt:       link.ExpiresAt,
+		RedirectStatus:  link.Redirect,
+		Tags:            link.Tags,
+		Owner:           link.Owner,
+		Version:         link.Version,
+		DeletedAt:       link.DeletedAt,
+		RestorableUntil: link.PurgeAt,
 	}
+}
+
+// Instrument counts and times every request by its route template, so
+// /r/{short} is one series rather than one per code.
+func (h *Handler) Instrument(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+	start := time.Now()
+	chain.ProcessFilter(req, resp)
+	route, method := req.SelectedRoutePath(), req.Request.Method
+	h.requests.With(route, method, strconv.Itoa(resp.StatusCode())).Inc()
+	h.latency.With(route, method).Observe(time.Since(start).Seconds())
+}
 
-	resp.WriteEntity(list)
+// apiDescription is the OpenAPI description of the API as a whole.
+var apiDescription = openapi.Config{
+	Info: openapi.Info{
+		Title:       "URL shortener",
+		Description: "Shortens URLs and redirects short links to them. Every error is reported as an ErrorResponse.",
+		Version:     "1.0",
+	},
+	SecuritySchemes: map[string]openapi.SecurityScheme{
+		"apiKey": {Type: "apiKey", Name: "X-API-Key", In: "header"},
+		"bearer": {Type: "http", Scheme: "bearer", Description: "The API key as a bearer token"},
+	},
+}
+
+// OpenAPI serves the OpenAPI document of the routes Register added.
+func (h *Handler) OpenAPI(req *restful.Request, resp *restful.Response) {
+	resp.WriteEntity(h.spec)
+}
+
+// Metrics serves the Prometheus text exposition of Registry.
+func (h *Handler) Metrics(req *restful.Request, resp *restful.Response) {
+	h.Registry.ServeHTTP(resp, req.Request)
+}
+
+func (h *Handler) TopDomains(req *restful.Request, resp *restful.Response) {
+	limit := h.TopDomainsLimit
+	if limit <= 0 {
+		limit = service.DefaultTopDomains
+	}
+	if raw := req.QueryParameter("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			writeErrorCode(req, resp, http.StatusBadRequest, CodeInvalidParameter, "limit must be a positive integer")
+			return
+		}
+		limit = n
+	}
+	var window time.Duration
+	if raw := req.QueryParameter("window"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			writeErrorCode(req, resp, http.StatusBadRequest, CodeInvalidParameter, "window must be a duration such as 24h")
+			return
+		}
+		window = d
+	}
+
+	domains, err := h.namespace(req).Service.GetTopDomains(limit, window)
+	if err != nil {
+		writeError(req, resp, err)
+		return
+	}
+	resp.WriteEntity(domains)
 }
@@ -8,7 +8,11 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
+	"url-shortener/internal/auth"
+	"url-shortener/internal/metrics"
 	"url-shortener/internal/service"
+	"url-shortener/internal/storage"
 	"url-shortener/model"
 
 	"github.com/emicklei/go-restful/v3"
@@ -25,6 +29,7 @@ var (
 type HandlerTestSuite struct {
 	suite.Suite
 	Handler          *Handler
+	Service          *urlServiceMock
 	Webservice       *restful.WebService
 	Container        *restful.Container
 	ResponseRecorder *httptest.ResponseRecorder
@@ -36,8 +41,8 @@ func TestHandlerTestSuite(t *testing.T) {
 }
 
 func (suite *HandlerTestSuite) SetupTest() {
-	mockService := &urlServiceMock{}
-	suite.Handler = NewHandler((*service.URLService)(mockService))
+	suite.Service = &urlServiceMock{}
+	suite.Handler = NewHandler(suite.Service, auth.NewKeyring())
 	suite.Container = restful.NewContainer()
 	suite.Webservice = new(restful.WebService).
 		Path("/").
@@ -50,8 +55,17 @@ func (suite *HandlerTestSuite) SetupTest() {
 	urlGetTopDomainsFail = false
 
 	suite.Webservice.Route(suite.Webservice.POST("/shorten").To(suite.Handler.Shorten))
+	suite.Webservice.Route(suite.Webservice.POST("/shorten/batch").Consumes(restful.MIME_JSON, MIME_NDJSON).Produces(restful.MIME_JSON, MIME_NDJSON).To(suite.Handler.ShortenBatch))
 	suite.Webservice.Route(suite.Webservice.GET("/r/{short}").To(suite.Handler.Redirect))
-	suite.Webservice.Route(suite.Webservice.GET("/metrics").To(suite.Handler.Metrics))
+	suite.Webservice.Filter(suite.Handler.Instrument)
+	suite.Container.Filter(suite.Handler.Recover)
+	suite.Webservice.Route(suite.Webservice.GET("/metrics").Produces(metrics.ContentType, "text/plain").To(suite.Handler.Metrics))
+	suite.Webservice.Route(suite.Webservice.GET("/api/v1/analytics/top-domains").To(suite.Handler.TopDomains))
+	suite.Webservice.Route(suite.Webservice.GET("/links/{short}/stats").To(suite.Handler.LinkStats))
+	suite.Webservice.Route(suite.Webservice.GET("/links").To(suite.Handler.ListLinks))
+	suite.Webservice.Route(suite.Webservice.PATCH("/links/{short}").To(suite.Handler.UpdateLink))
+	suite.Webservice.Route(suite.Webservice.DELETE("/links/{short}").To(suite.Handler.DeleteLink))
+	suite.Webservice.Route(suite.Webservice.POST("/links/{short}/restore").AllowedMethodsWithoutContentType([]string{http.MethodPost}).To(suite.Handler.RestoreLink))
 	suite.Container.Add(suite.Webservice)
 }
 
@@ -66,7 +80,9 @@ func (suite *HandlerTestSuite) TestShortenSuccess() {
 	suite.T().Logf("Response body: %s", suite.ResponseRecorder.Body.String())
 	assert.Equal(suite.T(), http.StatusOK, suite.ResponseRecorder.Result().StatusCode, "Expected 200 OK, got %d", suite.ResponseRecorder.Result().StatusCode)
 	assert.NotNil(suite.T(), response)
-	assert.Equal(suite.T(), "abc123", response.ShortURL)
+	assert.Equal(suite.T(), "http://example.com/abc123", response.ShortURL)
+	assert.Equal(suite.T(), "abc123", response.Code)
+	assert.Equal(suite.T(), "https://example.com", response.OriginalURL)
 }
 
 func (suite *HandlerTestSuite) TestShortenParseError() {
@@ -76,6 +92,7 @@ func (suite *HandlerTestSuite) TestShortenParseError() {
 	suite.Container.ServeHTTP(suite.ResponseRecorder, req)
 	suite.T().Logf("Response body: %s", suite.ResponseRecorder.Body.String())
 	assert.Equal(suite.T(), http.StatusBadRequest, suite.ResponseRecorder.Result().StatusCode)
+	assert.Equal(suite.T(), CodeMalformedBody, errorBody(suite.ResponseRecorder).Code)
 	assert.Contains(suite.T(), suite.ResponseRecorder.Body.String(), "invalid character")
 }
 
@@ -89,7 +106,187 @@ func (suite *HandlerTestSuite) TestShortenError() {
 	suite.Container.ServeHTTP(suite.ResponseRecorder, req)
 	suite.T().Logf("Response body: %s", suite.ResponseRecorder.Body.String())
 	assert.Equal(suite.T(), http.StatusInternalServerError, suite.ResponseRecorder.Result().StatusCode)
-	assert.Contains(suite.T(), suite.ResponseRecorder.Body.String(), "expected shorten to fail")
+	assert.Equal(suite.T(), CodeInternal, errorBody(suite.ResponseRecorder).Code)
+	assert.NotContains(suite.T(), suite.ResponseRecorder.Body.String(), "expected shorten to fail", "Panic details stay in the log")
+}
+
+func (suite *HandlerTestSuite) TestShortenWithAlias() {
+	body, _ := json.Marshal(model.URLRequest{OriginalURL: "https://example.com", Alias: "summer-sale"})
+	req := httptest.NewRequest("POST", "/shorten", bytes.NewReader(body))
+	req.Header.Set("Content-Type", restful.MIME_JSON)
+
+	suite.Container.ServeHTTP(suite.ResponseRecorder, req)
+	response := convertToURLResponse(suite.ResponseRecorder.Body.String())
+	assert.Equal(suite.T(), http.StatusOK, suite.ResponseRecorder.Result().StatusCode)
+	assert.NotNil(suite.T(), response)
+	assert.Equal(suite.T(), "http://example.com/summer-sale", response.ShortURL)
+	assert.Equal(suite.T(), "summer-sale", response.Code)
+}
+
+func (suite *HandlerTestSuite) TestShortenAliasErrors() {
+	cases := map[string]int{
+		"taken":   http.StatusConflict,
+		"metrics": http.StatusBadRequest,
+		"a":       http.StatusBadRequest,
+	}
+	for alias, status := range cases {
+		recorder := httptest.NewRecorder()
+		body, _ := json.Marshal(model.URLRequest{OriginalURL: "https://example.com", Alias: alias})
+		req := httptest.NewRequest("POST", "/shorten", bytes.NewReader(body))
+		req.Header.Set("Content-Type", restful.MIME_JSON)
+
+		suite.Container.ServeHTTP(recorder, req)
+		assert.Equal(suite.T(), status, recorder.Result().StatusCode, "alias %q", alias)
+	}
+}
+
+func (suite *HandlerTestSuite) TestShortenWithTTL() {
+	req := httptest.NewRequest("POST", "/shorten", strings.NewReader(`{"original_url":"https://example.com","ttl_seconds":60}`))
+	req.Header.Set("Content-Type", restful.MIME_JSON)
+
+	suite.Container.ServeHTTP(suite.ResponseRecorder, req)
+	response := convertToURLResponse(suite.ResponseRecorder.Body.String())
+	assert.Equal(suite.T(), http.StatusOK, suite.ResponseRecorder.Result().StatusCode)
+	assert.Equal(suite.T(), "http://example.com/ttl123", response.ShortURL)
+	assert.Equal(suite.T(), "ttl123", response.Code)
+}
+
+func (suite *HandlerTestSuite) TestShortenTTLOutOfRange() {
+	for _, ttl := range []string{"-1", "9223372037", "9223372036854775807"} {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/shorten", strings.NewReader(`{"original_url":"https://example.com","ttl_seconds":`+ttl+`}`))
+		req.Header.Set("Content-Type", restful.MIME_JSON)
+
+		suite.Container.ServeHTTP(recorder, req)
+		assert.Equal(suite.T(), http.StatusBadRequest, recorder.Result().StatusCode, ttl)
+		assert.Equal(suite.T(), service.CodeInvalidExpiry, errorBody(recorder).Code, ttl)
+	}
+}
+
+func (suite *HandlerTestSuite) TestShortenWithRedirectStatus() {
+	req := httptest.NewRequest("POST", "/shorten", strings.NewReader(`{"original_url":"https://example.com","redirect_status":302}`))
+	req.Header.Set("Content-Type", restful.MIME_JSON)
+
+	suite.Container.ServeHTTP(suite.ResponseRecorder, req)
+	response := convertToURLResponse(suite.ResponseRecorder.Body.String())
+	assert.Equal(suite.T(), http.StatusOK, suite.ResponseRecorder.Result().StatusCode)
+	assert.Equal(suite.T(), "http://example.com/temp123", response.ShortURL)
+	assert.Equal(suite.T(), "temp123", response.Code)
+
+	recorder := httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/shorten", strings.NewReader(`{"original_url":"https://example.com","redirect_status":200}`))
+	req.Header.Set("Content-Type", restful.MIME_JSON)
+	suite.Container.ServeHTTP(recorder, req)
+	assert.Equal(suite.T(), http.StatusBadRequest, recorder.Result().StatusCode)
+}
+
+func (suite *HandlerTestSuite) TestShortenInvalidURL() {
+	body, _ := json.Marshal(model.URLRequest{OriginalURL: "javascript:alert(1)"})
+	req := httptest.NewRequest("POST", "/shorten", bytes.NewReader(body))
+	req.Header.Set("Content-Type", restful.MIME_JSON)
+
+	suite.Container.ServeHTTP(suite.ResponseRecorder, req)
+	response := errorBody(suite.ResponseRecorder)
+	assert.Equal(suite.T(), http.StatusBadRequest, suite.ResponseRecorder.Result().StatusCode)
+	assert.Equal(suite.T(), service.CodeSchemeNotAllowed, response.Code)
+	assert.NotEmpty(suite.T(), response.Message)
+}
+
+func (suite *HandlerTestSuite) TestShortenBatchJSON() {
+	body := `[{"original_url":"https://example.com"},{"original_url":"javascript:alert(1)"},{"original_url":"https://example.com","alias":"taken"}]`
+	req := httptest.NewRequest("POST", "/shorten/batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", restful.MIME_JSON)
+
+	suite.Container.ServeHTTP(suite.ResponseRecorder, req)
+
+	assert.Equal(suite.T(), http.StatusOK, suite.ResponseRecorder.Result().StatusCode)
+	var results []model.BatchResult
+	assert.NoError(suite.T(), json.Unmarshal(suite.ResponseRecorder.Body.Bytes(), &results))
+	assert.Equal(suite.T(), []model.BatchResult{
+		{Index: 0, ShortURL: "http://example.com/abc123", Code: "abc123", OriginalURL: "https://example.com"},
+		{Index: 1, OriginalURL: "javascript:alert(1)", Error: &model.Error{Code: service.CodeSchemeNotAllowed, Message: `scheme "javascript" is not allowed`}},
+		{Index: 2, OriginalURL: "https://example.com", Error: &model.Error{Code: "alias_taken", Message: "alias already in use"}},
+	}, results)
+}
+
+func (suite *HandlerTestSuite) TestShortenBatchBounds() {
+	suite.Handler.MaxBatchSize = 2
+	item := `{"original_url":"https://example.com"}`
+	huge := `[{"original_url":"https://example.com/` + strings.Repeat("a", 2*maxBatchItemBytes) + `"}]`
+	for _, tc := range []struct {
+		contentType, body string
+		status            int
+	}{
+		{restful.MIME_JSON, "[" + item + "," + item + "]", http.StatusOK},
+		{restful.MIME_JSON, "[" + item + "," + item + "," + item + "]", http.StatusRequestEntityTooLarge},
+		{restful.MIME_JSON, huge, http.StatusRequestEntityTooLarge},
+		{MIME_NDJSON, item + "\n" + item + "\n", http.StatusOK},
+		{MIME_NDJSON, item + "\n" + item + "\n" + item + "\nnot json\n", http.StatusRequestEntityTooLarge},
+	} {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/shorten/batch", strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", tc.contentType)
+		suite.Container.ServeHTTP(recorder, req)
+		assert.Equal(suite.T(), tc.status, recorder.Result().StatusCode, tc.body[:min(len(tc.body), 80)])
+		if tc.status == http.StatusRequestEntityTooLarge {
+			assert.Equal(suite.T(), "batch_too_large", errorBody(recorder).Code)
+		}
+	}
+}
+
+func (suite *HandlerTestSuite) TestReadNDJSONStopsPastLimit() {
+	body := strings.NewReader(strings.Repeat(`{"original_url":"https://example.com"}`+"\n", 10000))
+
+	_, err := readNDJSON(body, 3)
+
+	assert.ErrorIs(suite.T(), err, service.ErrBatchTooLarge)
+	assert.NotZero(suite.T(), body.Len(), "The rest of the body should not be read")
+}
+
+func (suite *HandlerTestSuite) TestShortenBatchTTLOutOfRange() {
+	body := `[{"original_url":"https://example.com","ttl_seconds":9223372036854775807},{"original_url":"https://example.com"}]`
+	req := httptest.NewRequest("POST", "/shorten/batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", restful.MIME_JSON)
+
+	suite.Container.ServeHTTP(suite.ResponseRecorder, req)
+
+	assert.Equal(suite.T(), http.StatusOK, suite.ResponseRecorder.Result().StatusCode)
+	var results []model.BatchResult
+	assert.NoError(suite.T(), json.Unmarshal(suite.ResponseRecorder.Body.Bytes(), &results))
+	assert.Equal(suite.T(), []model.BatchResult{
+		{Index: 0, OriginalURL: "https://example.com", Error: &model.Error{Code: service.CodeInvalidExpiry, Message: "ttl_seconds must be at most 9223372036"}},
+		{Index: 1, ShortURL: "http://example.com/abc123", Code: "abc123", OriginalURL: "https://example.com"},
+	}, results)
+}
+
+func (suite *HandlerTestSuite) TestShortenBatchNDJSON() {
+	body := "{\"original_url\":\"https://example.com\"}\n\n{\"original_url\":\"https://example.com\",\"alias\":\"sale\"}\n"
+	req := httptest.NewRequest("POST", "/shorten/batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", MIME_NDJSON)
+
+	suite.Container.ServeHTTP(suite.ResponseRecorder, req)
+
+	assert.Equal(suite.T(), http.StatusOK, suite.ResponseRecorder.Result().StatusCode)
+	assert.Equal(suite.T(), MIME_NDJSON, suite.ResponseRecorder.Header().Get("Content-Type"))
+	assert.Equal(suite.T(), "{\"index\":0,\"short_url\":\"http://example.com/abc123\",\"code\":\"abc123\",\"original_url\":\"https://example.com\"}\n"+
+		"{\"index\":1,\"short_url\":\"http://example.com/sale\",\"code\":\"sale\",\"original_url\":\"https://example.com\"}\n", suite.ResponseRecorder.Body.String())
+}
+
+func (suite *HandlerTestSuite) TestShortenBatchErrors() {
+	for _, tc := range []struct {
+		contentType, body string
+		status            int
+	}{
+		{restful.MIME_JSON, `{"original_url":"https://example.com"}`, http.StatusBadRequest},
+		{MIME_NDJSON, "{\"original_url\":\"https://example.com\"}\nnot json\n", http.StatusBadRequest},
+		{restful.MIME_JSON, `[{},{},{},{}]`, http.StatusRequestEntityTooLarge},
+	} {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/shorten/batch", strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", tc.contentType)
+		suite.Container.ServeHTTP(recorder, req)
+		assert.Equal(suite.T(), tc.status, recorder.Result().StatusCode, tc.body)
+	}
 }
 
 func (suite *HandlerTestSuite) TestRedirectSuccess() {
@@ -99,6 +296,29 @@ func (suite *HandlerTestSuite) TestRedirectSuccess() {
 	suite.T().Logf("Response body: %s", suite.ResponseRecorder.Body.String())
 	assert.Equal(suite.T(), http.StatusMovedPermanently, suite.ResponseRecorder.Result().StatusCode)
 	assert.Equal(suite.T(), "https://example.com", suite.ResponseRecorder.Header().Get("Location"))
+	assert.Equal(suite.T(), "public, max-age=3600", suite.ResponseRecorder.Header().Get("Cache-Control"))
+}
+
+func (suite *HandlerTestSuite) TestRedirectTemporary() {
+	req := httptest.NewRequest("GET", "/r/temp123", nil)
+
+	suite.Container.ServeHTTP(suite.ResponseRecorder, req)
+	assert.Equal(suite.T(), http.StatusFound, suite.ResponseRecorder.Result().StatusCode)
+	assert.Equal(suite.T(), "https://example.com", suite.ResponseRecorder.Header().Get("Location"))
+	assert.Equal(suite.T(), "no-store", suite.ResponseRecorder.Header().Get("Cache-Control"))
+}
+
+func (suite *HandlerTestSuite) TestRedirectRecordsClick() {
+	req := httptest.NewRequest("GET", "/r/abc123", nil)
+	req.Header.Set("Referer", "https://news.example/story")
+	req.Header.Set("User-Agent", "curl/8.0")
+
+	suite.Container.ServeHTTP(suite.ResponseRecorder, req)
+	assert.Equal(suite.T(), []click{{"abc123", "https://news.example/story", "curl/8.0"}}, suite.Service.clicks)
+
+	suite.ResponseRecorder = httptest.NewRecorder()
+	suite.Container.ServeHTTP(suite.ResponseRecorder, httptest.NewRequest("GET", "/r/invalid", nil))
+	assert.Len(suite.T(), suite.Service.clicks, 1, "Failed lookups should not be counted")
 }
 
 func (suite *HandlerTestSuite) TestRedirectNotFound() {
@@ -107,7 +327,15 @@ func (suite *HandlerTestSuite) TestRedirectNotFound() {
 	suite.Container.ServeHTTP(suite.ResponseRecorder, req)
 	suite.T().Logf("Response body: %s", suite.ResponseRecorder.Body.String())
 	assert.Equal(suite.T(), http.StatusNotFound, suite.ResponseRecorder.Result().StatusCode)
-	assert.Contains(suite.T(), suite.ResponseRecorder.Body.String(), "short URL not found")
+	assert.Equal(suite.T(), model.Error{Code: "link_not_found", Message: "short URL not found"}, errorBody(suite.ResponseRecorder))
+}
+
+func (suite *HandlerTestSuite) TestRedirectExpired() {
+	req := httptest.NewRequest("GET", "/r/expired", nil)
+
+	suite.Container.ServeHTTP(suite.ResponseRecorder, req)
+	assert.Equal(suite.T(), http.StatusGone, suite.ResponseRecorder.Result().StatusCode)
+	assert.Equal(suite.T(), "link_expired", errorBody(suite.ResponseRecorder).Code)
 }
 
 func (suite *HandlerTestSuite) TestRedirectError() {
@@ -117,11 +345,12 @@ func (suite *HandlerTestSuite) TestRedirectError() {
 	suite.Container.ServeHTTP(suite.ResponseRecorder, req)
 	suite.T().Logf("Response body: %s", suite.ResponseRecorder.Body.String())
 	assert.Equal(suite.T(), http.StatusInternalServerError, suite.ResponseRecorder.Result().StatusCode)
-	assert.Contains(suite.T(), suite.ResponseRecorder.Body.String(), "expected get original to fail")
+	assert.Equal(suite.T(), CodeInternal, errorBody(suite.ResponseRecorder).Code)
+	assert.NotContains(suite.T(), suite.ResponseRecorder.Body.String(), "expected get original to fail", "Panic details stay in the log")
 }
 
-func (suite *HandlerTestSuite) TestMetricsSuccess() {
-	req := httptest.NewRequest("GET", "/metrics", nil)
+func (suite *HandlerTestSuite) TestTopDomainsSuccess() {
+	req := httptest.NewRequest("GET", "/api/v1/analytics/top-domains", nil)
 
 	suite.Container.ServeHTTP(suite.ResponseRecorder, req)
 	var response []struct {
@@ -141,14 +370,175 @@ func (suite *HandlerTestSuite) TestMetricsSuccess() {
 	assert.Equal(suite.T(), 3, response[2].Count)
 }
 
-func (suite *HandlerTestSuite) TestMetricsError() {
+func (suite *HandlerTestSuite) TestTopDomainsLimitAndWindow() {
+	req := httptest.NewRequest("GET", "/api/v1/analytics/top-domains?limit=2&window=24h", nil)
+
+	suite.Container.ServeHTTP(suite.ResponseRecorder, req)
+	var response []service.DomainCount
+	err := json.Unmarshal(suite.ResponseRecorder.Body.Bytes(), &response)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, suite.ResponseRecorder.Result().StatusCode)
+	assert.Len(suite.T(), response, 2)
+	assert.Equal(suite.T(), 2, suite.Service.topLimit)
+	assert.Equal(suite.T(), 24*time.Hour, suite.Service.topWindow)
+}
+
+func (suite *HandlerTestSuite) TestTopDomainsBadQuery() {
+	for _, query := range []string{"limit=0", "limit=ten", "window=yesterday", "window=400h"} {
+		recorder := httptest.NewRecorder()
+		suite.Container.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/v1/analytics/top-domains?"+query, nil))
+		assert.Equal(suite.T(), http.StatusBadRequest, recorder.Result().StatusCode, query)
+	}
+}
+
+func (suite *HandlerTestSuite) TestTopDomainsError() {
 	urlGetTopDomainsFail = true
-	req := httptest.NewRequest("GET", "/metrics", nil)
+	req := httptest.NewRequest("GET", "/api/v1/analytics/top-domains", nil)
 
 	suite.Container.ServeHTTP(suite.ResponseRecorder, req)
 	suite.T().Logf("Response body: %s", suite.ResponseRecorder.Body.String())
 	assert.Equal(suite.T(), http.StatusInternalServerError, suite.ResponseRecorder.Result().StatusCode)
-	assert.Contains(suite.T(), suite.ResponseRecorder.Body.String(), "expected get top domains to fail")
+	assert.Equal(suite.T(), CodeInternal, errorBody(suite.ResponseRecorder).Code)
+	assert.NotContains(suite.T(), suite.ResponseRecorder.Body.String(), "expected get top domains to fail", "Panic details stay in the log")
+}
+
+func (suite *HandlerTestSuite) TestLinkStats() {
+	req := httptest.NewRequest("GET", "/links/abc123/stats", nil)
+
+	suite.Container.ServeHTTP(suite.ResponseRecorder, req)
+	var response model.LinkStatsResponse
+	err := json.Unmarshal(suite.ResponseRecorder.Body.Bytes(), &response)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, suite.ResponseRecorder.Result().StatusCode)
+	assert.Equal(suite.T(), "abc123", response.Short)
+	assert.Equal(suite.T(), int64(7), response.Clicks)
+	assert.Equal(suite.T(), int64(4), response.Referrers["news.example"])
+}
+
+func (suite *HandlerTestSuite) TestLinkStatsNotFound() {
+	req := httptest.NewRequest("GET", "/links/invalid/stats", nil)
+
+	suite.Container.ServeHTTP(suite.ResponseRecorder, req)
+	assert.Equal(suite.T(), http.StatusNotFound, suite.ResponseRecorder.Result().StatusCode)
+}
+
+func (suite *HandlerTestSuite) TestMetricsExposition() {
+	suite.Container.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/r/abc123", nil))
+	suite.Container.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/r/invalid", nil))
+	suite.Container.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/r/expired", nil))
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Accept", "text/plain;version=0.0.4;q=0.4,*/*;q=0.1")
+	suite.Container.ServeHTTP(suite.ResponseRecorder, req)
+
+	body := suite.ResponseRecorder.Body.String()
+	assert.Equal(suite.T(), http.StatusOK, suite.ResponseRecorder.Result().StatusCode)
+	assert.Equal(suite.T(), metrics.ContentType, suite.ResponseRecorder.Header().Get("Content-Type"))
+	assert.Contains(suite.T(), body, `http_requests_total{route="/r/{short}",method="GET",code="301"} 1`)
+	assert.Contains(suite.T(), body, `http_requests_total{route="/r/{short}",method="GET",code="404"} 1`)
+	assert.Contains(suite.T(), body, `http_request_duration_seconds_count{route="/r/{short}",method="GET"} 3`)
+	assert.Contains(suite.T(), body, `urlshortener_redirects_total{result="hit"} 1`)
+	assert.Contains(suite.T(), body, `urlshortener_redirects_total{result="miss"} 1`)
+	assert.Contains(suite.T(), body, `urlshortener_redirects_total{result="expired"} 1`)
+}
+
+func (suite *HandlerTestSuite) TestUpdateLink() {
+	req := httptest.NewRequest("PATCH", "/links/abc123", strings.NewReader(`{"original_url":"https://other.com","version":0}`))
+	req.Header.Set("Content-Type", restful.MIME_JSON)
+
+	suite.Container.ServeHTTP(suite.ResponseRecorder, req)
+	var response model.LinkResponse
+	err := json.Unmarshal(suite.ResponseRecorder.Body.Bytes(), &response)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, suite.ResponseRecorder.Result().StatusCode)
+	assert.Equal(suite.T(), "https://other.com", response.OriginalURL)
+	assert.Equal(suite.T(), int64(1), response.Version)
+}
+
+func (suite *HandlerTestSuite) TestUpdateLinkErrors() {
+	cases := []struct {
+		path, body string
+		status     int
+	}{
+		{"/links/invalid", `{"original_url":"https://other.com"}`, http.StatusNotFound},
+		{"/links/abc123", `{"original_url":"https://other.com","version":7}`, http.StatusConflict},
+		{"/links/abc123", `{"original_url":"javascript:alert(1)"}`, http.StatusBadRequest},
+		{"/links/abc123", `{bad json}`, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("PATCH", c.path, strings.NewReader(c.body))
+		req.Header.Set("Content-Type", restful.MIME_JSON)
+
+		suite.Container.ServeHTTP(recorder, req)
+		assert.Equal(suite.T(), c.status, recorder.Result().StatusCode, c.body)
+	}
+}
+
+func (suite *HandlerTestSuite) TestDeleteAndRestoreLink() {
+	suite.Container.ServeHTTP(suite.ResponseRecorder, httptest.NewRequest("DELETE", "/links/abc123?version=0", nil))
+	var response model.LinkResponse
+	err := json.Unmarshal(suite.ResponseRecorder.Body.Bytes(), &response)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, suite.ResponseRecorder.Result().StatusCode)
+	assert.False(suite.T(), response.DeletedAt.IsZero())
+	assert.False(suite.T(), response.RestorableUntil.IsZero())
+
+	recorder := httptest.NewRecorder()
+	suite.Container.ServeHTTP(recorder, httptest.NewRequest("POST", "/links/abc123/restore", nil))
+	assert.Equal(suite.T(), http.StatusOK, recorder.Result().StatusCode)
+
+	recorder = httptest.NewRecorder()
+	suite.Container.ServeHTTP(recorder, httptest.NewRequest("POST", "/links/live123/restore", nil))
+	assert.Equal(suite.T(), http.StatusConflict, recorder.Result().StatusCode)
+}
+
+func (suite *HandlerTestSuite) TestDeleteLinkErrors() {
+	cases := map[string]int{
+		"/links/invalid":           http.StatusNotFound,
+		"/links/abc123?version=7":  http.StatusConflict,
+		"/links/abc123?version=-1": http.StatusBadRequest,
+	}
+	for path, status := range cases {
+		recorder := httptest.NewRecorder()
+		suite.Container.ServeHTTP(recorder, httptest.NewRequest("DELETE", path, nil))
+		assert.Equal(suite.T(), status, recorder.Result().StatusCode, path)
+	}
+}
+
+func (suite *HandlerTestSuite) TestListLinks() {
+	req := httptest.NewRequest("GET", "/links?domain=example.com&tag=promo&owner=bob&q=sale&sort=-clicks&limit=2&cursor=abc&created_after=2025-06-01T00:00:00Z&created_before=2025-07-01T00:00:00Z", nil)
+
+	suite.Container.ServeHTTP(suite.ResponseRecorder, req)
+	var response model.LinkListResponse
+	err := json.Unmarshal(suite.ResponseRecorder.Body.Bytes(), &response)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, suite.ResponseRecorder.Result().StatusCode)
+	assert.Equal(suite.T(), service.ListOptions{
+		Domain:      "example.com",
+		Tag:         "promo",
+		Owner:       "bob",
+		Search:      "sale",
+		Sort:        storage.SortClicksDesc,
+		Cursor:      "abc",
+		Limit:       2,
+		CreatedFrom: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+		CreatedTo:   time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC),
+	}, suite.Service.listOpts)
+	assert.Len(suite.T(), response.Links, 2)
+	assert.Equal(suite.T(), "next", response.NextCursor)
+	if assert.NotNil(suite.T(), response.Links[0].Clicks) {
+		assert.Equal(suite.T(), int64(9), *response.Links[0].Clicks)
+	}
+	assert.Equal(suite.T(), []string{"promo"}, response.Links[0].Tags)
+}
+
+func (suite *HandlerTestSuite) TestListLinksBadQuery() {
+	for _, query := range []string{"limit=0", "limit=x", "created_after=yesterday", "created_before=2025", "sort=name"} {
+		recorder := httptest.NewRecorder()
+		suite.Container.ServeHTTP(recorder, httptest.NewRequest("GET", "/links?"+query, nil))
+		assert.Equal(suite.T(), http.StatusBadRequest, recorder.Result().StatusCode, query)
+	}
 }
 
 func convertToURLResponse(str string) *model.URLResponse {
@@ -160,36 +550,176 @@ func convertToURLResponse(str string) *model.URLResponse {
 	return response
 }
 
-type urlServiceMock service.URLService
+// errorBody decodes the shared error format from recorder.
+func errorBody(recorder *httptest.ResponseRecorder) model.Error {
+	var response model.ErrorResponse
+	json.Unmarshal(recorder.Body.Bytes(), &response)
+	return response.Error
+}
+
+type click struct {
+	short, referrer, userAgent string
+}
+
+type urlServiceMock struct {
+	clicks     []click
+	listCaller service.Caller
+	listOpts   service.ListOptions
+	owner      string
+	topLimit   int
+	topWindow  time.Duration
+}
 
-func (mock *urlServiceMock) ShortenURL(original string) string {
+func (mock *urlServiceMock) ShortenURL(original string, opts service.ShortenOptions) (string, error) {
 	if urlShortenFail {
 		panic(errors.New("expected shorten to fail"))
 	}
+	mock.owner = opts.Owner
+	if opts.Redirect != 0 && !service.IsRedirectStatus(opts.Redirect) {
+		return "", &service.ValidationError{Code: service.CodeInvalidRedirect, Message: "redirect status must be 301, 302, 307 or 308"}
+	}
+	if opts.Redirect == http.StatusFound {
+		return "temp123", nil
+	}
+	if opts.TTL == time.Minute && opts.ExpiresAt.IsZero() {
+		return "ttl123", nil
+	}
+	if original == "javascript:alert(1)" {
+		return "", &service.ValidationError{Code: service.CodeSchemeNotAllowed, Message: `scheme "javascript" is not allowed`}
+	}
+	switch opts.Alias {
+	case "":
+	case "taken":
+		return "", service.ErrAliasTaken
+	case "metrics":
+		return "", service.ErrReservedAlias
+	case "a":
+		return "", service.ErrInvalidAlias
+	default:
+		return opts.Alias, nil
+	}
 	if original == "" {
-		return ""
+		return "", nil
+	}
+	return "abc123", nil
+}
+
+// ShortenBatch accepts at most three items and shortens each as ShortenURL.
+func (mock *urlServiceMock) ShortenBatch(items []service.BatchItem) ([]service.BatchResult, error) {
+	if len(items) > 3 {
+		return nil, service.ErrBatchTooLarge
+	}
+	results := make([]service.BatchResult, len(items))
+	for i, item := range items {
+		results[i].Short, results[i].Err = mock.ShortenURL(item.URL, item.Options)
 	}
-	return "abc123"
+	return results, nil
 }
 
-func (mock *urlServiceMock) GetOriginalURL(short string) (string, bool) {
+func (mock *urlServiceMock) Resolve(short string) (service.Target, error) {
 	if urlGetOriginalFail {
 		panic(errors.New("expected get original to fail"))
 	}
-	if short == "invalid" {
-		return "", false
+	switch short {
+	case "invalid":
+		return service.Target{}, service.ErrLinkNotFound
+	case "expired":
+		return service.Target{}, service.ErrLinkExpired
+	case "temp123":
+		return service.Target{URL: "https://example.com", Status: http.StatusFound}, nil
 	}
-	return "https://example.com", true
+	return service.Target{URL: "https://example.com", Status: http.StatusMovedPermanently, MaxAge: time.Hour}, nil
 }
 
-func (mock *urlServiceMock) GetTopDomains(limit int) map[string]int {
+func (mock *urlServiceMock) GetTopDomains(limit int, window time.Duration) ([]service.DomainCount, error) {
 	if urlGetTopDomainsFail {
-		panic(errors.New("expected get top domains to fail"))
+		panic("expected get top domains to fail")
+	}
+	if window > service.MaxDomainWindow {
+		return nil, service.ErrInvalidWindow
+	}
+	mock.topLimit, mock.topWindow = limit, window
+	domains := []service.DomainCount{
+		{Domain: "example.com", Count: 10},
+		{Domain: "test.com", Count: 5},
+		{Domain: "other.com", Count: 3},
+		{Domain: "more.com", Count: 1},
 	}
-	return map[string]int{
-		"example.com": 10,
-		"test.com":    5,
-		"other.com":   3,
-		"more.com":    1,
+	if limit < len(domains) {
+		domains = domains[:limit]
+	}
+	return domains, nil
+}
+
+func (mock *urlServiceMock) RecordClick(short, referrer, userAgent string) {
+	mock.clicks = append(mock.clicks, click{short, referrer, userAgent})
+}
+
+func (mock *urlServiceMock) GetLinkStats(caller service.Caller, short string) (storage.LinkStats, error) {
+	if short == "invalid" {
+		return storage.LinkStats{}, service.ErrLinkNotFound
+	}
+	at := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	return storage.LinkStats{
+		Clicks:     7,
+		FirstClick: at,
+		LastClick:  at.Add(time.Hour),
+		Referrers:  map[string]int64{"news.example": 4, storage.NoneValue: 3},
+		UserAgents: map[string]int64{"curl/8.0": 7},
+	}, nil
+}
+
+// mockLink returns the link the mock pretends is stored under short at
+// version 0.
+func mockLink(short string, version int64) (storage.Link, error) {
+	switch {
+	case short == "invalid":
+		return storage.Link{}, service.ErrLinkNotFound
+	case version != service.AnyVersion && version != 0:
+		return storage.Link{}, service.ErrVersionConflict
+	}
+	return storage.Link{Short: short, Original: "https://example.com", CreatedAt: time.Now()}, nil
+}
+
+func (mock *urlServiceMock) UpdateLink(caller service.Caller, short, original string, version int64) (storage.Link, error) {
+	link, err := mockLink(short, version)
+	if err != nil {
+		return link, err
+	}
+	if original == "javascript:alert(1)" {
+		return storage.Link{}, &service.ValidationError{Code: service.CodeSchemeNotAllowed, Message: `scheme "javascript" is not allowed`}
+	}
+	link.Original = original
+	link.Version++
+	return link, nil
+}
+
+func (mock *urlServiceMock) DeleteLink(caller service.Caller, short string, version int64) (storage.Link, error) {
+	link, err := mockLink(short, version)
+	if err != nil {
+		return link, err
+	}
+	link.DeletedAt = time.Now()
+	link.PurgeAt = link.DeletedAt.Add(service.DefaultRestoreWindow)
+	link.Version++
+	return link, nil
+}
+
+func (mock *urlServiceMock) RestoreLink(caller service.Caller, short string) (storage.Link, error) {
+	if short == "live123" {
+		return storage.Link{}, service.ErrLinkNotDeleted
+	}
+	return mockLink(short, service.AnyVersion)
+}
+
+func (mock *urlServiceMock) ListLinks(caller service.Caller, opts service.ListOptions) (service.LinkListing, error) {
+	mock.listCaller, mock.listOpts = caller, opts
+	if opts.Sort != "" && !opts.Sort.Valid() {
+		return service.LinkListing{}, &service.ValidationError{Code: service.CodeInvalidQuery, Message: "bad sort"}
 	}
+	link := storage.Link{Short: "abc123", Original: "https://example.com/sale", Tags: []string{"promo"}, Owner: "bob"}
+	return service.LinkListing{
+		Links:      []service.LinkSummary{{Link: link, Clicks: 9}, {Link: link, Clicks: 3}},
+		NextCursor: "next",
+	}, nil
 }
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"net/http"
+
+	"url-shortener/internal/health"
+	"url-shortener/model"
+
+	restful "github.com/emicklei/go-restful/v3"
+)
+
+// Values of model.HealthResponse.
+const (
+	healthOK          = "ok"
+	healthUnavailable = "unavailable"
+	checkFailed       = "failed"
+)
+
+// HealthService returns the probe routes alone, for serving while the rest
+// of the API is still starting. Register adds the same routes.
+func HealthService(registry *health.Registry) *restful.WebService {
+	ws := new(restful.WebService)
+	ws.Path("/").Produces(restful.MIME_JSON)
+	addProbes(ws, probes{registry})
+	return ws
+}
+
+func addProbes(ws *restful.WebService, p probes) {
+	ws.Route(ws.GET("/healthz").Operation("Live").To(p.Live).
+		Doc("Liveness probe").
+		Returns(http.StatusOK, "The process is serving", model.HealthResponse{}))
+	ws.Route(ws.GET("/readyz").Operation("Ready").To(p.Ready).
+		Doc("Readiness probe").
+		Notes("Fails while stores are opened, when a store is unhealthy and once shutdown has begun.").
+		Returns(http.StatusOK, "Ready for traffic", model.HealthResponse{}).
+		Returns(http.StatusServiceUnavailable, "Not ready; checks says which check failed", model.HealthResponse{}))
+}
+
+// probes serves the liveness and readiness endpoints.
+type probes struct {
+	registry *health.Registry
+}
+
+// Live answers the liveness probe. It succeeds whenever the process can
+// serve HTTP at all: a failing store makes the instance unready, but
+// restarting it would not help.
+func (p probes) Live(req *restful.Request, resp *restful.Response) {
+	resp.WriteHeaderAndJson(http.StatusOK, model.HealthResponse{Status: healthOK}, restful.MIME_JSON)
+}
+
+// Ready answers the readiness probe with the outcome of every registered
+// check. Failures are logged rather than returned, since the endpoint is
+// public and check errors name files and addresses.
+func (p probes) Ready(req *restful.Request, resp *restful.Response) {
+	report := p.registry.Run(req.Request.Context())
+	status, body := http.StatusOK, model.HealthResponse{Status: healthOK, Checks: make(map[string]string, len(report))}
+	for _, res := range report {
+		if res.Err == nil {
+			body.Checks[res.Name] = healthOK
+			continue
+		}
+		status, body.Status = http.StatusServiceUnavailable, healthUnavailable
+		body.Checks[res.Name] = checkFailed
+		requestLogger(req).Warn("readiness check failed", "check", res.Name, "err", res.Err)
+	}
+	resp.WriteHeaderAndJson(status, body, restful.MIME_JSON)
+}
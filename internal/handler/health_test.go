@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"url-shortener/internal/auth"
+	"url-shortener/internal/health"
+	"url-shortener/model"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type HealthTestSuite struct {
+	suite.Suite
+	Handler   *Handler
+	Container *restful.Container
+}
+
+func TestHealthTestSuite(t *testing.T) {
+	suite.Run(t, new(HealthTestSuite))
+}
+
+func (suite *HealthTestSuite) SetupTest() {
+	suite.Handler = NewHandler(&urlServiceMock{}, auth.NewKeyring())
+	suite.Handler.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	suite.Container = restful.NewContainer()
+	suite.Handler.Register(suite.Container)
+}
+
+func (suite *HealthTestSuite) get(container *restful.Container, path string) (int, model.HealthResponse) {
+	recorder := httptest.NewRecorder()
+	container.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+	var body model.HealthResponse
+	require.NoError(suite.T(), json.Unmarshal(recorder.Body.Bytes(), &body))
+	return recorder.Code, body
+}
+
+func (suite *HealthTestSuite) TestReady() {
+	code, body := suite.get(suite.Container, "/readyz")
+	assert.Equal(suite.T(), http.StatusOK, code)
+	assert.Equal(suite.T(), model.HealthResponse{Status: "ok", Checks: map[string]string{"shutdown": "ok"}}, body)
+}
+
+func (suite *HealthTestSuite) TestNotReadyHidesErrors() {
+	suite.Handler.Health.Register("storage.wal", func(context.Context) error {
+		return errors.New("storage: append wal: /var/lib/url-shortener/wal.log: no space left on device")
+	})
+
+	recorder := httptest.NewRecorder()
+	suite.Container.ServeHTTP(recorder, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(suite.T(), http.StatusServiceUnavailable, recorder.Code)
+	assert.NotContains(suite.T(), recorder.Body.String(), "no space left")
+
+	_, body := suite.get(suite.Container, "/readyz")
+	assert.Equal(suite.T(), "unavailable", body.Status)
+	assert.Equal(suite.T(), map[string]string{"shutdown": "ok", "storage.wal": "failed"}, body.Checks)
+}
+
+func (suite *HealthTestSuite) TestDrainingIsNotReadyButLive() {
+	suite.Handler.Health.Drain()
+
+	code, body := suite.get(suite.Container, "/readyz")
+	assert.Equal(suite.T(), http.StatusServiceUnavailable, code)
+	assert.Equal(suite.T(), "failed", body.Checks["shutdown"])
+
+	code, body = suite.get(suite.Container, "/healthz")
+	assert.Equal(suite.T(), http.StatusOK, code)
+	assert.Equal(suite.T(), "ok", body.Status)
+}
+
+func (suite *HealthTestSuite) TestProbesNeedNoKey() {
+	for _, path := range []string{"/healthz", "/readyz"} {
+		code, _ := suite.get(suite.Container, path)
+		assert.Equal(suite.T(), http.StatusOK, code, path)
+	}
+}
+
+func (suite *HealthTestSuite) TestHealthServiceAlone() {
+	registry := health.NewRegistry()
+	done := registry.Pending("startup", "replaying logs")
+	container := restful.NewContainer()
+	container.Add(HealthService(registry))
+
+	code, _ := suite.get(container, "/healthz")
+	assert.Equal(suite.T(), http.StatusOK, code, "The process is live while it starts")
+	code, body := suite.get(container, "/readyz")
+	assert.Equal(suite.T(), http.StatusServiceUnavailable, code)
+	assert.Equal(suite.T(), "failed", body.Checks["startup"])
+
+	done()
+	code, _ = suite.get(container, "/readyz")
+	assert.Equal(suite.T(), http.StatusOK, code)
+}
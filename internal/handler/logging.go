@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"url-shortener/internal/ratelimit"
+
+	restful "github.com/emicklei/go-restful/v3"
+)
+
+const (
+	// requestIDHeader carries the request ID in both directions.
+	requestIDHeader = "X-Request-ID"
+	// maxRequestID bounds the request IDs accepted from clients.
+	maxRequestID = 128
+	// requestIDAttribute and loggerAttribute are the request attributes
+	// RequestID stores the request's ID and logger under.
+	requestIDAttribute = "requestID"
+	loggerAttribute    = "logger"
+)
+
+// RequestID is a container filter giving every request an ID: the client's
+// X-Request-ID when it sent a usable one, else a random one. The ID is
+// echoed in the response header, in error bodies and in every log record
+// about the request.
+func (h *Handler) RequestID(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+	id := req.HeaderParameter(requestIDHeader)
+	if !validRequestID(id) {
+		id = newRequestID()
+	}
+	req.SetAttribute(requestIDAttribute, id)
+	req.SetAttribute(loggerAttribute, h.Logger.With("request_id", id))
+	resp.AddHeader(requestIDHeader, id)
+	chain.ProcessFilter(req, resp)
+}
+
+// validRequestID accepts IDs of printable ASCII without spaces, so they are
+// safe to log and echo.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestID {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// requestID returns the ID RequestID gave req.
+func requestID(req *restful.Request) string {
+	id, _ := req.Attribute(requestIDAttribute).(string)
+	return id
+}
+
+// requestLogger returns the logger for records about req.
+func requestLogger(req *restful.Request) *slog.Logger {
+	if logger, ok := req.Attribute(loggerAttribute).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// AccessLog is a container filter logging one record per request with its
+// route template, status, latency and response size. Query strings are
+// left out since they may carry secrets. Server errors are logged at error
+// level, everything else at info.
+func (h *Handler) AccessLog(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+	start := time.Now()
+	chain.ProcessFilter(req, resp)
+	level := slog.LevelInfo
+	if resp.StatusCode() >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	requestLogger(req).LogAttrs(req.Request.Context(), level, "request",
+		slog.String("method", req.Request.Method),
+		slog.String("route", req.SelectedRoutePath()),
+		slog.String("path", req.Request.URL.Path),
+		slog.Int("status", resp.StatusCode()),
+		slog.Duration("latency", time.Since(start)),
+		slog.Int("bytes", resp.ContentLength()),
+		slog.String("client", ratelimit.ClientIP(req.Request, h.TrustedProxies)),
+	)
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"url-shortener/internal/auth"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type LoggingTestSuite struct {
+	suite.Suite
+	Log       *bytes.Buffer
+	Container *restful.Container
+	Key       string
+}
+
+func TestLoggingTestSuite(t *testing.T) {
+	suite.Run(t, new(LoggingTestSuite))
+}
+
+func (suite *LoggingTestSuite) SetupTest() {
+	keys := auth.NewKeyring()
+	var err error
+	suite.Key, _, err = keys.Create("marketing", auth.RoleUser, "")
+	require.NoError(suite.T(), err)
+
+	suite.Log = new(bytes.Buffer)
+	h := NewHandler(&urlServiceMock{}, keys)
+	h.Logger = slog.New(slog.NewJSONHandler(suite.Log, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	suite.Container = restful.NewContainer()
+	h.Register(suite.Container)
+}
+
+// records decodes the JSON log lines written so far.
+func (suite *LoggingTestSuite) records() []map[string]any {
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(suite.Log.String()), "\n") {
+		var record map[string]any
+		require.NoError(suite.T(), json.Unmarshal([]byte(line), &record), line)
+		records = append(records, record)
+	}
+	return records
+}
+
+func (suite *LoggingTestSuite) TestRequestIDPropagated() {
+	req := httptest.NewRequest("GET", "/r/abc123", nil)
+	req.Header.Set("X-Request-ID", "edge-7f3a")
+	recorder := httptest.NewRecorder()
+
+	suite.Container.ServeHTTP(recorder, req)
+
+	assert.Equal(suite.T(), "edge-7f3a", recorder.Header().Get("X-Request-ID"))
+	records := suite.records()
+	require.Len(suite.T(), records, 1)
+	assert.Equal(suite.T(), "edge-7f3a", records[0]["request_id"])
+}
+
+func (suite *LoggingTestSuite) TestRequestIDGenerated() {
+	for _, sent := range []string{"", "has spaces", strings.Repeat("x", maxRequestID+1)} {
+		req := httptest.NewRequest("GET", "/nope/nope", nil)
+		req.Header.Set("X-Request-ID", sent)
+		recorder := httptest.NewRecorder()
+
+		suite.Container.ServeHTTP(recorder, req)
+
+		id := recorder.Header().Get("X-Request-ID")
+		assert.Len(suite.T(), id, 32, sent)
+		assert.Equal(suite.T(), id, errorBody(recorder).RequestID, "Unmatched routes are still tagged")
+	}
+}
+
+func (suite *LoggingTestSuite) TestAccessLog() {
+	req := httptest.NewRequest("GET", "/r/abc123?utm_source=mail", nil)
+	recorder := httptest.NewRecorder()
+
+	suite.Container.ServeHTTP(recorder, req)
+
+	records := suite.records()
+	require.Len(suite.T(), records, 1)
+	record := records[0]
+	assert.Equal(suite.T(), "request", record["msg"])
+	assert.Equal(suite.T(), "INFO", record["level"])
+	assert.Equal(suite.T(), "GET", record["method"])
+	assert.Equal(suite.T(), "/r/{short}", record["route"])
+	assert.Equal(suite.T(), "/r/abc123", record["path"])
+	assert.EqualValues(suite.T(), http.StatusMovedPermanently, record["status"])
+	assert.EqualValues(suite.T(), recorder.Body.Len(), record["bytes"])
+	assert.Contains(suite.T(), record, "latency")
+	assert.Equal(suite.T(), "192.0.2.1", record["client"])
+	assert.NotContains(suite.T(), suite.Log.String(), "utm_source")
+}
+
+func (suite *LoggingTestSuite) TestDestinationQueryRedacted() {
+	req := httptest.NewRequest("POST", "/shorten", strings.NewReader(`{"original_url":"https://example.com/reset?token=s3cret"}`))
+	req.Header.Set("Content-Type", restful.MIME_JSON)
+	req.Header.Set("Authorization", "Bearer "+suite.Key)
+
+	suite.Container.ServeHTTP(httptest.NewRecorder(), req)
+
+	records := suite.records()
+	require.Len(suite.T(), records, 2)
+	assert.Equal(suite.T(), "shortened", records[0]["msg"])
+	assert.Equal(suite.T(), "https://example.com/reset?REDACTED", records[0]["url"])
+	assert.NotContains(suite.T(), suite.Log.String(), "s3cret")
+}
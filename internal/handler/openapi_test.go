@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"url-shortener/internal/auth"
+	"url-shortener/internal/health"
+	"url-shortener/internal/openapi"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type OpenAPITestSuite struct {
+	suite.Suite
+	Container *restful.Container
+}
+
+func TestOpenAPITestSuite(t *testing.T) {
+	suite.Run(t, new(OpenAPITestSuite))
+}
+
+func (suite *OpenAPITestSuite) SetupTest() {
+	suite.Container = restful.NewContainer()
+	NewHandler(&urlServiceMock{}, auth.NewKeyring()).Register(suite.Container)
+}
+
+func (suite *OpenAPITestSuite) spec() *openapi.Document {
+	recorder := httptest.NewRecorder()
+	suite.Container.ServeHTTP(recorder, httptest.NewRequest("GET", "/openapi.json", nil))
+	require.Equal(suite.T(), http.StatusOK, recorder.Code)
+	var doc openapi.Document
+	require.NoError(suite.T(), json.Unmarshal(recorder.Body.Bytes(), &doc))
+	return &doc
+}
+
+func (suite *OpenAPITestSuite) TestEveryRouteDocumented() {
+	assert.NoError(suite.T(), openapi.Check(suite.Container.RegisteredWebServices()))
+	assert.NoError(suite.T(), openapi.Check([]*restful.WebService{HealthService(health.NewRegistry())}))
+}
+
+func (suite *OpenAPITestSuite) TestEveryRouteServed() {
+	doc := suite.spec()
+	for _, ws := range suite.Container.RegisteredWebServices() {
+		for _, route := range ws.Routes() {
+			assert.NotNil(suite.T(), doc.Paths[route.Path][strings.ToLower(route.Method)], "%s %s", route.Method, route.Path)
+		}
+	}
+}
+
+func (suite *OpenAPITestSuite) TestShortenDocumented() {
+	doc := suite.spec()
+	op := doc.Paths["/shorten"]["post"]
+	require.NotNil(suite.T(), op)
+	assert.Equal(suite.T(), "#/components/schemas/URLRequest", op.RequestBody.Content[restful.MIME_JSON].Schema.Ref)
+	assert.Equal(suite.T(), "#/components/schemas/URLResponse", op.Responses["200"].Content[restful.MIME_JSON].Schema.Ref)
+	assert.NotEmpty(suite.T(), op.Security, "Shortening needs an API key")
+	assert.Contains(suite.T(), doc.Components.Schemas["URLRequest"].Required, "original_url")
+
+	tenant := doc.Paths["/t/{tenant}/shorten"]["post"]
+	require.NotNil(suite.T(), tenant)
+	assert.Equal(suite.T(), "TenantShorten", tenant.OperationID)
+	assert.Equal(suite.T(), "tenant", tenant.Parameters[0].Name)
+}
+
+func (suite *OpenAPITestSuite) TestTopDomainsIsAnArray() {
+	schema := suite.spec().Paths["/api/v1/analytics/top-domains"]["get"].Responses["200"].Content[restful.MIME_JSON].Schema
+	assert.Equal(suite.T(), "array", schema.Type)
+	assert.Equal(suite.T(), "#/components/schemas/DomainCount", schema.Items.Ref)
+}
+
+func (suite *OpenAPITestSuite) TestPublicRoutesNeedNoKey() {
+	doc := suite.spec()
+	for _, path := range []string{"/{short}", "/r/{short}", "/healthz", "/readyz", "/openapi.json"} {
+		op := doc.Paths[path]["get"]
+		require.NotNil(suite.T(), op, path)
+		assert.Empty(suite.T(), op.Security, path)
+	}
+}
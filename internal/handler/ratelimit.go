@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"url-shortener/internal/ratelimit"
+
+	restful "github.com/emicklei/go-restful/v3"
+)
+
+// RateLimits are the per-client budgets RateLimit enforces. A nil limiter
+// leaves its routes unlimited.
+type RateLimits struct {
+	// Shorten covers POST /shorten and POST /shorten/batch.
+	Shorten *ratelimit.Limiter
+	// Redirect covers GET /r/{short} and GET /{short}.
+	Redirect *ratelimit.Limiter
+}
+
+// budget returns the limiter for a route and its name for metrics.
+func (l RateLimits) budget(method, route string) (*ratelimit.Limiter, string) {
+	route = strings.TrimPrefix(route, tenantPrefix)
+	switch {
+	case method == http.MethodPost && (route == "/shorten" || route == "/shorten/batch"):
+		return l.Shorten, "shorten"
+	case method == http.MethodGet && (route == "/r/{short}" || route == "/{short}"):
+		return l.Redirect, "redirect"
+	}
+	return nil, ""
+}
+
+// RateLimit is a container filter charging each request to a per-client
+// token bucket. Clients are told their budget in RateLimit-* headers and
+// get 429 with Retry-After once it runs out. Clients are identified by a
+// valid API key, falling back to their IP address.
+func (h *Handler) RateLimit(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+	limiter, name := h.RateLimits.budget(req.Request.Method, req.SelectedRoutePath())
+	if limiter == nil {
+		chain.ProcessFilter(req, resp)
+		return
+	}
+
+	d := limiter.Allow(h.client(req.Request))
+	limit := limiter.Limit()
+	resp.AddHeader("RateLimit-Policy", strconv.Itoa(limit.Burst)+";w="+seconds(time.Duration(float64(limit.Burst)/limit.Rate*float64(time.Second))))
+	resp.AddHeader("RateLimit-Limit", strconv.Itoa(d.Limit))
+	resp.AddHeader("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+	resp.AddHeader("RateLimit-Reset", seconds(d.Reset))
+	if !d.Allowed {
+		h.limited.With(name).Inc()
+		resp.AddHeader("Retry-After", seconds(d.RetryAfter))
+		writeErrorCode(req, resp, http.StatusTooManyRequests, CodeRateLimited, "rate limit exceeded")
+		return
+	}
+	chain.ProcessFilter(req, resp)
+}
+
+// client names the bucket a request is charged to.
+func (h *Handler) client(r *http.Request) string {
+	if key, ok := h.Keys.Authenticate(apiKey(r)); ok {
+		return "key:" + key.ID
+	}
+	return "ip:" + ratelimit.ClientIP(r, h.TrustedProxies)
+}
+
+// seconds rounds d up to whole seconds, as the headers require.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
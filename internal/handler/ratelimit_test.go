@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"url-shortener/internal/auth"
+	"url-shortener/internal/ratelimit"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type RateLimitTestSuite struct {
+	suite.Suite
+	Handler   *Handler
+	Container *restful.Container
+	Key       string
+}
+
+func TestRateLimitTestSuite(t *testing.T) {
+	suite.Run(t, new(RateLimitTestSuite))
+}
+
+func (suite *RateLimitTestSuite) SetupTest() {
+	keys := auth.NewKeyring()
+	var err error
+	suite.Key, _, err = keys.Create("marketing", auth.RoleUser, "")
+	require.NoError(suite.T(), err)
+
+	suite.Handler = NewHandler(&urlServiceMock{}, keys)
+	suite.Handler.RateLimits = RateLimits{
+		Shorten:  ratelimit.New(ratelimit.Limit{Rate: 1, Burst: 2}),
+		Redirect: ratelimit.New(ratelimit.Limit{Rate: 1, Burst: 1}),
+	}
+	suite.Handler.TrustedProxies, err = ratelimit.ParseTrustedProxies([]string{"10.0.0.1"})
+	require.NoError(suite.T(), err)
+	suite.Container = restful.NewContainer()
+	suite.Handler.Register(suite.Container)
+}
+
+func (suite *RateLimitTestSuite) shorten(key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/shorten", strings.NewReader(`{"original_url":"https://example.com"}`))
+	req.Header.Set("Content-Type", restful.MIME_JSON)
+	req.Header.Set("Authorization", "Bearer "+key)
+	recorder := httptest.NewRecorder()
+	suite.Container.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func (suite *RateLimitTestSuite) redirect(remote, forwarded string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/r/abc123", nil)
+	req.RemoteAddr = remote
+	if forwarded != "" {
+		req.Header.Set("X-Forwarded-For", forwarded)
+	}
+	recorder := httptest.NewRecorder()
+	suite.Container.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func (suite *RateLimitTestSuite) TestShortenBudgetPerKey() {
+	first := suite.shorten(suite.Key)
+	assert.Equal(suite.T(), http.StatusOK, first.Code)
+	assert.Equal(suite.T(), "2", first.Header().Get("RateLimit-Limit"))
+	assert.Equal(suite.T(), "1", first.Header().Get("RateLimit-Remaining"))
+	assert.Equal(suite.T(), "2;w=2", first.Header().Get("RateLimit-Policy"))
+	assert.Equal(suite.T(), http.StatusOK, suite.shorten(suite.Key).Code)
+
+	limited := suite.shorten(suite.Key)
+
+	assert.Equal(suite.T(), http.StatusTooManyRequests, limited.Code)
+	assert.Equal(suite.T(), "1", limited.Header().Get("Retry-After"))
+	assert.Equal(suite.T(), "0", limited.Header().Get("RateLimit-Remaining"))
+	assert.Equal(suite.T(), http.StatusMovedPermanently, suite.redirect("192.0.2.1:1234", "").Code, "Redirects have their own budget")
+}
+
+func (suite *RateLimitTestSuite) TestRedirectBudgetPerClientIP() {
+	assert.Equal(suite.T(), http.StatusMovedPermanently, suite.redirect("10.0.0.1:80", "198.51.100.1").Code)
+	assert.Equal(suite.T(), http.StatusTooManyRequests, suite.redirect("10.0.0.1:80", "198.51.100.1").Code)
+	assert.Equal(suite.T(), http.StatusMovedPermanently, suite.redirect("10.0.0.1:80", "198.51.100.2").Code, "Clients behind a trusted proxy are told apart")
+	assert.Equal(suite.T(), http.StatusMovedPermanently, suite.redirect("192.0.2.1:1234", "198.51.100.1").Code)
+	assert.Equal(suite.T(), http.StatusTooManyRequests, suite.redirect("192.0.2.1:1234", "198.51.100.9").Code, "Untrusted peers cannot spoof X-Forwarded-For")
+}
+
+func (suite *RateLimitTestSuite) TestOtherRoutesAreNotLimited() {
+	for range 3 {
+		req := httptest.NewRequest("GET", "/links", nil)
+		req.Header.Set("Authorization", "Bearer "+suite.Key)
+		recorder := httptest.NewRecorder()
+		suite.Container.ServeHTTP(recorder, req)
+		assert.Equal(suite.T(), http.StatusOK, recorder.Code)
+		assert.Empty(suite.T(), recorder.Header().Get("RateLimit-Limit"))
+	}
+}
+
+func (suite *RateLimitTestSuite) TestRootRedirectSharesBudget() {
+	assert.Equal(suite.T(), http.StatusMovedPermanently, suite.redirect("192.0.2.1:1234", "").Code)
+
+	req := httptest.NewRequest("GET", "/abc123", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	recorder := httptest.NewRecorder()
+	suite.Container.ServeHTTP(recorder, req)
+
+	assert.Equal(suite.T(), http.StatusTooManyRequests, recorder.Code)
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"url-shortener/internal/ratelimit"
+)
+
+// baseURL is the public address the short links of ns are served at, without
+// a trailing slash: the tenant's own domain, else the host a trusted proxy
+// says the client asked for, else Handler.BaseURL, else the request's Host.
+// Tenants without a domain of their own are reached under /t/{tenant}.
+func (h *Handler) baseURL(r *http.Request, ns Namespace) string {
+	if ns.Tenant.BaseURL != "" {
+		return strings.TrimSuffix(ns.Tenant.BaseURL, "/")
+	}
+	base := strings.TrimSuffix(h.BaseURL, "/")
+	if host, scheme := h.forwarded(r); host != "" {
+		base = scheme + "://" + host
+	} else if base == "" {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		base = scheme + "://" + r.Host
+	}
+	if ns.Tenant.ID != "" {
+		base += "/t/" + ns.Tenant.ID
+	}
+	return base
+}
+
+// forwarded returns the host and scheme of the client's original request
+// from X-Forwarded-Host and X-Forwarded-Proto, or "" when r did not come
+// from a trusted proxy or names no usable host. With several proxies the
+// first entry is the one facing the client.
+func (h *Handler) forwarded(r *http.Request) (host, scheme string) {
+	if !ratelimit.TrustedPeer(r, h.TrustedProxies) {
+		return "", ""
+	}
+	host, _, _ = strings.Cut(r.Header.Get("X-Forwarded-Host"), ",")
+	host = strings.TrimSpace(host)
+	if host == "" || strings.ContainsAny(host, "/\\@?# ") {
+		return "", ""
+	}
+	scheme, _, _ = strings.Cut(r.Header.Get("X-Forwarded-Proto"), ",")
+	scheme = strings.ToLower(strings.TrimSpace(scheme))
+	if scheme != "https" {
+		scheme = "http"
+	}
+	return host, scheme
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"url-shortener/internal/auth"
+	"url-shortener/internal/ratelimit"
+	"url-shortener/internal/tenant"
+	"url-shortener/model"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type ShortURLTestSuite struct {
+	suite.Suite
+	Service   *urlServiceMock
+	Handler   *Handler
+	Container *restful.Container
+	Key       string
+}
+
+func TestShortURLTestSuite(t *testing.T) {
+	suite.Run(t, new(ShortURLTestSuite))
+}
+
+func (suite *ShortURLTestSuite) SetupTest() {
+	keys := auth.NewKeyring()
+	var err error
+	suite.Key, _, err = keys.Create("marketing", auth.RoleAdmin, "")
+	require.NoError(suite.T(), err)
+
+	suite.Service = &urlServiceMock{}
+	suite.Handler = NewHandler(suite.Service, keys)
+	suite.Handler.Tenants.Add(tenant.Tenant{ID: "beta"}, &urlServiceMock{})
+	suite.Handler.TrustedProxies, err = ratelimit.ParseTrustedProxies([]string{"10.0.0.1"})
+	require.NoError(suite.T(), err)
+	suite.Container = restful.NewContainer()
+	suite.Handler.Register(suite.Container)
+}
+
+func (suite *ShortURLTestSuite) shorten(target string, prepare func(*http.Request)) model.URLResponse {
+	req := httptest.NewRequest("POST", target, strings.NewReader(`{"original_url":"https://example.com"}`))
+	req.Header.Set("Content-Type", restful.MIME_JSON)
+	req.Header.Set("Authorization", "Bearer "+suite.Key)
+	if prepare != nil {
+		prepare(req)
+	}
+	recorder := httptest.NewRecorder()
+	suite.Container.ServeHTTP(recorder, req)
+	require.Equal(suite.T(), http.StatusOK, recorder.Code, recorder.Body.String())
+
+	var response model.URLResponse
+	require.NoError(suite.T(), json.Unmarshal(recorder.Body.Bytes(), &response))
+	return response
+}
+
+func (suite *ShortURLTestSuite) TestFromRequestHost() {
+	response := suite.shorten("/shorten", func(r *http.Request) {
+		r.Host = "localhost:8080"
+	})
+
+	assert.Equal(suite.T(), model.URLResponse{
+		ShortURL:    "http://localhost:8080/abc123",
+		Code:        "abc123",
+		OriginalURL: "https://example.com",
+	}, response)
+	assert.Equal(suite.T(), "https://sho.rt/abc123", suite.shorten("/shorten", func(r *http.Request) {
+		r.Host = "sho.rt"
+		r.TLS = &tls.ConnectionState{}
+	}).ShortURL)
+}
+
+func (suite *ShortURLTestSuite) TestConfiguredBaseURL() {
+	suite.Handler.BaseURL = "https://sho.rt/"
+
+	assert.Equal(suite.T(), "https://sho.rt/abc123", suite.shorten("/shorten", nil).ShortURL)
+	assert.Equal(suite.T(), "https://sho.rt/t/beta/abc123", suite.shorten("/t/beta/shorten", nil).ShortURL)
+}
+
+func (suite *ShortURLTestSuite) TestForwardedHeaders() {
+	suite.Handler.BaseURL = "https://sho.rt"
+	forwarded := func(remote string) func(*http.Request) {
+		return func(r *http.Request) {
+			r.RemoteAddr = remote
+			r.Header.Set("X-Forwarded-Host", "links.example.org, proxy.internal")
+			r.Header.Set("X-Forwarded-Proto", "https")
+		}
+	}
+
+	assert.Equal(suite.T(), "https://links.example.org/abc123", suite.shorten("/shorten", forwarded("10.0.0.1:1234")).ShortURL)
+	assert.Equal(suite.T(), "https://sho.rt/abc123", suite.shorten("/shorten", forwarded("203.0.113.7:1234")).ShortURL,
+		"Untrusted clients cannot pick the host")
+	assert.Equal(suite.T(), "https://sho.rt/abc123", suite.shorten("/shorten", func(r *http.Request) {
+		r.RemoteAddr = "10.0.0.1:1234"
+		r.Header.Set("X-Forwarded-Host", "evil.example/phish?")
+	}).ShortURL)
+}
+
+func (suite *ShortURLTestSuite) TestRootRedirect() {
+	for _, target := range []string{"/abc123", "/r/abc123", "/t/beta/abc123"} {
+		recorder := httptest.NewRecorder()
+		suite.Container.ServeHTTP(recorder, httptest.NewRequest("GET", target, nil))
+
+		assert.Equal(suite.T(), http.StatusMovedPermanently, recorder.Code, target)
+		assert.Equal(suite.T(), "https://example.com", recorder.Header().Get("Location"), target)
+	}
+	assert.Len(suite.T(), suite.Service.clicks, 2)
+}
+
+func (suite *ShortURLTestSuite) TestRootRedirectLeavesRoutesAlone() {
+	req := httptest.NewRequest("GET", "/links", nil)
+	req.Header.Set("Authorization", "Bearer "+suite.Key)
+	recorder := httptest.NewRecorder()
+
+	suite.Container.ServeHTTP(recorder, req)
+
+	assert.Equal(suite.T(), http.StatusOK, recorder.Code)
+	assert.Empty(suite.T(), suite.Service.clicks)
+}
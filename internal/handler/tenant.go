@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"url-shortener/internal/auth"
+	"url-shortener/internal/tenant"
+
+	restful "github.com/emicklei/go-restful/v3"
+)
+
+const (
+	// tenantPrefix roots the routes that act in a tenant named in the path.
+	tenantPrefix = "/t/{tenant}"
+	// namespaceAttribute is the request attribute SelectTenant stores the
+	// selected Namespace under.
+	namespaceAttribute = "namespace"
+)
+
+// Namespace is a tenant and the service holding its links.
+type Namespace struct {
+	Tenant  tenant.Tenant
+	Service URLService
+}
+
+// Tenants maps tenant IDs and hosts to namespaces. Requests that select no
+// tenant use Handler.URLService.
+type Tenants struct {
+	byID   map[string]Namespace
+	byHost map[string]string
+}
+
+// Add serves t from svc.
+func (t *Tenants) Add(tn tenant.Tenant, svc URLService) {
+	if t.byID == nil {
+		t.byID = make(map[string]Namespace)
+		t.byHost = make(map[string]string)
+	}
+	t.byID[tn.ID] = Namespace{Tenant: tn, Service: svc}
+	if host := tn.Host(); host != "" {
+		t.byHost[host] = tn.ID
+	}
+}
+
+// Exists reports whether id names a configured tenant.
+func (t *Tenants) Exists(id string) bool {
+	_, exists := t.byID[id]
+	return exists
+}
+
+// SelectTenant picks the namespace a request acts in: the tenant named by
+// the /t/{tenant} prefix, else the one whose base URL has the request's
+// host, else the API key's. Keys confined to a tenant, and keys of the
+// default namespace, are refused in any other tenant; only instance admins
+// may act everywhere.
+func (h *Handler) SelectTenant(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+	id := req.PathParameter("tenant")
+	if id == "" {
+		id = h.Tenants.byHost[strings.ToLower(req.Request.Host)]
+	}
+	key := requestKey(req)
+	if id == "" {
+		id = key.Tenant
+	}
+	if key.ID != "" && key.Tenant != id && !instanceAdmin(key) {
+		writeErrorCode(req, resp, http.StatusForbidden, CodeForbidden, "api key belongs to another tenant")
+		return
+	}
+	if id != "" {
+		ns, exists := h.Tenants.byID[id]
+		if !exists {
+			writeErrorCode(req, resp, http.StatusNotFound, CodeTenantNotFound, "tenant not found")
+			return
+		}
+		req.SetAttribute(namespaceAttribute, ns)
+	}
+	chain.ProcessFilter(req, resp)
+}
+
+// namespace returns the namespace SelectTenant chose for req.
+func (h *Handler) namespace(req *restful.Request) Namespace {
+	if ns, ok := req.Attribute(namespaceAttribute).(Namespace); ok {
+		return ns
+	}
+	return Namespace{Service: h.URLService}
+}
+
+// instanceAdmin reports whether key administers the whole instance rather
+// than one tenant.
+func instanceAdmin(key auth.Key) bool {
+	return key.Admin() && key.Tenant == ""
+}
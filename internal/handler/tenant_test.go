@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"url-shortener/internal/auth"
+	"url-shortener/internal/service"
+	"url-shortener/internal/tenant"
+	"url-shortener/model"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type TenantTestSuite struct {
+	suite.Suite
+	Default   *urlServiceMock
+	Acme      *urlServiceMock
+	Keys      *auth.Keyring
+	Container *restful.Container
+	Admin     string
+	User      string
+	AcmeUser  string
+	AcmeAdmin string
+}
+
+func TestTenantTestSuite(t *testing.T) {
+	suite.Run(t, new(TenantTestSuite))
+}
+
+func (suite *TenantTestSuite) SetupTest() {
+	suite.Default, suite.Acme = &urlServiceMock{}, &urlServiceMock{}
+	suite.Keys = auth.NewKeyring()
+	h := NewHandler(suite.Default, suite.Keys)
+	h.Tenants.Add(tenant.Tenant{ID: "acme", BaseURL: "https://go.acme.com"}, suite.Acme)
+	h.Tenants.Add(tenant.Tenant{ID: "beta"}, &urlServiceMock{})
+	suite.Container = restful.NewContainer()
+	h.Register(suite.Container)
+
+	for _, k := range []struct {
+		secret       *string
+		role         auth.Role
+		tenant, name string
+	}{
+		{&suite.Admin, auth.RoleAdmin, "", "ops"},
+		{&suite.User, auth.RoleUser, "", "marketing"},
+		{&suite.AcmeUser, auth.RoleUser, "acme", "acme"},
+		{&suite.AcmeAdmin, auth.RoleAdmin, "acme", "acme-ops"},
+	} {
+		var err error
+		*k.secret, _, err = suite.Keys.Create(k.name, k.role, k.tenant)
+		require.NoError(suite.T(), err)
+	}
+}
+
+func (suite *TenantTestSuite) serve(method, target, host, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if host != "" {
+		req.Host = host
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", restful.MIME_JSON)
+	}
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	recorder := httptest.NewRecorder()
+	suite.Container.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func (suite *TenantTestSuite) shortURL(recorder *httptest.ResponseRecorder) string {
+	var response model.URLResponse
+	require.NoError(suite.T(), json.Unmarshal(recorder.Body.Bytes(), &response))
+	return response.ShortURL
+}
+
+func (suite *TenantTestSuite) TestSelectByPathPrefix() {
+	recorder := suite.serve("POST", "/t/acme/shorten", "", suite.Admin, `{"original_url":"https://example.com"}`)
+
+	require.Equal(suite.T(), http.StatusOK, recorder.Code)
+	assert.Equal(suite.T(), "https://go.acme.com/abc123", suite.shortURL(recorder))
+	assert.NotEmpty(suite.T(), suite.Acme.owner)
+	assert.Empty(suite.T(), suite.Default.owner)
+}
+
+func (suite *TenantTestSuite) TestSelectByHost() {
+	recorder := suite.serve("GET", "/r/abc123", "GO.acme.com", "", "")
+
+	assert.Equal(suite.T(), http.StatusMovedPermanently, recorder.Code)
+	assert.Len(suite.T(), suite.Acme.clicks, 1)
+	assert.Empty(suite.T(), suite.Default.clicks)
+}
+
+func (suite *TenantTestSuite) TestSelectByKey() {
+	recorder := suite.serve("POST", "/shorten", "", suite.AcmeUser, `{"original_url":"https://example.com"}`)
+
+	require.Equal(suite.T(), http.StatusOK, recorder.Code)
+	assert.Equal(suite.T(), "https://go.acme.com/abc123", suite.shortURL(recorder))
+	assert.NotEmpty(suite.T(), suite.Acme.owner)
+}
+
+func (suite *TenantTestSuite) TestDefaultNamespace() {
+	recorder := suite.serve("POST", "/shorten", "", suite.User, `{"original_url":"https://example.com"}`)
+
+	require.Equal(suite.T(), http.StatusOK, recorder.Code)
+	assert.Equal(suite.T(), "http://example.com/abc123", suite.shortURL(recorder))
+	assert.NotEmpty(suite.T(), suite.Default.owner)
+}
+
+func (suite *TenantTestSuite) TestKeysStayInTheirTenant() {
+	for _, tc := range []struct{ target, host, key string }{
+		{"/t/beta/links", "", suite.AcmeUser},
+		{"/links", "go.acme.com", suite.User},
+		{"/t/acme/links", "", suite.User},
+	} {
+		recorder := suite.serve("GET", tc.target, tc.host, tc.key, "")
+		assert.Equal(suite.T(), http.StatusForbidden, recorder.Code, tc.target)
+	}
+	assert.Equal(suite.T(), http.StatusOK, suite.serve("GET", "/t/beta/links", "", suite.Admin, "").Code)
+}
+
+func (suite *TenantTestSuite) TestUnknownTenant() {
+	recorder := suite.serve("GET", "/t/nope/r/abc123", "", "", "")
+
+	assert.Equal(suite.T(), http.StatusNotFound, recorder.Code)
+}
+
+func (suite *TenantTestSuite) TestTenantAdmin() {
+	recorder := suite.serve("GET", "/api/v1/analytics/top-domains", "", suite.AcmeAdmin, "")
+	assert.Equal(suite.T(), http.StatusOK, recorder.Code)
+	assert.Equal(suite.T(), service.DefaultTopDomains, suite.Acme.topLimit, "Tenant admins read their own leaderboard")
+
+	assert.Equal(suite.T(), http.StatusForbidden, suite.serve("GET", "/admin/keys", "", suite.AcmeAdmin, "").Code)
+	assert.Equal(suite.T(), http.StatusForbidden, suite.serve("GET", "/metrics", "", suite.AcmeAdmin, "").Code)
+}
+
+func (suite *TenantTestSuite) TestCreateTenantKey() {
+	recorder := suite.serve("POST", "/admin/keys", "", suite.Admin, `{"name":"beta","tenant":"beta"}`)
+	require.Equal(suite.T(), http.StatusCreated, recorder.Code)
+	var created model.APIKeyResponse
+	require.NoError(suite.T(), json.Unmarshal(recorder.Body.Bytes(), &created))
+	assert.Equal(suite.T(), "beta", created.Tenant)
+
+	recorder = suite.serve("POST", "/admin/keys", "", suite.Admin, `{"name":"x","tenant":"nope"}`)
+	assert.Equal(suite.T(), http.StatusBadRequest, recorder.Code)
+}
+
+func (suite *TenantTestSuite) TestNoTenantMetricsKeys() {
+	recorder := suite.serve("POST", "/admin/keys", "", suite.Admin, `{"name":"prometheus","role":"metrics","tenant":"acme"}`)
+
+	assert.Equal(suite.T(), http.StatusBadRequest, recorder.Code)
+	assert.Equal(suite.T(), "invalid_role", errorBody(recorder).Code)
+}
@@ -0,0 +1,155 @@
+// Package health runs the checks behind the readiness probe. Components
+// register named checks; the service is ready when every check passes and
+// it is not shutting down.
+package health
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTimeout bounds each check run by Registry.Run.
+const DefaultTimeout = 2 * time.Second
+
+// ErrDraining is reported by the "shutdown" check once Drain is called.
+var ErrDraining = errors.New("health: shutting down")
+
+// Check reports why a component cannot serve traffic, or nil if it can. It
+// should return promptly once ctx is done.
+type Check func(ctx context.Context) error
+
+// Checker is implemented by components, such as storage backends, that
+// contribute checks of their own.
+type Checker interface {
+	// HealthChecks returns the component's checks by name.
+	HealthChecks() map[string]Check
+}
+
+// Registry holds the readiness checks of the service.
+type Registry struct {
+	// Timeout bounds each check; zero means DefaultTimeout.
+	Timeout time.Duration
+
+	mu       sync.Mutex
+	checks   map[string]Check
+	draining atomic.Bool
+}
+
+// NewRegistry returns a registry with only the shutdown check.
+func NewRegistry() *Registry {
+	r := &Registry{checks: make(map[string]Check)}
+	r.Register("shutdown", func(context.Context) error {
+		if r.draining.Load() {
+			return ErrDraining
+		}
+		return nil
+	})
+	return r
+}
+
+// Register adds a check. Names must be unique.
+func (r *Registry) Register(name string, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, dup := r.checks[name]; dup {
+		panic("health: duplicate check " + name)
+	}
+	r.checks[name] = check
+}
+
+// Add registers every check of c, naming each prefix.name.
+func (r *Registry) Add(prefix string, c Checker) {
+	for name, check := range c.HealthChecks() {
+		r.Register(prefix+"."+name, check)
+	}
+}
+
+// Pending registers a check that fails with reason until the returned
+// function is called, for work such as startup that must finish before the
+// service is ready.
+func (r *Registry) Pending(name, reason string) (done func()) {
+	var finished atomic.Bool
+	err := errors.New(reason)
+	r.Register(name, func(context.Context) error {
+		if !finished.Load() {
+			return err
+		}
+		return nil
+	})
+	return func() { finished.Store(true) }
+}
+
+// Drain makes the service unready for good, so load balancers stop sending
+// it traffic before it shuts down.
+func (r *Registry) Drain() {
+	r.draining.Store(true)
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Name string
+	Err  error
+}
+
+// Report is the outcome of every check, in name order.
+type Report []Result
+
+// Ready reports whether every check passed.
+func (rep Report) Ready() bool {
+	for _, res := range rep {
+		if res.Err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Run runs every check concurrently, each bounded by the registry timeout.
+// A check still running when its time is up fails with
+// context.DeadlineExceeded.
+func (r *Registry) Run(ctx context.Context) Report {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	r.mu.Lock()
+	report := make(Report, 0, len(r.checks))
+	checks := make([]Check, 0, len(r.checks))
+	for name, check := range r.checks {
+		report = append(report, Result{Name: name})
+		checks = append(checks, check)
+	}
+	r.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report[i].Err = run(ctx, timeout, check)
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(report, func(i, j int) bool { return report[i].Name < report[j].Name })
+	return report
+}
+
+// run runs check, giving up on it after timeout even if it ignores ctx.
+func run(ctx context.Context, timeout time.Duration, check Check) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	errc := make(chan error, 1)
+	go func() { errc <- check(ctx) }()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
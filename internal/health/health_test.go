@@ -0,0 +1,92 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type HealthTestSuite struct {
+	suite.Suite
+	Registry *Registry
+}
+
+func TestHealthTestSuite(t *testing.T) {
+	suite.Run(t, new(HealthTestSuite))
+}
+
+func (suite *HealthTestSuite) SetupTest() {
+	suite.Registry = NewRegistry()
+}
+
+type fakeBackend map[string]Check
+
+func (b fakeBackend) HealthChecks() map[string]Check { return b }
+
+func (suite *HealthTestSuite) TestReadyByDefault() {
+	report := suite.Registry.Run(context.Background())
+	assert.True(suite.T(), report.Ready())
+	assert.Equal(suite.T(), Report{{Name: "shutdown"}}, report)
+}
+
+func (suite *HealthTestSuite) TestFailingCheck() {
+	broken := errors.New("disk on fire")
+	suite.Registry.Register("disk", func(context.Context) error { return broken })
+
+	report := suite.Registry.Run(context.Background())
+	assert.False(suite.T(), report.Ready())
+	assert.Equal(suite.T(), Report{{Name: "disk", Err: broken}, {Name: "shutdown"}}, report)
+}
+
+func (suite *HealthTestSuite) TestAddPrefixesNames() {
+	suite.Registry.Add("storage", fakeBackend{
+		"wal":  func(context.Context) error { return nil },
+		"disk": func(context.Context) error { return nil },
+	})
+
+	var names []string
+	for _, res := range suite.Registry.Run(context.Background()) {
+		names = append(names, res.Name)
+	}
+	assert.Equal(suite.T(), []string{"shutdown", "storage.disk", "storage.wal"}, names)
+}
+
+func (suite *HealthTestSuite) TestDuplicatePanics() {
+	assert.Panics(suite.T(), func() {
+		suite.Registry.Register("shutdown", func(context.Context) error { return nil })
+	})
+}
+
+func (suite *HealthTestSuite) TestPending() {
+	done := suite.Registry.Pending("startup", "replaying logs")
+	report := suite.Registry.Run(context.Background())
+	assert.False(suite.T(), report.Ready())
+	assert.EqualError(suite.T(), report[1].Err, "replaying logs")
+
+	done()
+	assert.True(suite.T(), suite.Registry.Run(context.Background()).Ready())
+}
+
+func (suite *HealthTestSuite) TestDrain() {
+	suite.Registry.Drain()
+	report := suite.Registry.Run(context.Background())
+	assert.False(suite.T(), report.Ready())
+	assert.ErrorIs(suite.T(), report[0].Err, ErrDraining)
+}
+
+func (suite *HealthTestSuite) TestTimeout() {
+	suite.Registry.Timeout = 10 * time.Millisecond
+	hung := make(chan struct{})
+	defer close(hung)
+	suite.Registry.Register("hung", func(context.Context) error {
+		<-hung
+		return nil
+	})
+
+	report := suite.Registry.Run(context.Background())
+	assert.ErrorIs(suite.T(), report[0].Err, context.DeadlineExceeded, "A check that ignores its context still fails in time")
+}
@@ -0,0 +1,68 @@
+// Package logging sets up the service's structured logger and keeps
+// destination URLs, whose query strings often carry tokens and personal
+// data, out of the logs.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"strings"
+)
+
+// Log formats accepted by New.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// New returns a logger writing records at level or above to w, as
+// key=value text or one JSON object per line. level is debug, info, warn or
+// error.
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("logging: level %q: must be debug, info, warn or error", level)
+	}
+	opts := &slog.HandlerOptions{Level: l}
+	switch strings.ToLower(format) {
+	case FormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("logging: format %q: must be text or json", format)
+}
+
+// redacted replaces the parts of a URL that are not logged.
+const redacted = "REDACTED"
+
+// URL is a destination URL that logs without its credentials, query string
+// or fragment.
+type URL string
+
+// LogValue implements slog.LogValuer.
+func (u URL) LogValue() slog.Value {
+	return slog.StringValue(RedactURL(string(u)))
+}
+
+// RedactURL returns raw with any password, query string and fragment
+// replaced by REDACTED. Values that do not parse as URLs are redacted
+// whole.
+func RedactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redacted
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+	if u.RawQuery != "" || u.ForceQuery {
+		u.RawQuery, u.ForceQuery = redacted, false
+	}
+	if u.Fragment != "" {
+		u.Fragment, u.RawFragment = redacted, ""
+	}
+	return u.String()
+}
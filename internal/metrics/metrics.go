@@ -0,0 +1,343 @@
+// Package metrics is a small Prometheus-compatible registry that renders
+// the text exposition format (version 0.0.4). It covers the counters,
+// histograms and callback gauges the service needs without pulling in the
+// full client library.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ContentType is the media type of the exposition format written by
+// Registry.WriteTo.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefBuckets are latency buckets in seconds suited to an HTTP service.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type collector interface {
+	write(w io.Writer, name string)
+}
+
+type family struct {
+	name, help, kind string
+	collector        collector
+}
+
+// Registry holds metric families and renders them in name order.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]family
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]family)}
+}
+
+func (r *Registry) register(name, help, kind string, c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, dup := r.families[name]; dup {
+		panic("metrics: duplicate metric " + name)
+	}
+	r.families[name] = family{name: name, help: help, kind: kind, collector: c}
+}
+
+// Counter registers a counter partitioned by labels.
+func (r *Registry) Counter(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{vec: newVec[*Counter](labels, func() *Counter { return &Counter{} })}
+	r.register(name, help, "counter", c)
+	return c
+}
+
+// Histogram registers a histogram partitioned by labels. Buckets are upper
+// bounds in ascending order; the +Inf bucket is implicit.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{vec: newVec[*Histogram](labels, func() *Histogram {
+		return &Histogram{upper: buckets, counts: make([]atomic.Uint64, len(buckets))}
+	})}
+	r.register(name, help, "histogram", h)
+	return h
+}
+
+// GaugeFunc registers a gauge whose value is read from fn at scrape time.
+func (r *Registry) GaugeFunc(name, help string, fn func() float64) {
+	r.register(name, help, "gauge", funcCollector(fn))
+}
+
+// CounterFunc registers a counter whose value is read from fn at scrape
+// time. fn must never decrease.
+func (r *Registry) CounterFunc(name, help string, fn func() float64) {
+	r.register(name, help, "counter", funcCollector(fn))
+}
+
+// GaugeFuncVec registers a gauge partitioned by labels whose values are
+// read at scrape time from the functions set with FuncVec.Set.
+func (r *Registry) GaugeFuncVec(name, help string, labels ...string) *FuncVec {
+	f := &FuncVec{vec: newVec[*funcValue](labels, func() *funcValue { return &funcValue{} })}
+	r.register(name, help, "gauge", f)
+	return f
+}
+
+// CounterFuncVec is GaugeFuncVec for counters. The functions must never
+// decrease.
+func (r *Registry) CounterFuncVec(name, help string, labels ...string) *FuncVec {
+	f := &FuncVec{vec: newVec[*funcValue](labels, func() *funcValue { return &funcValue{} })}
+	r.register(name, help, "counter", f)
+	return f
+}
+
+// WriteTo renders every registered metric.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	families := r.families
+	r.mu.Unlock()
+	sort.Strings(names)
+
+	cw := &countingWriter{w: w}
+	for _, name := range names {
+		f := families[name]
+		fmt.Fprintf(cw, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+		fmt.Fprintf(cw, "# TYPE %s %s\n", f.name, f.kind)
+		f.collector.write(cw, f.name)
+	}
+	return cw.n, cw.err
+}
+
+// ServeHTTP writes the registry in the text exposition format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	r.WriteTo(w)
+}
+
+// Counter is a monotonically increasing value.
+type Counter struct {
+	bits atomic.Uint64
+}
+
+// Inc adds one.
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add adds v, which must not be negative.
+func (c *Counter) Add(v float64) {
+	for {
+		old := c.bits.Load()
+		next := math.Float64bits(math.Float64frombits(old) + v)
+		if c.bits.CompareAndSwap(old, next) {
+			return
+		}
+	}
+}
+
+// Value returns the current count.
+func (c *Counter) Value() float64 {
+	return math.Float64frombits(c.bits.Load())
+}
+
+// CounterVec is a set of counters sharing a name.
+type CounterVec struct {
+	*vec[*Counter]
+}
+
+func (c *CounterVec) write(w io.Writer, name string) {
+	c.each(func(labels string, counter *Counter) {
+		fmt.Fprintf(w, "%s%s %s\n", name, labels, formatFloat(counter.Value()))
+	})
+}
+
+// Histogram counts observations into cumulative buckets.
+type Histogram struct {
+	upper  []float64
+	counts []atomic.Uint64
+	count  atomic.Uint64
+	sum    Counter
+}
+
+// Observe records v.
+func (h *Histogram) Observe(v float64) {
+	for i, upper := range h.upper {
+		if v <= upper {
+			h.counts[i].Add(1)
+			break
+		}
+	}
+	h.count.Add(1)
+	h.sum.Add(v)
+}
+
+// HistogramVec is a set of histograms sharing a name and buckets.
+type HistogramVec struct {
+	*vec[*Histogram]
+}
+
+func (h *HistogramVec) write(w io.Writer, name string) {
+	h.each(func(labels string, hist *Histogram) {
+		var cumulative uint64
+		for i, upper := range hist.upper {
+			cumulative += hist.counts[i].Load()
+			fmt.Fprintf(w, "%s_bucket%s %d\n", name, withLabel(labels, "le", formatFloat(upper)), cumulative)
+		}
+		count := hist.count.Load()
+		fmt.Fprintf(w, "%s_bucket%s %d\n", name, withLabel(labels, "le", "+Inf"), count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", name, labels, formatFloat(hist.sum.Value()))
+		fmt.Fprintf(w, "%s_count%s %d\n", name, labels, count)
+	})
+}
+
+type funcCollector func() float64
+
+func (fn funcCollector) write(w io.Writer, name string) {
+	fmt.Fprintf(w, "%s %s\n", name, formatFloat(fn()))
+}
+
+// FuncVec is a set of callback metrics sharing a name.
+type FuncVec struct {
+	*vec[*funcValue]
+}
+
+// Set makes fn the source of the metric with the given label values.
+func (f *FuncVec) Set(fn func() float64, values ...string) {
+	f.With(values...).fn.Store(&fn)
+}
+
+func (f *FuncVec) write(w io.Writer, name string) {
+	f.each(func(labels string, v *funcValue) {
+		if fn := v.fn.Load(); fn != nil {
+			fmt.Fprintf(w, "%s%s %s\n", name, labels, formatFloat((*fn)()))
+		}
+	})
+}
+
+type funcValue struct {
+	fn atomic.Pointer[func() float64]
+}
+
+// vec maps label values to one metric each.
+type vec[M any] struct {
+	labels  []string
+	newFunc func() M
+	mu      sync.RWMutex
+	metrics map[string]M
+}
+
+func newVec[M any](labels []string, newFunc func() M) *vec[M] {
+	return &vec[M]{labels: labels, newFunc: newFunc, metrics: make(map[string]M)}
+}
+
+// With returns the metric for the given label values, creating it on first
+// use. Values are matched to label names by position.
+func (v *vec[M]) With(values ...string) M {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: got %d label values for %d labels", len(values), len(v.labels)))
+	}
+	key := v.render(values)
+	v.mu.RLock()
+	m, ok := v.metrics[key]
+	v.mu.RUnlock()
+	if ok {
+		return m
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if m, ok = v.metrics[key]; !ok {
+		m = v.newFunc()
+		v.metrics[key] = m
+	}
+	return m
+}
+
+func (v *vec[M]) render(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range v.labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func (v *vec[M]) each(fn func(labels string, m M)) {
+	v.mu.RLock()
+	keys := make([]string, 0, len(v.metrics))
+	for k := range v.metrics {
+		keys = append(keys, k)
+	}
+	metrics := make(map[string]M, len(v.metrics))
+	for k, m := range v.metrics {
+		metrics[k] = m
+	}
+	v.mu.RUnlock()
+	sort.Strings(keys)
+	for _, k := range keys {
+		fn(k, metrics[k])
+	}
+}
+
+// withLabel appends name="value" to a rendered label set.
+func withLabel(labels, name, value string) string {
+	pair := name + `="` + value + `"`
+	if labels == "" {
+		return "{" + pair + "}"
+	}
+	return labels[:len(labels)-1] + "," + pair + "}"
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
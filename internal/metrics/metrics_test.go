@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type MetricsTestSuite struct {
+	suite.Suite
+	Registry *Registry
+}
+
+func TestMetricsTestSuite(t *testing.T) {
+	suite.Run(t, new(MetricsTestSuite))
+}
+
+func (suite *MetricsTestSuite) SetupTest() {
+	suite.Registry = NewRegistry()
+}
+
+func (suite *MetricsTestSuite) render() string {
+	var b strings.Builder
+	_, err := suite.Registry.WriteTo(&b)
+	suite.Require().NoError(err)
+	return b.String()
+}
+
+func (suite *MetricsTestSuite) TestCounter() {
+	requests := suite.Registry.Counter("requests_total", "Requests served.", "route", "code")
+	requests.With("/b", "200").Inc()
+	requests.With("/a", "404").Add(2)
+	requests.With("/b", "200").Inc()
+
+	assert.Equal(suite.T(), `# HELP requests_total Requests served.
+# TYPE requests_total counter
+requests_total{route="/a",code="404"} 2
+requests_total{route="/b",code="200"} 2
+`, suite.render())
+}
+
+func (suite *MetricsTestSuite) TestHistogram() {
+	latency := suite.Registry.Histogram("latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+	latency.With("/a").Observe(0.05)
+	latency.With("/a").Observe(0.5)
+	latency.With("/a").Observe(3)
+
+	assert.Equal(suite.T(), `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/a",le="0.1"} 1
+latency_seconds_bucket{route="/a",le="1"} 2
+latency_seconds_bucket{route="/a",le="+Inf"} 3
+latency_seconds_sum{route="/a"} 3.55
+latency_seconds_count{route="/a"} 3
+`, suite.render())
+}
+
+func (suite *MetricsTestSuite) TestFuncMetricsAndOrdering() {
+	size := 3.0
+	suite.Registry.GaugeFunc("b_links", "Links stored.", func() float64 { return size })
+	suite.Registry.CounterFunc("a_dropped_total", "Dropped.", func() float64 { return 7 })
+	size = 4
+
+	assert.Equal(suite.T(), `# HELP a_dropped_total Dropped.
+# TYPE a_dropped_total counter
+a_dropped_total 7
+# HELP b_links Links stored.
+# TYPE b_links gauge
+b_links 4
+`, suite.render())
+}
+
+func (suite *MetricsTestSuite) TestFuncVec() {
+	links := suite.Registry.GaugeFuncVec("links", "Links stored.", "tenant")
+	links.Set(func() float64 { return 2 }, "")
+	links.Set(func() float64 { return 5 }, "acme")
+	suite.Registry.CounterFuncVec("empty_total", "Nothing set.", "tenant")
+
+	assert.Equal(suite.T(), `# HELP empty_total Nothing set.
+# TYPE empty_total counter
+# HELP links Links stored.
+# TYPE links gauge
+links{tenant=""} 2
+links{tenant="acme"} 5
+`, suite.render())
+}
+
+func (suite *MetricsTestSuite) TestLabelEscaping() {
+	suite.Registry.Counter("c_total", "Help with \\ and\nnewline.", "v").With("a\"b\\c\nd").Inc()
+
+	out := suite.render()
+	assert.Contains(suite.T(), out, `# HELP c_total Help with \\ and\nnewline.`)
+	assert.Contains(suite.T(), out, `c_total{v="a\"b\\c\nd"} 1`)
+}
+
+func (suite *MetricsTestSuite) TestDuplicateRegistrationPanics() {
+	suite.Registry.Counter("dup_total", "First.")
+	assert.Panics(suite.T(), func() { suite.Registry.Counter("dup_total", "Second.") })
+}
+
+func (suite *MetricsTestSuite) TestServeHTTP() {
+	suite.Registry.GaugeFunc("up", "Up.", func() float64 { return 1 })
+	recorder := httptest.NewRecorder()
+
+	suite.Registry.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(suite.T(), ContentType, recorder.Header().Get("Content-Type"))
+	assert.Contains(suite.T(), recorder.Body.String(), "up 1\n")
+}
@@ -0,0 +1,293 @@
+// Package openapi describes go-restful web services as an OpenAPI 3.0
+// document. Everything comes from the documentation attached to the
+// routes: Doc and Notes, parameters, Reads and Returns. Schemas are derived
+// from the Go types of the samples by reflection, following their json
+// tags, so the document cannot drift from the code it describes.
+package openapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	restful "github.com/emicklei/go-restful/v3"
+)
+
+// Version is the OpenAPI version of the documents built here.
+const Version = "3.0.3"
+
+// MetaSecurity is the route metadata key that, set to true, marks a route as
+// requiring one of Config.SecuritySchemes.
+const MetaSecurity = "openapi.security"
+
+// Config describes the API as a whole.
+type Config struct {
+	Info Info
+	// SecuritySchemes are the ways of authenticating; routes marked with
+	// MetaSecurity accept any one of them.
+	SecuritySchemes map[string]SecurityScheme
+}
+
+// Document is an OpenAPI document. Only the parts Build fills in are
+// modelled.
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// PathItem holds the operations on one path by lowercase method.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas,omitempty"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme is an API key ("apiKey" with Name and In) or an HTTP
+// authentication scheme ("http" with Scheme).
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+	Name        string `json:"name,omitempty"`
+	In          string `json:"in,omitempty"`
+	Scheme      string `json:"scheme,omitempty"`
+}
+
+// Build describes the routes of services.
+func Build(cfg Config, services []*restful.WebService) *Document {
+	doc := &Document{
+		OpenAPI:    Version,
+		Info:       cfg.Info,
+		Paths:      make(map[string]PathItem),
+		Components: Components{SecuritySchemes: cfg.SecuritySchemes},
+	}
+	schemas := newSchemas()
+	var security []map[string][]string
+	for _, name := range sortedKeys(cfg.SecuritySchemes) {
+		security = append(security, map[string][]string{name: {}})
+	}
+
+	for _, ws := range services {
+		for _, route := range ws.Routes() {
+			op := &Operation{
+				OperationID: route.Operation,
+				Summary:     route.Doc,
+				Description: route.Notes,
+				Responses:   make(map[string]Response),
+				Deprecated:  route.Deprecated,
+			}
+			for _, p := range append(ws.PathParameters(), route.ParameterDocs...) {
+				if p.Kind() == restful.BodyParameterKind {
+					continue
+				}
+				op.Parameters = append(op.Parameters, parameter(p.Data()))
+			}
+			if route.ReadSample != nil {
+				op.RequestBody = &RequestBody{Required: true, Content: content(route.Consumes, schemas.of(route.ReadSample))}
+				if body := bodyParameter(route); body != nil {
+					op.RequestBody.Description = body.Description
+				}
+			}
+			for code, r := range route.ResponseErrors {
+				op.Responses[strconv.Itoa(code)] = response(r, route.Produces, code, schemas)
+			}
+			if route.DefaultResponse != nil {
+				op.Responses["default"] = response(*route.DefaultResponse, route.Produces, http.StatusInternalServerError, schemas)
+			}
+			if secured, _ := route.Metadata[MetaSecurity].(bool); secured {
+				op.Security = security
+			}
+
+			path := templatePath(route.Path)
+			if doc.Paths[path] == nil {
+				doc.Paths[path] = make(PathItem)
+			}
+			doc.Paths[path][strings.ToLower(route.Method)] = op
+		}
+	}
+	doc.Components.Schemas = schemas.defs
+	return doc
+}
+
+// Check reports every route of services that Build could not describe
+// fully: one without a summary or responses, one whose path parameters are
+// not all documented, or one sharing its operation id with another.
+func Check(services []*restful.WebService) error {
+	var errs []error
+	operations := make(map[string]string)
+	for _, ws := range services {
+		documented := make(map[string]bool)
+		for _, p := range ws.PathParameters() {
+			documented[p.Data().Name] = true
+		}
+		for _, route := range ws.Routes() {
+			name := route.Method + " " + route.Path
+			fail := func(format string, args ...any) {
+				errs = append(errs, fmt.Errorf("openapi: %s: "+format, append([]any{name}, args...)...))
+			}
+			if route.Doc == "" {
+				fail("no Doc")
+			}
+			if len(route.ResponseErrors) == 0 {
+				fail("no Returns")
+			}
+			params := make(map[string]bool)
+			for _, p := range route.ParameterDocs {
+				if p.Kind() == restful.PathParameterKind {
+					params[p.Data().Name] = true
+				}
+			}
+			for _, m := range pathParam.FindAllStringSubmatch(route.Path, -1) {
+				if !params[m[1]] && !documented[m[1]] {
+					fail("path parameter %s is not documented", m[1])
+				}
+			}
+			if other, dup := operations[route.Operation]; dup {
+				fail("operation %q is also used by %s", route.Operation, other)
+			}
+			operations[route.Operation] = name
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// pathParam matches the {name} or {name:regexp} segments of a route path.
+var pathParam = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// templatePath drops the regular expressions go-restful allows in path
+// parameters, which OpenAPI has no place for.
+func templatePath(path string) string {
+	return pathParam.ReplaceAllString(path, "{$1}")
+}
+
+var parameterLocations = map[int]string{
+	restful.PathParameterKind:   "path",
+	restful.QueryParameterKind:  "query",
+	restful.HeaderParameterKind: "header",
+}
+
+func parameter(p restful.ParameterData) Parameter {
+	schema := &Schema{Type: p.DataType, Format: p.DataFormat, Enum: p.PossibleValues}
+	if schema.Type == "" {
+		schema.Type = "string"
+	}
+	if p.AllowMultiple {
+		schema = &Schema{Type: "array", Items: schema}
+	}
+	return Parameter{
+		Name:        p.Name,
+		In:          parameterLocations[p.Kind],
+		Description: p.Description,
+		Required:    p.Required || p.Kind == restful.PathParameterKind,
+		Schema:      schema,
+	}
+}
+
+func bodyParameter(route restful.Route) *restful.ParameterData {
+	for _, p := range route.ParameterDocs {
+		if p.Kind() == restful.BodyParameterKind {
+			data := p.Data()
+			return &data
+		}
+	}
+	return nil
+}
+
+// response describes r. Errors are always written as JSON whatever the
+// route produces otherwise.
+func response(r restful.ResponseError, produces []string, code int, schemas *schemas) Response {
+	out := Response{Description: r.Message}
+	if out.Description == "" {
+		out.Description = http.StatusText(code)
+	}
+	if r.Model != nil {
+		if code >= http.StatusBadRequest {
+			produces = []string{restful.MIME_JSON}
+		}
+		out.Content = content(produces, schemas.of(r.Model))
+	}
+	for _, name := range sortedKeys(r.Headers) {
+		h := r.Headers[name]
+		if out.Headers == nil {
+			out.Headers = make(map[string]Header)
+		}
+		schema := &Schema{Type: "string"}
+		if h.Items != nil {
+			schema = &Schema{Type: h.Type, Format: h.Format}
+		}
+		out.Headers[name] = Header{Description: h.Description, Schema: schema}
+	}
+	return out
+}
+
+func content(mimeTypes []string, schema *Schema) map[string]MediaType {
+	if len(mimeTypes) == 0 {
+		mimeTypes = []string{restful.MIME_JSON}
+	}
+	out := make(map[string]MediaType, len(mimeTypes))
+	for _, mime := range mimeTypes {
+		out[mime] = MediaType{Schema: schema}
+	}
+	return out
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
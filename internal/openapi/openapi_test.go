@@ -0,0 +1,157 @@
+package openapi
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type OpenAPITestSuite struct {
+	suite.Suite
+	WS *restful.WebService
+}
+
+func TestOpenAPITestSuite(t *testing.T) {
+	suite.Run(t, new(OpenAPITestSuite))
+}
+
+type note struct {
+	Text     string    `json:"text"`
+	Tags     []string  `json:"tags,omitempty"`
+	Created  time.Time `json:"created,omitzero"`
+	Parent   *note     `json:"parent,omitempty"`
+	internal string
+	Ignored  string `json:"-"`
+	audit
+}
+
+type audit struct {
+	Version int64 `json:"version"`
+}
+
+type failure struct {
+	Code string `json:"code"`
+}
+
+func noop(*restful.Request, *restful.Response) {}
+
+func (suite *OpenAPITestSuite) SetupTest() {
+	suite.WS = new(restful.WebService)
+	suite.WS.Path("/v1").Consumes(restful.MIME_JSON).Produces(restful.MIME_JSON)
+	suite.WS.Param(suite.WS.PathParameter("shelf", "Shelf"))
+}
+
+func (suite *OpenAPITestSuite) build() *Document {
+	return Build(Config{
+		Info:            Info{Title: "notes", Version: "1"},
+		SecuritySchemes: map[string]SecurityScheme{"key": {Type: "apiKey", Name: "X-Key", In: "header"}},
+	}, []*restful.WebService{suite.WS})
+}
+
+func (suite *OpenAPITestSuite) TestOperation() {
+	ws := suite.WS
+	ws.Route(ws.POST("/{shelf}/notes").Operation("AddNote").To(noop).
+		Doc("Add a note").
+		Notes("Longer text.").
+		Metadata(MetaSecurity, true).
+		Param(ws.QueryParameter("limit", "Limit").DataType("integer")).
+		Reads(note{}, "The note").
+		Returns(http.StatusCreated, "Added", note{}).
+		Returns(http.StatusBadRequest, "Rejected", failure{}).
+		DefaultReturns("Error", failure{}))
+
+	doc := suite.build()
+	assert.Equal(suite.T(), Version, doc.OpenAPI)
+	op := doc.Paths["/v1/{shelf}/notes"]["post"]
+	require.NotNil(suite.T(), op)
+	assert.Equal(suite.T(), "AddNote", op.OperationID)
+	assert.Equal(suite.T(), "Add a note", op.Summary)
+	assert.Equal(suite.T(), "Longer text.", op.Description)
+	assert.Equal(suite.T(), []Parameter{
+		{Name: "shelf", In: "path", Description: "Shelf", Required: true, Schema: &Schema{Type: "string"}},
+		{Name: "limit", In: "query", Description: "Limit", Schema: &Schema{Type: "integer"}},
+	}, op.Parameters)
+	assert.Equal(suite.T(), &RequestBody{
+		Description: "The note",
+		Required:    true,
+		Content:     map[string]MediaType{restful.MIME_JSON: {Schema: &Schema{Ref: "#/components/schemas/note"}}},
+	}, op.RequestBody)
+	assert.Equal(suite.T(), "Added", op.Responses["201"].Description)
+	assert.Equal(suite.T(), "#/components/schemas/failure", op.Responses["400"].Content[restful.MIME_JSON].Schema.Ref)
+	assert.Contains(suite.T(), op.Responses, "default")
+	assert.Equal(suite.T(), []map[string][]string{{"key": {}}}, op.Security)
+}
+
+func (suite *OpenAPITestSuite) TestSchemaFollowsJSONTags() {
+	ws := suite.WS
+	ws.Route(ws.GET("/{shelf}").To(noop).Doc("List").Returns(http.StatusOK, "Notes", []note{}))
+
+	doc := suite.build()
+	assert.Equal(suite.T(), &Schema{Type: "array", Items: &Schema{Ref: "#/components/schemas/note"}},
+		doc.Paths["/v1/{shelf}"]["get"].Responses["200"].Content[restful.MIME_JSON].Schema)
+	assert.Equal(suite.T(), &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"text":    {Type: "string"},
+			"tags":    {Type: "array", Items: &Schema{Type: "string"}},
+			"created": {Type: "string", Format: "date-time"},
+			"parent":  {Ref: "#/components/schemas/note"},
+			"version": {Type: "integer", Format: "int64"},
+		},
+		Required: []string{"text", "version"},
+	}, doc.Components.Schemas["note"])
+}
+
+func (suite *OpenAPITestSuite) TestErrorsAreJSON() {
+	ws := suite.WS
+	ws.Route(ws.GET("/{shelf}/export").Produces("text/plain").To(noop).
+		Doc("Export").
+		Returns(http.StatusOK, "Text", "").
+		Returns(http.StatusNotFound, "Missing", failure{}))
+
+	op := suite.build().Paths["/v1/{shelf}/export"]["get"]
+	assert.Contains(suite.T(), op.Responses["200"].Content, "text/plain")
+	assert.Contains(suite.T(), op.Responses["404"].Content, restful.MIME_JSON)
+	assert.NotContains(suite.T(), op.Responses["404"].Content, "text/plain")
+}
+
+func (suite *OpenAPITestSuite) TestResponseHeaders() {
+	ws := suite.WS
+	ws.Route(ws.GET("/{shelf}/go").To(noop).
+		Doc("Go").
+		ReturnsWithHeaders(http.StatusFound, "Away", nil, map[string]restful.Header{
+			"Location": {Items: &restful.Items{Type: "string"}, Description: "Where"},
+		}))
+
+	resp := suite.build().Paths["/v1/{shelf}/go"]["get"].Responses["302"]
+	assert.Nil(suite.T(), resp.Content)
+	assert.Equal(suite.T(), map[string]Header{"Location": {Description: "Where", Schema: &Schema{Type: "string"}}}, resp.Headers)
+}
+
+func (suite *OpenAPITestSuite) TestPathRegexpDropped() {
+	ws := suite.WS
+	ws.Route(ws.GET("/{shelf}/{id:[0-9]+}").To(noop).Doc("Get").Param(ws.PathParameter("id", "Id")).Returns(http.StatusOK, "Note", note{}))
+
+	assert.Contains(suite.T(), suite.build().Paths, "/v1/{shelf}/{id}")
+	assert.NoError(suite.T(), Check([]*restful.WebService{ws}))
+}
+
+func (suite *OpenAPITestSuite) TestCheck() {
+	ws := suite.WS
+	ws.Route(ws.GET("/{shelf}/a").Operation("A").To(noop).Doc("A").Returns(http.StatusOK, "A", note{}))
+	ws.Route(ws.GET("/{shelf}/b").Operation("B").To(noop))
+	ws.Route(ws.GET("/{shelf}/c/{id}").Operation("A").To(noop).Doc("C").Returns(http.StatusOK, "C", note{}))
+
+	err := Check([]*restful.WebService{ws})
+	require.Error(suite.T(), err)
+	assert.ErrorContains(suite.T(), err, "GET /v1/{shelf}/b: no Doc")
+	assert.ErrorContains(suite.T(), err, "GET /v1/{shelf}/b: no Returns")
+	assert.ErrorContains(suite.T(), err, "GET /v1/{shelf}/c/{id}: path parameter id is not documented")
+	assert.ErrorContains(suite.T(), err, `GET /v1/{shelf}/c/{id}: operation "A" is also used by GET /v1/{shelf}/a`)
+	assert.NotContains(suite.T(), err.Error(), "GET /v1/{shelf}/a:")
+}
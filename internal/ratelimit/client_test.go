@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type ClientTestSuite struct {
+	suite.Suite
+}
+
+func TestClientTestSuite(t *testing.T) {
+	suite.Run(t, new(ClientTestSuite))
+}
+
+func (suite *ClientTestSuite) TestClientIP() {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+	require.NoError(suite.T(), err)
+
+	for _, tc := range []struct {
+		name, remote string
+		forwarded    []string
+		want         string
+	}{
+		{"direct", "203.0.113.7:5000", nil, "203.0.113.7"},
+		{"untrusted peer is not believed", "203.0.113.7:5000", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"trusted proxy", "10.1.2.3:80", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"spoofed entries left of the client", "10.1.2.3:80", []string{"1.1.1.1, 198.51.100.1, 192.168.1.1"}, "198.51.100.1"},
+		{"repeated headers", "10.1.2.3:80", []string{"198.51.100.1", "10.9.9.9"}, "198.51.100.1"},
+		{"all hops trusted", "10.1.2.3:80", []string{"10.0.0.1"}, "10.0.0.1"},
+		{"garbage stops the walk", "10.1.2.3:80", []string{"198.51.100.1, nonsense"}, "10.1.2.3"},
+		{"ipv6", "[2001:db8::1]:443", nil, "2001:db8::1"},
+		{"mapped ipv4", "[::ffff:10.1.2.3]:80", []string{"198.51.100.1"}, "198.51.100.1"},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tc.remote
+		for _, header := range tc.forwarded {
+			req.Header.Add("X-Forwarded-For", header)
+		}
+		assert.Equal(suite.T(), tc.want, ClientIP(req, trusted), tc.name)
+	}
+}
+
+func (suite *ClientTestSuite) TestParseTrustedProxiesRejectsGarbage() {
+	_, err := ParseTrustedProxies([]string{"10.0.0.0/33"})
+	assert.Error(suite.T(), err)
+	_, err = ParseTrustedProxies([]string{"proxy.local"})
+	assert.Error(suite.T(), err)
+}
+
+func (suite *ClientTestSuite) TestTrustedPeer() {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8"})
+	require.NoError(suite.T(), err)
+	req := httptest.NewRequest("GET", "/", nil)
+
+	req.RemoteAddr = "10.1.2.3:80"
+	assert.True(suite.T(), TrustedPeer(req, trusted))
+	req.RemoteAddr = "203.0.113.7:5000"
+	assert.False(suite.T(), TrustedPeer(req, trusted))
+	assert.False(suite.T(), TrustedPeer(req, nil))
+}
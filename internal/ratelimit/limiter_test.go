@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type LimiterTestSuite struct {
+	suite.Suite
+	Limiter *Limiter
+	Now     time.Time
+}
+
+func TestLimiterTestSuite(t *testing.T) {
+	suite.Run(t, new(LimiterTestSuite))
+}
+
+func (suite *LimiterTestSuite) SetupTest() {
+	suite.Now = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	suite.Limiter = New(Limit{Rate: 2, Burst: 3})
+	suite.Limiter.now = func() time.Time { return suite.Now }
+}
+
+func (suite *LimiterTestSuite) TestBurstThenReject() {
+	for i := 2; i >= 0; i-- {
+		d := suite.Limiter.Allow("a")
+		assert.True(suite.T(), d.Allowed)
+		assert.Equal(suite.T(), i, d.Remaining)
+		assert.Equal(suite.T(), 3, d.Limit)
+	}
+
+	d := suite.Limiter.Allow("a")
+
+	assert.False(suite.T(), d.Allowed)
+	assert.Equal(suite.T(), 500*time.Millisecond, d.RetryAfter)
+	assert.Equal(suite.T(), 1500*time.Millisecond, d.Reset)
+	assert.True(suite.T(), suite.Limiter.Allow("b").Allowed, "Keys have separate buckets")
+}
+
+func (suite *LimiterTestSuite) TestRefill() {
+	for range 3 {
+		suite.Limiter.Allow("a")
+	}
+	suite.Now = suite.Now.Add(500 * time.Millisecond)
+
+	assert.True(suite.T(), suite.Limiter.Allow("a").Allowed)
+	assert.False(suite.T(), suite.Limiter.Allow("a").Allowed)
+
+	suite.Now = suite.Now.Add(time.Hour)
+	assert.Equal(suite.T(), 2, suite.Limiter.Allow("a").Remaining, "Refills stop at the burst size")
+}
+
+func (suite *LimiterTestSuite) TestIdleBucketsAreDropped() {
+	suite.Limiter.Allow("a")
+	suite.Now = suite.Now.Add(sweepEvery)
+	suite.Limiter.Allow("b")
+	suite.Limiter.Allow("b")
+	assert.Equal(suite.T(), 1, suite.Limiter.Len())
+
+	suite.Now = suite.Now.Add(sweepEvery)
+	suite.Limiter.Allow("c")
+
+	assert.Equal(suite.T(), 1, suite.Limiter.Len())
+}
@@ -0,0 +1,144 @@
+// Package server runs the HTTP server with timeouts and stops it gracefully:
+// once asked to stop it refuses new connections and lets requests in flight
+// finish before returning.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Defaults for Options.
+const (
+	DefaultAddr              = ":8080"
+	DefaultReadHeaderTimeout = 5 * time.Second
+	DefaultReadTimeout       = 10 * time.Second
+	DefaultWriteTimeout      = 30 * time.Second
+	DefaultIdleTimeout       = 2 * time.Minute
+	DefaultShutdownTimeout   = 30 * time.Second
+)
+
+// Options configure a Server. Zero timeouts disable the corresponding
+// limit, as in http.Server.
+type Options struct {
+	Addr string
+	// ReadHeaderTimeout bounds reading request headers, which stops clients
+	// from holding connections open by sending them slowly.
+	ReadHeaderTimeout time.Duration
+	// ReadTimeout bounds reading a whole request, body included.
+	ReadTimeout time.Duration
+	// WriteTimeout bounds the time from the end of the request headers to
+	// the end of the response.
+	WriteTimeout time.Duration
+	// IdleTimeout is how long keep-alive connections wait for the next
+	// request.
+	IdleTimeout time.Duration
+	// ShutdownTimeout is how long Serve waits for requests in flight once
+	// stopped before cutting them off.
+	ShutdownTimeout time.Duration
+	// DrainDelay is how long Serve keeps accepting requests once stopped,
+	// so load balancers notice the instance is no longer ready before it
+	// refuses connections.
+	DrainDelay time.Duration
+	// Draining, if set, is called as soon as Serve is stopped, before
+	// DrainDelay starts.
+	Draining func()
+}
+
+// Server is an HTTP server that shuts down gracefully.
+type Server struct {
+	http            *http.Server
+	handler         atomic.Pointer[http.Handler]
+	shutdownTimeout time.Duration
+	drainDelay      time.Duration
+	draining        func()
+	logger          *slog.Logger
+}
+
+// New returns a server for handler. Errors the HTTP server itself runs into,
+// such as TLS handshake failures, are logged to logger as warnings.
+func New(opts Options, handler http.Handler, logger *slog.Logger) *Server {
+	s := &Server{
+		shutdownTimeout: opts.ShutdownTimeout,
+		drainDelay:      opts.DrainDelay,
+		draining:        opts.Draining,
+		logger:          logger,
+	}
+	s.http = &http.Server{
+		Addr:              opts.Addr,
+		Handler:           http.HandlerFunc(s.serveHTTP),
+		ReadHeaderTimeout: opts.ReadHeaderTimeout,
+		ReadTimeout:       opts.ReadTimeout,
+		WriteTimeout:      opts.WriteTimeout,
+		IdleTimeout:       opts.IdleTimeout,
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+	}
+	s.SetHandler(handler)
+	return s
+}
+
+// SetHandler replaces the handler new requests are dispatched to, for
+// example once startup work the full API depends on has finished. It is
+// safe to call while serving.
+func (s *Server) SetHandler(handler http.Handler) {
+	s.handler.Store(&handler)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	(*s.handler.Load()).ServeHTTP(w, r)
+}
+
+// Run listens on the configured address and serves until ctx is done; see
+// Serve.
+func (s *Server) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.http.Addr)
+	if err != nil {
+		return fmt.Errorf("server: %w", err)
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve accepts connections on ln until ctx is done, then shuts down: after
+// the drain delay ln is closed, idle connections are dropped and requests in
+// flight get up to the shutdown timeout to finish before their connections
+// are closed too. It returns nil after a clean shutdown.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	s.logger.Info("server running", "addr", ln.Addr().String())
+	errc := make(chan error, 1)
+	go func() { errc <- s.http.Serve(ln) }()
+
+	select {
+	case err := <-errc:
+		return fmt.Errorf("server: %w", err)
+	case <-ctx.Done():
+	}
+
+	if s.draining != nil {
+		s.draining()
+	}
+	if s.drainDelay > 0 {
+		s.logger.Info("draining", "delay", s.drainDelay)
+		time.Sleep(s.drainDelay)
+	}
+	s.logger.Info("shutting down", "timeout", s.shutdownTimeout)
+	shutdownCtx := context.Background()
+	if s.shutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		shutdownCtx, cancel = context.WithTimeout(shutdownCtx, s.shutdownTimeout)
+		defer cancel()
+	}
+	if err := s.http.Shutdown(shutdownCtx); err != nil {
+		s.http.Close()
+		return fmt.Errorf("server: requests still running after %s: %w", s.shutdownTimeout, err)
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("server: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type ServerTestSuite struct {
+	suite.Suite
+	Listener net.Listener
+	URL      string
+}
+
+func TestServerTestSuite(t *testing.T) {
+	suite.Run(t, new(ServerTestSuite))
+}
+
+func (suite *ServerTestSuite) SetupTest() {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(suite.T(), err)
+	suite.Listener = ln
+	suite.URL = "http://" + ln.Addr().String()
+}
+
+func (suite *ServerTestSuite) start(opts Options, handler http.Handler) (context.CancelFunc, <-chan error) {
+	srv := New(opts, handler, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ctx, suite.Listener) }()
+	return cancel, done
+}
+
+func (suite *ServerTestSuite) TestDrainsRequestsInFlight() {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	stop, done := suite.start(Options{ShutdownTimeout: 5 * time.Second}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	}))
+
+	result := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(suite.URL)
+		if err != nil {
+			result <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		result <- string(body)
+	}()
+	<-started
+	stop()
+
+	select {
+	case err := <-done:
+		suite.T().Fatalf("Serve returned before the request finished: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	_, err := net.Dial("tcp", suite.Listener.Addr().String())
+	assert.Error(suite.T(), err, "New connections are refused while draining")
+
+	close(release)
+	assert.Equal(suite.T(), "done", <-result)
+	assert.NoError(suite.T(), <-done)
+}
+
+func (suite *ServerTestSuite) TestShutdownTimeout() {
+	started := make(chan struct{})
+	stop, done := suite.start(Options{ShutdownTimeout: 20 * time.Millisecond}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	}))
+
+	go http.Get(suite.URL)
+	<-started
+	stop()
+
+	assert.ErrorIs(suite.T(), <-done, context.DeadlineExceeded)
+}
+
+func (suite *ServerTestSuite) TestReadHeaderTimeout() {
+	stop, done := suite.start(Options{ReadHeaderTimeout: 20 * time.Millisecond}, http.NotFoundHandler())
+	defer func() {
+		stop()
+		<-done
+	}()
+
+	conn, err := net.Dial("tcp", suite.Listener.Addr().String())
+	require.NoError(suite.T(), err)
+	defer conn.Close()
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: x\r\n")
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err = io.ReadAll(conn)
+	assert.NoError(suite.T(), err, "The server hangs up on clients that never finish their headers")
+}
+
+func (suite *ServerTestSuite) TestDrainDelayKeepsServing() {
+	draining := make(chan struct{})
+	stop, done := suite.start(Options{
+		DrainDelay: 100 * time.Millisecond,
+		Draining:   func() { close(draining) },
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+
+	stop()
+	<-draining
+	resp, err := http.Get(suite.URL)
+	require.NoError(suite.T(), err, "Requests are still served during the drain delay")
+	resp.Body.Close()
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	assert.NoError(suite.T(), <-done)
+}
+
+func (suite *ServerTestSuite) TestSetHandler() {
+	srv := New(Options{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ctx, suite.Listener) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	status := func() int {
+		resp, err := http.Get(suite.URL)
+		require.NoError(suite.T(), err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	assert.Equal(suite.T(), http.StatusServiceUnavailable, status())
+	srv.SetHandler(http.NotFoundHandler())
+	assert.Equal(suite.T(), http.StatusNotFound, status())
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"url-shortener/internal/storage"
+)
+
+const (
+	MinAliasLength = 3
+	MaxAliasLength = 32
+)
+
+// DefaultReservedAliases are route names and words that may never be used
+// as an alias.
+var DefaultReservedAliases = []string{
+	"admin", "api", "healthz", "links", "metrics", "openapi.json", "r", "readyz", "shorten", "t",
+}
+
+var (
+	// ErrInvalidAlias is returned for aliases with bad characters or length.
+	ErrInvalidAlias = errors.New("service: invalid alias")
+	// ErrReservedAlias is returned for aliases on the reserved list.
+	ErrReservedAlias = errors.New("service: alias is reserved")
+	// ErrAliasTaken is returned when an alias already points elsewhere.
+	ErrAliasTaken = errors.New("service: alias already in use")
+)
+
+// WithReservedAliases replaces DefaultReservedAliases.
+func WithReservedAliases(words []string) Option {
+	return func(s *URLService) {
+		s.reserved = reservedSet(words)
+	}
+}
+
+func reservedSet(words []string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[strings.ToLower(w)] = true
+	}
+	return set
+}
+
+// ValidateAlias checks alias against the allowed character set, length
+// limits and reserved words.
+func (s *URLService) ValidateAlias(alias string) error {
+	if len(alias) < MinAliasLength || len(alias) > MaxAliasLength {
+		return fmt.Errorf("%w: must be %d to %d characters", ErrInvalidAlias, MinAliasLength, MaxAliasLength)
+	}
+	for _, c := range alias {
+		if !isAliasChar(c) {
+			return fmt.Errorf("%w: %q is not allowed, use letters, digits, '-' or '_'", ErrInvalidAlias, c)
+		}
+	}
+	if s.reserved[strings.ToLower(alias)] {
+		return fmt.Errorf("%w: %q", ErrReservedAlias, alias)
+	}
+	return nil
+}
+
+func isAliasChar(c rune) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_'
+}
+
+// claimAlias checks whether link can be stored under the user-chosen alias.
+// It reports false when the alias already maps to the same target, making
+// the request a no-op. stored holds the links already looked up by short
+// code. Callers hold s.mu.
+func (s *URLService) claimAlias(link storage.Link, alias string, stored map[string]storage.Link) (free bool, err error) {
+	if err := s.ValidateAlias(alias); err != nil {
+		return false, err
+	}
+	existing, exists := stored[alias]
+	switch {
+	case !exists:
+		return true, nil
+	case existing.Deleted() || existing.Expired(s.now()):
+		// Deleted and expired aliases stay reserved until they are reaped,
+		// just as they count toward WithMaxLinks: deleted ones can still be
+		// restored and expired ones keep answering 410 Gone.
+		return false, fmt.Errorf("%w: %q", ErrAliasTaken, alias)
+	case sameTarget(existing, link):
+		return false, nil
+	}
+	return false, fmt.Errorf("%w: %q", ErrAliasTaken, alias)
+}
+
+// sameTarget reports whether a and b belong to the same owner and would
+// behave identically as links.
+func sameTarget(a, b storage.Link) bool {
+	return a.DedupKey() == b.DedupKey() && a.Redirect == b.Redirect && slices.Equal(a.Tags, b.Tags)
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type AliasTestSuite struct {
+	suite.Suite
+	Service *URLService
+	Store   *mockStore
+}
+
+func TestAliasTestSuite(t *testing.T) {
+	suite.Run(t, new(AliasTestSuite))
+}
+
+func (suite *AliasTestSuite) SetupTest() {
+	suite.Store = newMockStore()
+	suite.Service = NewURLService(suite.Store)
+}
+
+func (suite *AliasTestSuite) TestShortenWithAlias() {
+	result, err := suite.Service.ShortenURL("https://example.com/sale", ShortenOptions{Alias: "summer-sale"})
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "summer-sale", result)
+	assert.Equal(suite.T(), "https://example.com/sale", suite.Store.ShortToURL["summer-sale"].Original)
+	assert.True(suite.T(), suite.Store.ShortToURL["summer-sale"].Custom)
+	assert.Equal(suite.T(), 1, suite.Store.DomainCounts["example.com"])
+}
+
+func (suite *AliasTestSuite) TestAliasIsIdempotentForSameURL() {
+	_, err := suite.Service.ShortenURL("https://example.com", ShortenOptions{Alias: "home"})
+	require.NoError(suite.T(), err)
+
+	result, err := suite.Service.ShortenURL("https://example.com", ShortenOptions{Alias: "home"})
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "home", result)
+	assert.Equal(suite.T(), 1, suite.Store.DomainCounts["example.com"], "Repeating an alias should not count twice")
+}
+
+func (suite *AliasTestSuite) TestAliasTakenByDifferentURL() {
+	_, err := suite.Service.ShortenURL("https://example.com", ShortenOptions{Alias: "home"})
+	require.NoError(suite.T(), err)
+
+	result, err := suite.Service.ShortenURL("https://other.com", ShortenOptions{Alias: "home"})
+
+	assert.ErrorIs(suite.T(), err, ErrAliasTaken)
+	assert.Empty(suite.T(), result)
+	assert.Equal(suite.T(), "https://example.com", suite.Store.ShortToURL["home"].Original)
+}
+
+func (suite *AliasTestSuite) TestAliasCollidesWithGeneratedCode() {
+	code, err := suite.Service.ShortenURL("https://example.com", ShortenOptions{})
+	require.NoError(suite.T(), err)
+
+	_, err = suite.Service.ShortenURL("https://other.com", ShortenOptions{Alias: code})
+
+	assert.ErrorIs(suite.T(), err, ErrAliasTaken)
+}
+
+func (suite *AliasTestSuite) TestAliasDoesNotReplaceGeneratedCode() {
+	code, err := suite.Service.ShortenURL("https://example.com", ShortenOptions{})
+	require.NoError(suite.T(), err)
+	_, err = suite.Service.ShortenURL("https://example.com", ShortenOptions{Alias: "home"})
+	require.NoError(suite.T(), err)
+
+	again, err := suite.Service.ShortenURL("https://example.com", ShortenOptions{})
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), code, again, "Plain shortening should keep returning the generated code")
+}
+
+func (suite *AliasTestSuite) TestValidateAlias() {
+	cases := map[string]error{
+		"ok-alias_1":            nil,
+		"ab":                    ErrInvalidAlias,
+		strings.Repeat("a", 33): ErrInvalidAlias,
+		"has space":             ErrInvalidAlias,
+		"slash/alias":           ErrInvalidAlias,
+		"émoji":                 ErrInvalidAlias,
+		"metrics":               ErrReservedAlias,
+		"Shorten":               ErrReservedAlias,
+		"ADMIN":                 ErrReservedAlias,
+	}
+	for alias, expected := range cases {
+		err := suite.Service.ValidateAlias(alias)
+		if expected == nil {
+			assert.NoError(suite.T(), err, alias)
+		} else {
+			assert.ErrorIs(suite.T(), err, expected, alias)
+		}
+	}
+}
+
+func (suite *AliasTestSuite) TestCustomReservedWords() {
+	svc := NewURLService(suite.Store, WithReservedAliases([]string{"promo"}))
+
+	_, err := svc.ShortenURL("https://example.com", ShortenOptions{Alias: "promo"})
+	assert.ErrorIs(suite.T(), err, ErrReservedAlias)
+
+	_, err = svc.ShortenURL("https://example.com", ShortenOptions{Alias: "metrics"})
+	assert.NoError(suite.T(), err)
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"log/slog"
+	"net/url"
+	"sync"
+	"sync/atomic"
+
+	"url-shortener/internal/storage"
+)
+
+const (
+	// DefaultClickBuffer is how many clicks may wait for the recorder before
+	// new ones are dropped.
+	DefaultClickBuffer = 10000
+	// clickBatch caps how many queued clicks are written in one store call.
+	clickBatch = 256
+)
+
+// WithClickBuffer replaces DefaultClickBuffer.
+func WithClickBuffer(n int) Option {
+	return func(s *URLService) {
+		s.clickBuffer = n
+	}
+}
+
+type clickMsg struct {
+	click   storage.Click
+	flushed chan struct{}
+}
+
+// ClickRecorder writes clicks to the store on a background goroutine so the
+// redirect path never waits on analytics. When the buffer is full clicks are
+// dropped and counted rather than blocking the caller.
+type ClickRecorder struct {
+	store   storage.Repository
+	queue   chan clickMsg
+	dropped atomic.Int64
+	done    chan struct{}
+	close   sync.Once
+}
+
+// NewClickRecorder starts a recorder that buffers up to buffer clicks.
+func NewClickRecorder(store storage.Repository, buffer int) *ClickRecorder {
+	if buffer <= 0 {
+		buffer = DefaultClickBuffer
+	}
+	r := &ClickRecorder{
+		store: store,
+		queue: make(chan clickMsg, buffer),
+		done:  make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// Record queues c without blocking and reports whether it was accepted.
+func (r *ClickRecorder) Record(c storage.Click) bool {
+	select {
+	case r.queue <- clickMsg{click: c}:
+		return true
+	default:
+		r.dropped.Add(1)
+		return false
+	}
+}
+
+// Dropped returns how many clicks were discarded because the buffer was full.
+func (r *ClickRecorder) Dropped() int64 {
+	return r.dropped.Load()
+}
+
+// Flush blocks until every click queued before the call has been written.
+func (r *ClickRecorder) Flush() {
+	flushed := make(chan struct{})
+	select {
+	case r.queue <- clickMsg{flushed: flushed}:
+		<-flushed
+	case <-r.done:
+	}
+}
+
+// Close writes any queued clicks and stops the recorder. Clicks recorded
+// after Close are dropped.
+func (r *ClickRecorder) Close() {
+	r.close.Do(func() {
+		r.Flush()
+		close(r.done)
+	})
+}
+
+func (r *ClickRecorder) run() {
+	batch := make([]storage.Click, 0, clickBatch)
+	for {
+		var msg clickMsg
+		select {
+		case msg = <-r.queue:
+		case <-r.done:
+			return
+		}
+
+		var flushes []chan struct{}
+		for {
+			if msg.flushed != nil {
+				flushes = append(flushes, msg.flushed)
+			} else {
+				batch = append(batch, msg.click)
+			}
+			if len(batch) == clickBatch {
+				break
+			}
+			select {
+			case msg = <-r.queue:
+				continue
+			default:
+			}
+			break
+		}
+
+		if len(batch) > 0 {
+			if err := r.store.RecordClicks(batch); err != nil {
+				slog.Error("analytics: recording clicks", "clicks", len(batch), "err", err)
+			}
+			batch = batch[:0]
+		}
+		for _, f := range flushes {
+			close(f)
+		}
+	}
+}
+
+// RecordClick queues a click on short for asynchronous recording. Only the
+// host of the referrer is kept.
+func (s *URLService) RecordClick(short, referrer, userAgent string) {
+	if u, err := url.Parse(referrer); err == nil && u.Host != "" {
+		referrer = u.Hostname()
+	}
+	s.clicks.Record(storage.Click{Short: short, At: s.now(), Referrer: referrer, UserAgent: userAgent})
+}
+
+// GetLinkStats returns the click statistics for short, or ErrLinkNotFound
+// if it does not exist or caller does not own it.
+func (s *URLService) GetLinkStats(caller Caller, short string) (storage.LinkStats, error) {
+	if link, exists := s.store.GetByShort(short); !exists || link.Deleted() || !caller.owns(link) {
+		return storage.LinkStats{}, ErrLinkNotFound
+	}
+	stats, exists := s.store.Stats(short)
+	if !exists {
+		return storage.LinkStats{}, ErrLinkNotFound
+	}
+	return stats, nil
+}
+
+// Close flushes pending analytics and stops background work.
+func (s *URLService) Close() {
+	s.clicks.Close()
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"url-shortener/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type AnalyticsTestSuite struct {
+	suite.Suite
+	Service *URLService
+	Store   *mockStore
+}
+
+func TestAnalyticsTestSuite(t *testing.T) {
+	suite.Run(t, new(AnalyticsTestSuite))
+}
+
+func (suite *AnalyticsTestSuite) SetupTest() {
+	suite.Store = newMockStore()
+	suite.Service = NewURLService(suite.Store)
+}
+
+func (suite *AnalyticsTestSuite) TearDownTest() {
+	suite.Service.Close()
+}
+
+func (suite *AnalyticsTestSuite) TestRecordClickIsWrittenAfterFlush() {
+	suite.Service.RecordClick("abc123", "https://news.example/story?id=1", "curl/8.0")
+	suite.Service.clicks.Flush()
+
+	clicks := suite.Store.RecordedClicks()
+	require.Len(suite.T(), clicks, 1)
+	assert.Equal(suite.T(), "abc123", clicks[0].Short)
+	assert.Equal(suite.T(), "news.example", clicks[0].Referrer, "Only the referrer host should be kept")
+	assert.Equal(suite.T(), "curl/8.0", clicks[0].UserAgent)
+	assert.False(suite.T(), clicks[0].At.IsZero())
+}
+
+func (suite *AnalyticsTestSuite) TestCloseDrainsQueue() {
+	for i := 0; i < 1000; i++ {
+		suite.Service.RecordClick(fmt.Sprintf("c%d", i), "", "")
+	}
+
+	suite.Service.Close()
+
+	assert.Len(suite.T(), suite.Store.RecordedClicks(), 1000)
+}
+
+func (suite *AnalyticsTestSuite) TestRecorderDropsWhenFull() {
+	blocking := &blockingStore{mockStore: newMockStore(), release: make(chan struct{})}
+	recorder := NewClickRecorder(blocking, 1)
+
+	// The first click is picked up by the worker and blocks in the store;
+	// the second fills the buffer; the rest must be dropped, not block.
+	recorder.Record(storage.Click{Short: "a"})
+	assert.Eventually(suite.T(), func() bool { return len(recorder.queue) == 0 }, time.Second, time.Millisecond)
+	assert.True(suite.T(), recorder.Record(storage.Click{Short: "b"}))
+	assert.False(suite.T(), recorder.Record(storage.Click{Short: "c"}))
+	assert.False(suite.T(), recorder.Record(storage.Click{Short: "d"}))
+	assert.Equal(suite.T(), int64(2), recorder.Dropped())
+
+	close(blocking.release)
+	recorder.Close()
+}
+
+func (suite *AnalyticsTestSuite) TestGetLinkStats() {
+	suite.Store.ShortToURL["abc123"] = storage.Link{Short: "abc123", Original: "https://example.com"}
+	suite.Service.RecordClick("abc123", "", "")
+	suite.Service.clicks.Flush()
+
+	stats, err := suite.Service.GetLinkStats(admin, "abc123")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(1), stats.Clicks)
+
+	_, err = suite.Service.GetLinkStats(admin, "missing")
+	assert.ErrorIs(suite.T(), err, ErrLinkNotFound)
+}
+
+type blockingStore struct {
+	*mockStore
+	release chan struct{}
+}
+
+func (b *blockingStore) RecordClicks(clicks []storage.Click) error {
+	<-b.release
+	return b.mockStore.RecordClicks(clicks)
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"url-shortener/internal/storage"
+)
+
+// DefaultMaxBatchSize bounds how many URLs one ShortenBatch call accepts.
+const DefaultMaxBatchSize = 1000
+
+var (
+	// ErrBatchTooLarge is returned for batches over the configured size.
+	ErrBatchTooLarge = errors.New("service: too many urls in batch")
+	// ErrQuotaExceeded is returned for new links past the WithMaxLinks cap.
+	ErrQuotaExceeded = errors.New("service: link quota exceeded")
+)
+
+// WithMaxBatchSize replaces DefaultMaxBatchSize.
+func WithMaxBatchSize(n int) Option {
+	return func(s *URLService) {
+		s.maxBatch = n
+	}
+}
+
+// WithMaxLinks caps how many links the store may hold, deleted and expired
+// ones included until they are reaped. Zero, the default, means no cap.
+func WithMaxLinks(n int) Option {
+	return func(s *URLService) {
+		s.maxLinks = n
+	}
+}
+
+// BatchItem is one shorten request within a batch.
+type BatchItem struct {
+	URL     string
+	Options ShortenOptions
+}
+
+// BatchResult is the outcome of the BatchItem at the same index: a short
+// code or the error ShortenURL would have returned for it alone.
+type BatchResult struct {
+	Short string
+	Err   error
+}
+
+// pendingLink is a batch item waiting to be inserted. Aliases have no
+// generator input and are tried once.
+type pendingLink struct {
+	index   int
+	link    storage.Link
+	input   string
+	attempt int
+}
+
+// ShortenBatch shortens every item and returns results in input order.
+// Items are validated up front, then the whole batch is resolved under one
+// service lock: deduplicated against the store with a single lookup and
+// against earlier items in the same batch, inserted with one storage call
+// per collision round (usually one), and counted with a single domain
+// update.
+func (s *URLService) ShortenBatch(items []BatchItem) ([]BatchResult, error) {
+	if len(items) > s.maxBatch {
+		return nil, fmt.Errorf("%w: %d exceeds the limit of %d", ErrBatchTooLarge, len(items), s.maxBatch)
+	}
+	results := make([]BatchResult, len(items))
+	links := make([]storage.Link, len(items))
+	for i, item := range items {
+		links[i], results[i].Err = s.prepare(item.URL, item.Options)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byShort, byKey := s.lookup(items, links, results)
+	var pending []pendingLink
+	// leaders maps a destination key or alias to the item creating it, and
+	// followers maps later identical items to their leader.
+	leaders := make(map[string]int)
+	followers := make(map[int]int)
+	for i, item := range items {
+		if results[i].Err != nil {
+			continue
+		}
+		link := links[i]
+
+		if alias := item.Options.Alias; alias != "" {
+			if j, seen := leaders["alias:"+alias]; seen {
+				if sameTarget(links[j], link) {
+					followers[i] = j
+				} else {
+					results[i].Err = fmt.Errorf("%w: %q", ErrAliasTaken, alias)
+				}
+				continue
+			}
+			free, err := s.claimAlias(link, alias, byShort)
+			if err != nil {
+				results[i].Err = err
+				continue
+			}
+			if !free {
+				results[i].Short = alias
+				s.deduplicated.Add(1)
+				continue
+			}
+			leaders["alias:"+alias] = i
+			link.Short = alias
+			link.Custom = true
+			pending = append(pending, pendingLink{index: i, link: link})
+			continue
+		}
+
+		if link.Indexed() {
+			if existing, exists := byKey[link.DedupKey()]; exists {
+				results[i].Short = existing.Short
+				s.deduplicated.Add(1)
+				continue
+			}
+			if j, seen := leaders["key:"+link.DedupKey()]; seen {
+				followers[i] = j
+				continue
+			}
+			leaders["key:"+link.DedupKey()] = i
+		}
+		pending = append(pending, pendingLink{index: i, link: link, input: codeInput(link)})
+	}
+
+	pending = s.withinQuota(pending, results)
+	inserted := s.insertPending(pending, results)
+	s.countDomains(inserted)
+	for i, j := range followers {
+		results[i] = results[j]
+		if results[j].Err == nil {
+			s.deduplicated.Add(1)
+		}
+	}
+	return results, nil
+}
+
+// lookup fetches, in one storage call, the links stored under the aliases
+// and destinations the valid items ask for.
+func (s *URLService) lookup(items []BatchItem, links []storage.Link, results []BatchResult) (byShort, byKey map[string]storage.Link) {
+	var shorts, keys []string
+	for i, item := range items {
+		switch {
+		case results[i].Err != nil:
+		case item.Options.Alias != "":
+			shorts = append(shorts, item.Options.Alias)
+		case links[i].Indexed():
+			keys = append(keys, links[i].DedupKey())
+		}
+	}
+	return s.store.LookupBatch(shorts, keys)
+}
+
+// withinQuota returns the pending links that fit under the link cap and
+// fails the rest with ErrQuotaExceeded.
+func (s *URLService) withinQuota(pending []pendingLink, results []BatchResult) []pendingLink {
+	if s.maxLinks <= 0 {
+		return pending
+	}
+	room := max(s.maxLinks-s.store.Len(), 0)
+	if len(pending) <= room {
+		return pending
+	}
+	for _, p := range pending[room:] {
+		results[p.index].Err = ErrQuotaExceeded
+	}
+	return pending[:room]
+}
+
+// insertPending inserts pending links, retrying generated codes that
+// collide with the next candidate, and records each outcome in results.
+// It returns the indexes of the items that were stored.
+func (s *URLService) insertPending(pending []pendingLink, results []BatchResult) []pendingLink {
+	var inserted []pendingLink
+	for len(pending) > 0 {
+		ready := make([]pendingLink, 0, len(pending))
+		for _, p := range pending {
+			if p.input != "" {
+				short, err := s.codes.Generate(p.input, p.attempt)
+				if err != nil {
+					results[p.index].Err = err
+					continue
+				}
+				p.link.Short = short
+				p.link.Generator = s.codesKind
+			}
+			ready = append(ready, p)
+		}
+		batch := make([]storage.Link, len(ready))
+		for k, p := range ready {
+			batch[k] = p.link
+		}
+
+		var retry []pendingLink
+		for k, err := range s.store.InsertBatch(batch) {
+			p := ready[k]
+			switch {
+			case err == nil:
+				results[p.index].Short = p.link.Short
+				inserted = append(inserted, p)
+			case !errors.Is(err, storage.ErrCodeTaken):
+				results[p.index].Err = err
+			case p.input == "":
+				results[p.index].Err = fmt.Errorf("%w: %q", ErrAliasTaken, p.link.Short)
+			case p.attempt+1 < maxCodeAttempts:
+				p.attempt++
+				retry = append(retry, p)
+			default:
+				results[p.index].Err = ErrCodeSpaceExhausted
+			}
+		}
+		pending = retry
+	}
+	return inserted
+}
+
+// countDomains bumps the shorten counters for newly stored links. The links
+// are stored either way, so a failed update is only logged and leaves the
+// leaderboard behind rather than failing their results.
+func (s *URLService) countDomains(inserted []pendingLink) {
+	if len(inserted) == 0 {
+		return
+	}
+	counts := make(map[string]int)
+	for _, p := range inserted {
+		counts[p.link.Domain()]++
+	}
+	s.created.Add(int64(len(inserted)))
+	totals, err := s.store.IncrementDomains(counts)
+	if err != nil {
+		slog.Error("counting shortened domains", "links", len(inserted), "err", err)
+		return
+	}
+	now := s.now()
+	for domain, n := range counts {
+		s.domains.record(domain, totals[domain], n, now)
+	}
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type BatchTestSuite struct {
+	suite.Suite
+	Service *URLService
+	Store   *mockStore
+}
+
+func TestBatchTestSuite(t *testing.T) {
+	suite.Run(t, new(BatchTestSuite))
+}
+
+func (suite *BatchTestSuite) SetupTest() {
+	suite.Store = newMockStore()
+	suite.Service = NewURLService(suite.Store)
+}
+
+func (suite *BatchTestSuite) TestResultsFollowInputOrder() {
+	items := []BatchItem{
+		{URL: "https://example.com/a"},
+		{URL: "ftp://example.com/file"},
+		{URL: "https://example.com/b", Options: ShortenOptions{Alias: "bee"}},
+	}
+
+	results, err := suite.Service.ShortenBatch(items)
+
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), results, 3)
+	assert.NoError(suite.T(), results[0].Err)
+	assert.Equal(suite.T(), "https://example.com/a", suite.Store.ShortToURL[results[0].Short].Original)
+	var verr *ValidationError
+	assert.ErrorAs(suite.T(), results[1].Err, &verr)
+	assert.Empty(suite.T(), results[1].Short)
+	assert.NoError(suite.T(), results[2].Err)
+	assert.Equal(suite.T(), "bee", results[2].Short)
+	assert.Equal(suite.T(), 2, suite.Store.DomainCounts["example.com"])
+}
+
+func (suite *BatchTestSuite) TestMatchesShortenURL() {
+	want, err := suite.Service.ShortenURL("https://example.com/page", ShortenOptions{})
+	require.NoError(suite.T(), err)
+
+	results, err := suite.Service.ShortenBatch([]BatchItem{{URL: "https://EXAMPLE.com/page"}})
+
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), want, results[0].Short)
+	assert.Equal(suite.T(), 1, suite.Store.DomainCounts["example.com"], "A deduplicated item should not count")
+}
+
+func (suite *BatchTestSuite) TestDuplicatesWithinBatchShareCode() {
+	results, err := suite.Service.ShortenBatch([]BatchItem{
+		{URL: "https://example.com/page"},
+		{URL: "https://example.com/page"},
+	})
+
+	require.NoError(suite.T(), err)
+	assert.NoError(suite.T(), results[1].Err)
+	assert.Equal(suite.T(), results[0].Short, results[1].Short)
+	assert.Equal(suite.T(), 1, suite.Store.DomainCounts["example.com"])
+	assert.Len(suite.T(), suite.Store.ShortToURL, 1)
+}
+
+func (suite *BatchTestSuite) TestAliasRepeatedWithinBatch() {
+	results, err := suite.Service.ShortenBatch([]BatchItem{
+		{URL: "https://example.com", Options: ShortenOptions{Alias: "home"}},
+		{URL: "https://example.com", Options: ShortenOptions{Alias: "home"}},
+		{URL: "https://other.com", Options: ShortenOptions{Alias: "home"}},
+	})
+
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "home", results[0].Short)
+	assert.Equal(suite.T(), "home", results[1].Short)
+	assert.NoError(suite.T(), results[1].Err)
+	assert.ErrorIs(suite.T(), results[2].Err, ErrAliasTaken)
+	assert.Equal(suite.T(), "https://example.com", suite.Store.ShortToURL["home"].Original)
+}
+
+func (suite *BatchTestSuite) TestStoreErrorIsPerItem() {
+	_, err := suite.Service.ShortenURL("https://example.com", ShortenOptions{Alias: "home"})
+	require.NoError(suite.T(), err)
+
+	results, err := suite.Service.ShortenBatch([]BatchItem{
+		{URL: "https://other.com", Options: ShortenOptions{Alias: "home"}},
+		{URL: "https://other.com"},
+	})
+
+	require.NoError(suite.T(), err)
+	assert.ErrorIs(suite.T(), results[0].Err, ErrAliasTaken)
+	assert.NoError(suite.T(), results[1].Err)
+	assert.Equal(suite.T(), 1, suite.Store.DomainCounts["other.com"])
+}
+
+func (suite *BatchTestSuite) TestOneLookupPerBatch() {
+	_, err := suite.Service.ShortenURL("https://example.com/a", ShortenOptions{Alias: "aye"})
+	require.NoError(suite.T(), err)
+	suite.Store.Lookups = 0
+
+	results, err := suite.Service.ShortenBatch([]BatchItem{
+		{URL: "https://example.com/a", Options: ShortenOptions{Alias: "aye"}},
+		{URL: "https://example.com/b"},
+		{URL: "https://example.com/c"},
+		{URL: "https://example.com/b"},
+	})
+
+	require.NoError(suite.T(), err)
+	for _, result := range results {
+		assert.NoError(suite.T(), result.Err)
+	}
+	assert.Equal(suite.T(), 1, suite.Store.Lookups)
+}
+
+func (suite *BatchTestSuite) TestDomainCountFailureKeepsLinks() {
+	suite.Store.DomainsErr = errors.New("disk full")
+
+	results, err := suite.Service.ShortenBatch([]BatchItem{
+		{URL: "https://example.com/a"},
+		{URL: "https://example.com/b", Options: ShortenOptions{Alias: "bee"}},
+	})
+
+	require.NoError(suite.T(), err)
+	for _, result := range results {
+		assert.NoError(suite.T(), result.Err)
+		assert.Contains(suite.T(), suite.Store.ShortToURL, result.Short)
+	}
+	assert.Equal(suite.T(), "bee", results[1].Short)
+}
+
+func (suite *BatchTestSuite) TestTooLarge() {
+	svc := NewURLService(suite.Store, WithMaxBatchSize(2))
+
+	results, err := svc.ShortenBatch(make([]BatchItem, 3))
+
+	assert.ErrorIs(suite.T(), err, ErrBatchTooLarge)
+	assert.Nil(suite.T(), results)
+	assert.Empty(suite.T(), suite.Store.ShortToURL)
+}
+
+func (suite *BatchTestSuite) TestMaxLinks() {
+	svc := NewURLService(suite.Store, WithMaxLinks(2))
+	_, err := svc.ShortenURL("https://example.com/a", ShortenOptions{})
+	require.NoError(suite.T(), err)
+
+	results, err := svc.ShortenBatch([]BatchItem{
+		{URL: "https://example.com/a"},
+		{URL: "https://example.com/b"},
+		{URL: "https://example.com/c"},
+		{URL: "https://example.com/c"},
+	})
+
+	require.NoError(suite.T(), err)
+	assert.NoError(suite.T(), results[0].Err, "Existing links do not count against the quota again")
+	assert.NoError(suite.T(), results[1].Err)
+	assert.ErrorIs(suite.T(), results[2].Err, ErrQuotaExceeded)
+	assert.ErrorIs(suite.T(), results[3].Err, ErrQuotaExceeded)
+	assert.Len(suite.T(), suite.Store.ShortToURL, 2)
+}
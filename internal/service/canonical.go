@@ -0,0 +1,179 @@
+package service
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// FragmentPolicy decides what canonicalization does with a URL fragment.
+type FragmentPolicy string
+
+const (
+	// FragmentKeep treats URLs that differ only by fragment as distinct.
+	FragmentKeep FragmentPolicy = "keep"
+	// FragmentStrip drops the fragment before deduplication.
+	FragmentStrip FragmentPolicy = "strip"
+)
+
+// DefaultTrackingParams is a ready-made strip list for common campaign and
+// click-tracking query parameters. A trailing '*' matches any suffix.
+var DefaultTrackingParams = []string{
+	"utm_*", "fbclid", "gclid", "dclid", "msclkid", "mc_cid", "mc_eid", "_ga", "yclid",
+}
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// CanonicalOptions configures how equivalent URLs are folded together.
+type CanonicalOptions struct {
+	// SortQuery orders query parameters by name so ?a=1&b=2 and ?b=2&a=1
+	// share a code.
+	SortQuery bool
+	// Fragment selects the fragment policy. Empty means FragmentKeep.
+	Fragment FragmentPolicy
+	// StripParams lists query parameters removed before deduplication.
+	StripParams []string
+	// PreserveOriginal redirects to the URL exactly as submitted instead of
+	// its canonical form. Deduplication always uses the canonical form.
+	PreserveOriginal bool
+}
+
+// WithCanonicalOptions replaces the default canonicalization settings.
+func WithCanonicalOptions(opts CanonicalOptions) Option {
+	return func(s *URLService) {
+		s.canonical = opts
+	}
+}
+
+// Canonicalize rewrites a validated absolute URL into a normal form:
+// lowercase scheme and host, no default port, consistent percent-encoding,
+// no empty query or bare root path, and the configured query and fragment
+// rules applied.
+func (o CanonicalOptions) Canonicalize(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	scheme := strings.ToLower(u.Scheme)
+	b.WriteString(scheme)
+	b.WriteString("://")
+	if u.User != nil {
+		b.WriteString(u.User.String())
+		b.WriteByte('@')
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	b.WriteString(host)
+	if port := u.Port(); port != "" && port != defaultPorts[scheme] {
+		b.WriteByte(':')
+		b.WriteString(port)
+	}
+
+	if path := normalizePercent(u.EscapedPath()); path != "/" {
+		b.WriteString(path)
+	}
+
+	if query := o.canonicalQuery(u.RawQuery); query != "" {
+		b.WriteByte('?')
+		b.WriteString(query)
+	}
+
+	if o.Fragment != FragmentStrip && u.Fragment != "" {
+		b.WriteByte('#')
+		b.WriteString(normalizePercent(u.EscapedFragment()))
+	}
+	return b.String(), nil
+}
+
+func (o CanonicalOptions) canonicalQuery(raw string) string {
+	var params []string
+	for _, param := range strings.Split(raw, "&") {
+		if param == "" {
+			continue
+		}
+		param = normalizePercent(param)
+		name, _, _ := strings.Cut(param, "=")
+		if decoded, err := url.QueryUnescape(name); err == nil {
+			name = decoded
+		}
+		if o.stripped(name) {
+			continue
+		}
+		params = append(params, param)
+	}
+	if o.SortQuery {
+		sort.SliceStable(params, func(i, j int) bool {
+			ni, _, _ := strings.Cut(params[i], "=")
+			nj, _, _ := strings.Cut(params[j], "=")
+			return ni < nj
+		})
+	}
+	return strings.Join(params, "&")
+}
+
+func (o CanonicalOptions) stripped(name string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range o.StripParams {
+		pattern = strings.ToLower(pattern)
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizePercent decodes percent-escapes of unreserved characters and
+// upper-cases the hex digits of every other escape (RFC 3986 section 6.2.2).
+func normalizePercent(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+			c := unhex(s[i+1])<<4 | unhex(s[i+2])
+			if isUnreserved(c) {
+				b.WriteByte(c)
+			} else {
+				b.WriteByte('%')
+				b.WriteString(strings.ToUpper(s[i+1 : i+3]))
+			}
+			i += 2
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func isUnreserved(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0'
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10
+	}
+	return c - 'A' + 10
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type CanonicalTestSuite struct {
+	suite.Suite
+}
+
+func TestCanonicalTestSuite(t *testing.T) {
+	suite.Run(t, new(CanonicalTestSuite))
+}
+
+func (suite *CanonicalTestSuite) TestDefaultCanonicalization() {
+	cases := map[string]string{
+		"https://example.com":                  "https://example.com",
+		"https://Example.com":                  "https://example.com",
+		"https://example.com/":                 "https://example.com",
+		"https://example.com/?":                "https://example.com",
+		"HTTPS://EXAMPLE.COM:443/":             "https://example.com",
+		"http://example.com:80/a":              "http://example.com/a",
+		"http://example.com:8080/a":            "http://example.com:8080/a",
+		"https://example.com/%7Euser/%2fx":     "https://example.com/~user/%2Fx",
+		"https://example.com/a?x=%41&&y=%3d":   "https://example.com/a?x=A&y=%3D",
+		"https://example.com/Path?b=2&a=1#Top": "https://example.com/Path?b=2&a=1#Top",
+		"http://[::1]:80/":                     "http://[::1]",
+		"https://user:pw@Example.com/":         "https://user:pw@example.com",
+	}
+	for raw, expected := range cases {
+		result, err := CanonicalOptions{}.Canonicalize(raw)
+
+		assert.NoError(suite.T(), err, raw)
+		assert.Equal(suite.T(), expected, result, raw)
+	}
+}
+
+func (suite *CanonicalTestSuite) TestSortQueryAndStripFragment() {
+	opts := CanonicalOptions{SortQuery: true, Fragment: FragmentStrip}
+
+	result, err := opts.Canonicalize("https://example.com/p?b=2&a=1&a=0#section")
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "https://example.com/p?a=1&a=0&b=2", result, "Sorting should be stable for repeated names")
+}
+
+func (suite *CanonicalTestSuite) TestStripTrackingParams() {
+	opts := CanonicalOptions{StripParams: DefaultTrackingParams}
+
+	result, err := opts.Canonicalize("https://example.com/p?utm_source=x&id=7&UTM_Medium=y&fbclid=abc&utm%5Fterm=z")
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "https://example.com/p?id=7", result)
+}
+
+func (suite *CanonicalTestSuite) TestEquivalentURLsDeduplicate() {
+	store := newMockStore()
+	svc := NewURLService(store)
+
+	first, err := svc.ShortenURL("https://Example.com", ShortenOptions{})
+	require.NoError(suite.T(), err)
+	second, err := svc.ShortenURL("https://example.com/", ShortenOptions{})
+	require.NoError(suite.T(), err)
+	third, err := svc.ShortenURL("https://example.com/?", ShortenOptions{})
+	require.NoError(suite.T(), err)
+
+	assert.Equal(suite.T(), first, second)
+	assert.Equal(suite.T(), first, third)
+	assert.Len(suite.T(), store.ShortToURL, 1)
+	assert.Equal(suite.T(), 1, store.DomainCounts["example.com"], "Equivalent URLs should not inflate domain hits")
+	assert.Equal(suite.T(), "https://example.com", store.ShortToURL[first].Original)
+}
+
+func (suite *CanonicalTestSuite) TestPreserveOriginalForRedirect() {
+	store := newMockStore()
+	svc := NewURLService(store, WithCanonicalOptions(CanonicalOptions{
+		StripParams:      []string{"utm_*"},
+		PreserveOriginal: true,
+	}))
+
+	first, err := svc.ShortenURL("https://Example.com/sale?utm_source=mail", ShortenOptions{})
+	require.NoError(suite.T(), err)
+	second, err := svc.ShortenURL("https://example.com/sale", ShortenOptions{})
+	require.NoError(suite.T(), err)
+
+	assert.Equal(suite.T(), first, second)
+	link := store.ShortToURL[first]
+	assert.Equal(suite.T(), "https://Example.com/sale?utm_source=mail", link.Original, "Redirect target should be the submitted URL")
+	assert.Equal(suite.T(), "https://example.com/sale", link.Canonical)
+
+	target, err := svc.GetOriginalURL(first)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "https://Example.com/sale?utm_source=mail", target)
+}
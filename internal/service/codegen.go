@@ -0,0 +1,258 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"url-shortener/internal/storage"
+)
+
+// Base62Alphabet is the default alphabet for counter, random and snowflake codes.
+const Base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// CodeGenerator produces short code candidates for a URL.
+type CodeGenerator interface {
+	// Generate returns the candidate for the given attempt. attempt starts at
+	// 0 and increases each time the previous candidate was already taken.
+	Generate(original string, attempt int) (string, error)
+}
+
+// Generator kinds accepted by NewCodeGenerator.
+const (
+	GeneratorHash      = "hash"
+	GeneratorCounter   = "counter"
+	GeneratorRandom    = "random"
+	GeneratorSnowflake = "snowflake"
+)
+
+// GeneratorConfig selects and parameterises a CodeGenerator.
+type GeneratorConfig struct {
+	// Kind is one of the Generator* constants. Empty means GeneratorHash.
+	Kind string
+	// Length is the code length for hash and random codes.
+	Length int
+	// Alphabet overrides Base62Alphabet for random codes.
+	Alphabet string
+	// CounterStart is the first value handed out by the counter generator.
+	CounterStart uint64
+	// NodeID identifies this replica for snowflake codes, 0..MaxSnowflakeNode.
+	NodeID int64
+}
+
+// NewCodeGenerator builds the generator described by cfg.
+func NewCodeGenerator(cfg GeneratorConfig) (CodeGenerator, error) {
+	switch cfg.Kind {
+	case "", GeneratorHash:
+		return &HashGenerator{Hash: md5Hash, Length: cfg.Length}, nil
+	case GeneratorCounter:
+		return NewCounterGenerator(cfg.CounterStart), nil
+	case GeneratorRandom:
+		return NewRandomGenerator(cfg.Length, cfg.Alphabet)
+	case GeneratorSnowflake:
+		return NewSnowflakeGenerator(cfg.NodeID)
+	}
+	return nil, fmt.Errorf("service: unknown code generator %q", cfg.Kind)
+}
+
+// generatorKind returns the Generator* constant for the built-in generators
+// and "" for any other.
+func generatorKind(g CodeGenerator) string {
+	switch g.(type) {
+	case *HashGenerator:
+		return GeneratorHash
+	case *CounterGenerator:
+		return GeneratorCounter
+	case *RandomGenerator:
+		return GeneratorRandom
+	case *SnowflakeGenerator:
+		return GeneratorSnowflake
+	}
+	return ""
+}
+
+// HashGenerator derives codes from a digest of the URL, so the same URL
+// always probes the same sequence of candidates. Attempt 0 is the plain
+// digest prefix; later attempts salt the input and, every saltsPerLength
+// attempts, lengthen the code by one character.
+type HashGenerator struct {
+	Hash   HashFunc
+	Length int
+}
+
+func (g *HashGenerator) Generate(original string, attempt int) (string, error) {
+	base := g.Length
+	if base <= 0 {
+		base = defaultCodeLength
+	}
+	length := base + attempt/saltsPerLength
+	input := original
+	if salt := attempt % saltsPerLength; salt > 0 {
+		input = fmt.Sprintf("%s#%d", original, salt)
+	}
+	code := hex.EncodeToString(g.Hash([]byte(input)))
+	if length > len(code) {
+		length = len(code)
+	}
+	return code[:length], nil
+}
+
+// CounterGenerator hands out a monotonically increasing base62 counter,
+// giving the shortest possible codes. It is only unique within one process;
+// use the snowflake generator when several replicas share a store.
+type CounterGenerator struct {
+	next atomic.Uint64
+}
+
+func NewCounterGenerator(start uint64) *CounterGenerator {
+	g := &CounterGenerator{}
+	g.next.Store(start)
+	return g
+}
+
+func (g *CounterGenerator) Generate(string, int) (string, error) {
+	return encodeBase62(g.next.Add(1) - 1), nil
+}
+
+// CounterStart returns the value a counter generator resumes from over the
+// stored links: one past the highest code a counter generator made, read as
+// a base62 counter. Counting the links instead would hand out taken codes
+// once some have been deleted. Aliases and codes from other generators are
+// ignored; a hash or snowflake code would otherwise jump the counter to a
+// huge value.
+func CounterStart(links []storage.Link) uint64 {
+	var start uint64
+	for _, link := range links {
+		if link.Generator != GeneratorCounter {
+			continue
+		}
+		if n, ok := decodeBase62(link.Short); ok && n >= start {
+			start = n + 1
+		}
+	}
+	return start
+}
+
+// RandomGenerator draws codes uniformly from an alphabet using crypto/rand.
+type RandomGenerator struct {
+	length   int
+	alphabet string
+}
+
+func NewRandomGenerator(length int, alphabet string) (*RandomGenerator, error) {
+	if length <= 0 {
+		length = defaultCodeLength
+	}
+	if alphabet == "" {
+		alphabet = Base62Alphabet
+	}
+	if len(alphabet) < 2 {
+		return nil, errors.New("service: random alphabet needs at least two characters")
+	}
+	return &RandomGenerator{length: length, alphabet: alphabet}, nil
+}
+
+func (g *RandomGenerator) Generate(string, int) (string, error) {
+	max := big.NewInt(int64(len(g.alphabet)))
+	code := make([]byte, g.length)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = g.alphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// Snowflake layout: 41 bits of milliseconds since snowflakeEpoch, 10 bits of
+// node ID and 12 bits of per-millisecond sequence. Distinct node IDs make
+// codes unique across replicas without any coordination.
+const (
+	snowflakeNodeBits = 10
+	snowflakeSeqBits  = 12
+	// MaxSnowflakeNode is the largest node ID a snowflake generator accepts.
+	MaxSnowflakeNode = 1<<snowflakeNodeBits - 1
+	snowflakeMaxSeq  = 1<<snowflakeSeqBits - 1
+)
+
+var snowflakeEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// SnowflakeGenerator produces time-ordered base62 IDs.
+type SnowflakeGenerator struct {
+	node int64
+	now  func() time.Time
+
+	mu   sync.Mutex
+	last int64
+	seq  int64
+}
+
+func NewSnowflakeGenerator(node int64) (*SnowflakeGenerator, error) {
+	if node < 0 || node > MaxSnowflakeNode {
+		return nil, fmt.Errorf("service: snowflake node id %d out of range 0..%d", node, MaxSnowflakeNode)
+	}
+	return &SnowflakeGenerator{node: node, now: time.Now}, nil
+}
+
+func (g *SnowflakeGenerator) Generate(string, int) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := g.now().Sub(snowflakeEpoch).Milliseconds()
+	// Never go backwards, even if the wall clock does.
+	if ms < g.last {
+		ms = g.last
+	}
+	if ms == g.last {
+		g.seq = (g.seq + 1) & snowflakeMaxSeq
+		if g.seq == 0 {
+			// Sequence exhausted for this millisecond; borrow the next one.
+			ms++
+		}
+	} else {
+		g.seq = 0
+	}
+	g.last = ms
+
+	id := ms<<(snowflakeNodeBits+snowflakeSeqBits) | g.node<<snowflakeSeqBits | g.seq
+	return encodeBase62(uint64(id)), nil
+}
+
+// decodeBase62 is the inverse of encodeBase62. It reports false for codes
+// with other characters, leading zeros or values beyond a uint64.
+func decodeBase62(code string) (uint64, bool) {
+	if code == "" || (len(code) > 1 && code[0] == Base62Alphabet[0]) {
+		return 0, false
+	}
+	var n uint64
+	for i := 0; i < len(code); i++ {
+		digit := strings.IndexByte(Base62Alphabet, code[i])
+		if digit < 0 || n > (math.MaxUint64-uint64(digit))/62 {
+			return 0, false
+		}
+		n = n*62 + uint64(digit)
+	}
+	return n, true
+}
+
+func encodeBase62(n uint64) string {
+	if n == 0 {
+		return Base62Alphabet[:1]
+	}
+	var buf [11]byte
+	i := len(buf)
+	for n > 0 {
+		i--
+		buf[i] = Base62Alphabet[n%62]
+		n /= 62
+	}
+	return string(buf[i:])
+}
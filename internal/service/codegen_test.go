@@ -0,0 +1,210 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"url-shortener/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type CodeGeneratorTestSuite struct {
+	suite.Suite
+}
+
+func TestCodeGeneratorTestSuite(t *testing.T) {
+	suite.Run(t, new(CodeGeneratorTestSuite))
+}
+
+func (suite *CodeGeneratorTestSuite) TestNewCodeGeneratorKinds() {
+	for _, kind := range []string{"", GeneratorHash, GeneratorCounter, GeneratorRandom, GeneratorSnowflake} {
+		gen, err := NewCodeGenerator(GeneratorConfig{Kind: kind})
+		assert.NoError(suite.T(), err, "kind %q", kind)
+		assert.NotNil(suite.T(), gen, "kind %q", kind)
+	}
+
+	_, err := NewCodeGenerator(GeneratorConfig{Kind: "uuid"})
+	assert.Error(suite.T(), err)
+}
+
+func (suite *CodeGeneratorTestSuite) TestHashGeneratorLength() {
+	gen := &HashGenerator{Hash: md5Hash, Length: 8}
+
+	code, err := gen.Generate("https://example.com", 0)
+
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), code, 8)
+}
+
+func (suite *CodeGeneratorTestSuite) TestCounterGeneratorIsMonotonic() {
+	gen := NewCounterGenerator(0)
+
+	var codes []string
+	for i := 0; i < 63; i++ {
+		code, err := gen.Generate("", 0)
+		require.NoError(suite.T(), err)
+		codes = append(codes, code)
+	}
+
+	assert.Equal(suite.T(), "0", codes[0])
+	assert.Equal(suite.T(), "z", codes[61])
+	assert.Equal(suite.T(), "10", codes[62])
+}
+
+func (suite *CodeGeneratorTestSuite) TestCounterGeneratorStart() {
+	gen := NewCounterGenerator(62 * 62)
+
+	code, err := gen.Generate("", 0)
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "100", code)
+}
+
+func (suite *CodeGeneratorTestSuite) TestRandomGeneratorUsesAlphabet() {
+	gen, err := NewRandomGenerator(12, "ab")
+	require.NoError(suite.T(), err)
+
+	code, err := gen.Generate("", 0)
+
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), code, 12)
+	assert.Empty(suite.T(), strings.Trim(code, "ab"), "Code should only contain alphabet characters")
+}
+
+func (suite *CodeGeneratorTestSuite) TestRandomGeneratorRejectsTinyAlphabet() {
+	_, err := NewRandomGenerator(6, "a")
+
+	assert.Error(suite.T(), err)
+}
+
+func (suite *CodeGeneratorTestSuite) TestSnowflakeRejectsBadNode() {
+	_, err := NewSnowflakeGenerator(MaxSnowflakeNode + 1)
+	assert.Error(suite.T(), err)
+	_, err = NewSnowflakeGenerator(-1)
+	assert.Error(suite.T(), err)
+}
+
+func (suite *CodeGeneratorTestSuite) TestSnowflakeUniqueAcrossNodes() {
+	fixed := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	seen := make(map[string]bool)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for node := int64(0); node < 4; node++ {
+		gen, err := NewSnowflakeGenerator(node)
+		require.NoError(suite.T(), err)
+		// A frozen clock forces every ID into the same millisecond, so
+		// uniqueness must come from node and sequence bits.
+		gen.now = func() time.Time { return fixed }
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 5000; i++ {
+				code, err := gen.Generate("", 0)
+				assert.NoError(suite.T(), err)
+				mu.Lock()
+				assert.False(suite.T(), seen[code], "duplicate code %s", code)
+				seen[code] = true
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+	assert.Len(suite.T(), seen, 20000)
+}
+
+func (suite *CodeGeneratorTestSuite) TestSnowflakeSurvivesClockRollback() {
+	gen, err := NewSnowflakeGenerator(1)
+	require.NoError(suite.T(), err)
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	gen.now = func() time.Time { return now }
+
+	first, err := gen.Generate("", 0)
+	require.NoError(suite.T(), err)
+	now = now.Add(-time.Second)
+	second, err := gen.Generate("", 0)
+	require.NoError(suite.T(), err)
+
+	assert.NotEqual(suite.T(), first, second)
+}
+
+func (suite *CodeGeneratorTestSuite) TestServiceSkipsTakenCounterCodes() {
+	store := newMockStore()
+	svc := NewURLService(store, WithCodeGenerator(NewCounterGenerator(0)))
+	_, err := svc.ShortenURL("https://first.com", ShortenOptions{})
+	require.NoError(suite.T(), err)
+
+	restarted := NewURLService(store, WithCodeGenerator(NewCounterGenerator(0)))
+	code, err := restarted.ShortenURL("https://second.com", ShortenOptions{})
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "1", code, "A restarted counter should skip codes already in use")
+	assert.Equal(suite.T(), "https://first.com", store.ShortToURL["0"].Original)
+}
+
+func (suite *CodeGeneratorTestSuite) TestBase62RoundTrip() {
+	for _, n := range []uint64{0, 1, 61, 62, 62 * 62, math.MaxUint64} {
+		decoded, ok := decodeBase62(encodeBase62(n))
+		assert.True(suite.T(), ok, n)
+		assert.Equal(suite.T(), n, decoded)
+	}
+	for _, code := range []string{"", "00", "a-b", "zzzzzzzzzzzz"} {
+		_, ok := decodeBase62(code)
+		assert.False(suite.T(), ok, code)
+	}
+}
+
+func (suite *CodeGeneratorTestSuite) TestCounterStartAfterDeletes() {
+	store := newMockStore()
+	svc := NewURLService(store, WithCodeGenerator(NewCounterGenerator(CounterStart(store.List()))))
+	var codes []string
+	for i := 0; i < 100; i++ {
+		code, err := svc.ShortenURL(fmt.Sprintf("https://example.com/%d", i), ShortenOptions{})
+		require.NoError(suite.T(), err)
+		codes = append(codes, code)
+	}
+	for _, code := range codes[:40] {
+		_, err := store.Delete(code)
+		require.NoError(suite.T(), err)
+	}
+	_, err := svc.ShortenURL("https://example.com/alias", ShortenOptions{Alias: "zzzz"})
+	require.NoError(suite.T(), err)
+
+	assert.Equal(suite.T(), uint64(100), CounterStart(store.List()), "Aliases should not move the counter")
+	restarted := NewURLService(store, WithCodeGenerator(NewCounterGenerator(CounterStart(store.List()))))
+	code, err := restarted.ShortenURL("https://example.com/new", ShortenOptions{})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), encodeBase62(100), code)
+}
+
+func (suite *CodeGeneratorTestSuite) TestCounterStartIgnoresOtherGenerators() {
+	store := newMockStore()
+	snowflake, err := NewSnowflakeGenerator(1)
+	require.NoError(suite.T(), err)
+	for i, svc := range []*URLService{
+		NewURLService(store),
+		NewURLService(store, WithCodeGenerator(snowflake)),
+		NewURLService(store, WithCodeGenerator(NewCounterGenerator(7))),
+	} {
+		_, err := svc.ShortenURL(fmt.Sprintf("https://example.com/%d", i), ShortenOptions{})
+		require.NoError(suite.T(), err)
+	}
+	// A counter code stored before generators were recorded.
+	require.NoError(suite.T(), store.Save(storage.Link{Short: "zz", Original: "https://example.com/old"}))
+
+	kinds := make(map[string]int)
+	for _, link := range store.List() {
+		kinds[link.Generator]++
+	}
+	assert.Equal(suite.T(), map[string]int{GeneratorHash: 1, GeneratorSnowflake: 1, GeneratorCounter: 1, "": 1}, kinds)
+	assert.Equal(suite.T(), uint64(8), CounterStart(store.List()))
+}
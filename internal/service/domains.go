@@ -0,0 +1,159 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTopDomains is how many domains the leaderboard lists when the
+	// caller sets no limit.
+	DefaultTopDomains = 3
+	// MaxDomainWindow is the longest window GetTopDomains accepts. Hourly
+	// counts older than this are discarded.
+	MaxDomainWindow = 7 * 24 * time.Hour
+	// domainBucketWidth is the granularity of windowed domain counts.
+	domainBucketWidth = time.Hour
+)
+
+// ErrInvalidWindow is returned for windows that are negative or longer than
+// MaxDomainWindow.
+var ErrInvalidWindow = errors.New("service: window must be between 0 and 168h")
+
+// DomainCount is one row of the domain leaderboard.
+type DomainCount struct {
+	Domain string `json:"domain"`
+	Count  int    `json:"count"`
+}
+
+// rankedBefore orders by count descending, then by domain name so ties are
+// stable.
+func rankedBefore(a, b DomainCount) bool {
+	if a.Count != b.Count {
+		return a.Count > b.Count
+	}
+	return a.Domain < b.Domain
+}
+
+func compareRank(a, b DomainCount) int {
+	if rankedBefore(a, b) {
+		return -1
+	}
+	if rankedBefore(b, a) {
+		return 1
+	}
+	return 0
+}
+
+type domainBucket struct {
+	start  time.Time
+	counts map[string]int
+}
+
+// domainRanking keeps domains sorted by all-time count as they are
+// incremented, so reading the top N is a prefix copy. Counts only ever grow,
+// which means an update moves one entry towards the front and never
+// disturbs the rest of the order. Windowed counts live in hourly buckets
+// kept in memory; they start empty when the process starts.
+type domainRanking struct {
+	mu      sync.Mutex
+	order   []DomainCount
+	index   map[string]int
+	buckets []domainBucket
+}
+
+func newDomainRanking(hits map[string]int) *domainRanking {
+	r := &domainRanking{index: make(map[string]int, len(hits))}
+	for domain, count := range hits {
+		r.order = append(r.order, DomainCount{Domain: domain, Count: count})
+	}
+	slices.SortFunc(r.order, compareRank)
+	for i, dc := range r.order {
+		r.index[dc.Domain] = i
+	}
+	return r
+}
+
+// record sets domain's all-time count to total and adds hits to the bucket
+// covering now.
+func (r *domainRanking) record(domain string, total, hits int, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i, ok := r.index[domain]
+	if !ok {
+		i = len(r.order)
+		r.order = append(r.order, DomainCount{Domain: domain})
+	}
+	r.order[i].Count = total
+	for i > 0 && rankedBefore(r.order[i], r.order[i-1]) {
+		r.order[i], r.order[i-1] = r.order[i-1], r.order[i]
+		r.index[r.order[i].Domain] = i
+		i--
+	}
+	r.index[domain] = i
+
+	start := now.Truncate(domainBucketWidth)
+	if n := len(r.buckets); n == 0 || !r.buckets[n-1].start.Equal(start) {
+		r.buckets = append(r.buckets, domainBucket{start: start, counts: make(map[string]int)})
+	}
+	r.buckets[len(r.buckets)-1].counts[domain] += hits
+	r.prune(now)
+}
+
+// prune drops buckets that can no longer fall inside any window.
+func (r *domainRanking) prune(now time.Time) {
+	cutoff := now.Add(-MaxDomainWindow - domainBucketWidth)
+	drop := 0
+	for drop < len(r.buckets) && r.buckets[drop].start.Before(cutoff) {
+		drop++
+	}
+	r.buckets = r.buckets[drop:]
+}
+
+// top returns up to limit domains in rank order. A zero window ranks by
+// all-time counts; otherwise only hits in buckets overlapping the last
+// window are counted. A limit of zero or less returns every domain.
+func (r *domainRanking) top(limit int, window time.Duration, now time.Time) []DomainCount {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if window == 0 {
+		if limit <= 0 || limit > len(r.order) {
+			limit = len(r.order)
+		}
+		return slices.Clone(r.order[:limit])
+	}
+
+	since := now.Add(-window)
+	sums := make(map[string]int)
+	for _, b := range r.buckets {
+		if !b.start.Add(domainBucketWidth).After(since) {
+			continue
+		}
+		for domain, n := range b.counts {
+			sums[domain] += n
+		}
+	}
+	list := make([]DomainCount, 0, len(sums))
+	for domain, n := range sums {
+		list = append(list, DomainCount{Domain: domain, Count: n})
+	}
+	slices.SortFunc(list, compareRank)
+	if limit > 0 && limit < len(list) {
+		list = list[:limit]
+	}
+	return list
+}
+
+// GetTopDomains returns up to limit of the most shortened domains, highest
+// count first and ties broken by name. A non-zero window only counts links
+// shortened within that long of now.
+func (s *URLService) GetTopDomains(limit int, window time.Duration) ([]DomainCount, error) {
+	if window < 0 || window > MaxDomainWindow {
+		return nil, ErrInvalidWindow
+	}
+	return s.domains.top(limit, window, s.now()), nil
+}
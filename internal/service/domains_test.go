@@ -0,0 +1,95 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type DomainsTestSuite struct {
+	suite.Suite
+	Service *URLService
+	Store   *mockStore
+	Now     time.Time
+}
+
+func TestDomainsTestSuite(t *testing.T) {
+	suite.Run(t, new(DomainsTestSuite))
+}
+
+func (suite *DomainsTestSuite) SetupTest() {
+	suite.Store = newMockStore()
+	suite.Service = NewURLService(suite.Store)
+	suite.Now = time.Date(2025, 6, 1, 12, 30, 0, 0, time.UTC)
+	suite.Service.now = func() time.Time { return suite.Now }
+}
+
+func (suite *DomainsTestSuite) shorten(urls ...string) {
+	for _, u := range urls {
+		_, err := suite.Service.ShortenURL(u, ShortenOptions{})
+		suite.Require().NoError(err)
+	}
+}
+
+func (suite *DomainsTestSuite) TestRankingFollowsShortens() {
+	suite.shorten("https://b.com/1", "https://a.com/1", "https://c.com/1", "https://c.com/2", "https://www.c.com/3")
+
+	result, err := suite.Service.GetTopDomains(0, 0)
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []DomainCount{
+		{Domain: "c.com", Count: 3},
+		{Domain: "a.com", Count: 1},
+		{Domain: "b.com", Count: 1},
+	}, result, "Ties should be ordered by domain name")
+}
+
+func (suite *DomainsTestSuite) TestLimit() {
+	for i := 0; i < 10; i++ {
+		suite.shorten(fmt.Sprintf("https://d%d.com/", i))
+	}
+
+	result, err := suite.Service.GetTopDomains(5, 0)
+
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), result, 5)
+	assert.Equal(suite.T(), "d0.com", result[0].Domain)
+	assert.Equal(suite.T(), "d4.com", result[4].Domain)
+}
+
+func (suite *DomainsTestSuite) TestWindowOnlyCountsRecentShortens() {
+	suite.shorten("https://old.com/1", "https://old.com/2", "https://old.com/3")
+	suite.Now = suite.Now.Add(48 * time.Hour)
+	suite.shorten("https://new.com/1")
+
+	recent, err := suite.Service.GetTopDomains(3, 24*time.Hour)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []DomainCount{{Domain: "new.com", Count: 1}}, recent)
+
+	week, err := suite.Service.GetTopDomains(3, MaxDomainWindow)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []DomainCount{{Domain: "old.com", Count: 3}, {Domain: "new.com", Count: 1}}, week)
+}
+
+func (suite *DomainsTestSuite) TestSeedsFromStore() {
+	suite.Store.DomainCounts["seeded.com"] = 4
+	suite.Service = NewURLService(suite.Store)
+	suite.Service.now = func() time.Time { return suite.Now }
+	suite.shorten("https://other.com/", "https://seeded.com/x")
+
+	result, err := suite.Service.GetTopDomains(1, 0)
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []DomainCount{{Domain: "seeded.com", Count: 5}}, result)
+}
+
+func (suite *DomainsTestSuite) TestInvalidWindow() {
+	_, err := suite.Service.GetTopDomains(3, -time.Hour)
+	assert.ErrorIs(suite.T(), err, ErrInvalidWindow)
+
+	_, err = suite.Service.GetTopDomains(3, MaxDomainWindow+time.Hour)
+	assert.ErrorIs(suite.T(), err, ErrInvalidWindow)
+}
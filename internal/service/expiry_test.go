@@ -0,0 +1,127 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"url-shortener/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type ExpiryTestSuite struct {
+	suite.Suite
+	Service *URLService
+	Store   *mockStore
+	Now     time.Time
+}
+
+func TestExpiryTestSuite(t *testing.T) {
+	suite.Run(t, new(ExpiryTestSuite))
+}
+
+func (suite *ExpiryTestSuite) SetupTest() {
+	suite.Store = newMockStore()
+	suite.Service = NewURLService(suite.Store)
+	suite.Now = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	suite.Service.now = func() time.Time { return suite.Now }
+}
+
+func (suite *ExpiryTestSuite) TestTTLSetsExpiry() {
+	short, err := suite.Service.ShortenURL("https://example.com", ShortenOptions{TTL: time.Hour})
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), suite.Now.Add(time.Hour), suite.Store.ShortToURL[short].ExpiresAt)
+}
+
+func (suite *ExpiryTestSuite) TestExpiredLinkIsGone() {
+	short, err := suite.Service.ShortenURL("https://example.com", ShortenOptions{ExpiresAt: suite.Now.Add(time.Minute)})
+	require.NoError(suite.T(), err)
+
+	target, err := suite.Service.GetOriginalURL(short)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "https://example.com", target)
+
+	suite.Now = suite.Now.Add(time.Minute)
+	_, err = suite.Service.GetOriginalURL(short)
+	assert.ErrorIs(suite.T(), err, ErrLinkExpired)
+}
+
+func (suite *ExpiryTestSuite) TestExpiredLinkIsStillGoneAfterSweep() {
+	store := storage.NewStore()
+	service := NewURLService(store)
+	require.NoError(suite.T(), store.Save(storage.Link{Short: "gone", Original: "https://example.com", ExpiresAt: time.Now().Add(-time.Minute)}))
+	janitor := storage.StartJanitor(store, time.Hour, 0)
+	defer janitor.Stop()
+
+	janitor.Sweep()
+
+	_, err := service.GetOriginalURL("gone")
+	assert.ErrorIs(suite.T(), err, ErrLinkExpired, "A reaped link would answer 404 instead of 410")
+}
+
+func (suite *ExpiryTestSuite) TestExpiringLinksDoNotDeduplicate() {
+	permanent, err := suite.Service.ShortenURL("https://example.com", ShortenOptions{})
+	require.NoError(suite.T(), err)
+	expiring, err := suite.Service.ShortenURL("https://example.com", ShortenOptions{TTL: time.Hour})
+	require.NoError(suite.T(), err)
+	again, err := suite.Service.ShortenURL("https://example.com", ShortenOptions{})
+	require.NoError(suite.T(), err)
+
+	assert.NotEqual(suite.T(), permanent, expiring, "An expiring link must not reuse the permanent code")
+	assert.Equal(suite.T(), permanent, again, "Plain shortening should keep returning the permanent code")
+}
+
+func (suite *ExpiryTestSuite) TestInvalidExpiry() {
+	cases := []ShortenOptions{
+		{TTL: -time.Second},
+		{ExpiresAt: suite.Now.Add(-time.Second)},
+		{ExpiresAt: suite.Now},
+		{ExpiresAt: suite.Now.Add(time.Hour), TTL: time.Hour},
+	}
+	for _, opts := range cases {
+		_, err := suite.Service.ShortenURL("https://example.com", opts)
+
+		var verr *ValidationError
+		if assert.ErrorAs(suite.T(), err, &verr, "%+v", opts) {
+			assert.Equal(suite.T(), CodeInvalidExpiry, verr.Code)
+		}
+	}
+	assert.Empty(suite.T(), suite.Store.ShortToURL)
+}
+
+func (suite *ExpiryTestSuite) TestExpiredAliasIsReservedUntilReaped() {
+	_, err := suite.Service.ShortenURL("https://old.com", ShortenOptions{Alias: "promo", TTL: time.Minute})
+	require.NoError(suite.T(), err)
+
+	suite.Now = suite.Now.Add(2 * time.Minute)
+	_, err = suite.Service.ShortenURL("https://new.com", ShortenOptions{Alias: "promo"})
+	assert.ErrorIs(suite.T(), err, ErrAliasTaken, "An expired alias should keep answering 410 until it is reaped")
+	_, err = suite.Service.ShortenURL("https://old.com", ShortenOptions{Alias: "promo", TTL: time.Minute})
+	assert.ErrorIs(suite.T(), err, ErrAliasTaken, "An expired alias is not the same link as a live one")
+
+	_, err = suite.Store.DeleteExpired(suite.Now, 10)
+	require.NoError(suite.T(), err)
+	short, err := suite.Service.ShortenURL("https://new.com", ShortenOptions{Alias: "promo"})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "promo", short)
+	assert.Equal(suite.T(), "https://new.com", suite.Store.ShortToURL["promo"].Original)
+}
+
+func (suite *ExpiryTestSuite) TestExpiredLinksCountTowardQuotaUntilReaped() {
+	svc := NewURLService(suite.Store, WithMaxLinks(1))
+	svc.now = func() time.Time { return suite.Now }
+	_, err := svc.ShortenURL("https://old.com", ShortenOptions{TTL: time.Minute})
+	require.NoError(suite.T(), err)
+
+	suite.Now = suite.Now.Add(2 * time.Minute)
+	_, err = svc.ShortenURL("https://new.com", ShortenOptions{})
+	assert.ErrorIs(suite.T(), err, ErrQuotaExceeded)
+
+	_, err = suite.Store.DeleteExpired(suite.Now, 10)
+	require.NoError(suite.T(), err)
+	_, err = svc.ShortenURL("https://new.com", ShortenOptions{})
+	assert.NoError(suite.T(), err)
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"url-shortener/internal/storage"
+)
+
+const (
+	// AnyVersion skips the optimistic concurrency check on link updates.
+	AnyVersion int64 = -1
+	// DefaultRestoreWindow is how long a deleted link can be restored
+	// before the janitor purges it.
+	DefaultRestoreWindow = 7 * 24 * time.Hour
+)
+
+var (
+	// ErrVersionConflict is returned when a link changed since the caller
+	// read the version it passed.
+	ErrVersionConflict = errors.New("service: link was modified concurrently")
+	// ErrLinkNotDeleted is returned when restoring a link that is live.
+	ErrLinkNotDeleted = errors.New("service: link is not deleted")
+)
+
+// Caller is who a link operation acts for. Admins may act on every link;
+// anyone else only on the links they own.
+type Caller struct {
+	Owner string
+	Admin bool
+}
+
+// owns reports whether c may manage link. Unowned links are left to admins.
+func (c Caller) owns(link storage.Link) bool {
+	return c.Admin || c.Owner != "" && link.Owner == c.Owner
+}
+
+// WithRestoreWindow replaces DefaultRestoreWindow.
+func WithRestoreWindow(d time.Duration) Option {
+	return func(s *URLService) {
+		s.restoreWindow = d
+	}
+}
+
+// UpdateLink points short at a new destination. The new URL is validated and
+// canonicalized like a shorten request; the code, expiry and redirect status
+// are kept. version must match the stored one unless it is AnyVersion.
+func (s *URLService) UpdateLink(caller Caller, short, original string, version int64) (storage.Link, error) {
+	link, err := s.liveLink(caller, short, version)
+	if err != nil {
+		return storage.Link{}, err
+	}
+	target, err := s.newLink(original)
+	if err != nil {
+		return storage.Link{}, err
+	}
+	link.Original, link.Canonical = target.Original, target.Canonical
+	return s.update(link)
+}
+
+// DeleteLink soft-deletes short. It stops redirecting at once and can be
+// restored with RestoreLink until the restore window ends.
+func (s *URLService) DeleteLink(caller Caller, short string, version int64) (storage.Link, error) {
+	link, err := s.liveLink(caller, short, version)
+	if err != nil {
+		return storage.Link{}, err
+	}
+	now := s.now()
+	link.DeletedAt = now
+	link.PurgeAt = now.Add(s.restoreWindow)
+	return s.update(link)
+}
+
+// RestoreLink undoes DeleteLink for a link still inside its restore window.
+func (s *URLService) RestoreLink(caller Caller, short string) (storage.Link, error) {
+	link, exists := s.store.GetByShort(short)
+	if !exists || !caller.owns(link) {
+		return storage.Link{}, ErrLinkNotFound
+	}
+	if !link.Deleted() {
+		return storage.Link{}, ErrLinkNotDeleted
+	}
+	link.DeletedAt, link.PurgeAt = time.Time{}, time.Time{}
+	return s.update(link)
+}
+
+// liveLink loads short for modification, hiding deleted links and links
+// the caller does not own, and checking the caller's version.
+func (s *URLService) liveLink(caller Caller, short string, version int64) (storage.Link, error) {
+	link, exists := s.store.GetByShort(short)
+	if !exists || link.Deleted() || !caller.owns(link) {
+		return storage.Link{}, ErrLinkNotFound
+	}
+	if version != AnyVersion && link.Version != version {
+		return storage.Link{}, ErrVersionConflict
+	}
+	return link, nil
+}
+
+func (s *URLService) update(link storage.Link) (storage.Link, error) {
+	updated, err := s.store.Update(link)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return storage.Link{}, ErrLinkNotFound
+	case errors.Is(err, storage.ErrVersionConflict):
+		return storage.Link{}, ErrVersionConflict
+	case err != nil:
+		return storage.Link{}, err
+	}
+	return updated, nil
+}
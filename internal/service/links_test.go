@@ -0,0 +1,163 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// admin may manage every link, including the unowned ones most tests use.
+var admin = Caller{Admin: true}
+
+type LinksTestSuite struct {
+	suite.Suite
+	Service *URLService
+	Store   *mockStore
+	Now     time.Time
+}
+
+func TestLinksTestSuite(t *testing.T) {
+	suite.Run(t, new(LinksTestSuite))
+}
+
+func (suite *LinksTestSuite) SetupTest() {
+	suite.Store = newMockStore()
+	suite.Service = NewURLService(suite.Store)
+	suite.Now = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	suite.Service.now = func() time.Time { return suite.Now }
+}
+
+func (suite *LinksTestSuite) shorten(original string) string {
+	short, err := suite.Service.ShortenURL(original, ShortenOptions{})
+	require.NoError(suite.T(), err)
+	return short
+}
+
+func (suite *LinksTestSuite) TestUpdateRetargetsLink() {
+	short := suite.shorten("https://example.com")
+
+	link, err := suite.Service.UpdateLink(admin, short, "HTTPS://Other.com/", 0)
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "https://other.com", link.Original, "The new destination should be canonicalized")
+	assert.Equal(suite.T(), int64(1), link.Version)
+	target, err := suite.Service.Resolve(short)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "https://other.com", target.URL)
+
+	again := suite.shorten("https://other.com")
+	assert.Equal(suite.T(), short, again, "The reverse index should follow the new destination")
+	fresh := suite.shorten("https://example.com")
+	assert.NotEqual(suite.T(), short, fresh, "The old destination should no longer map to the code")
+}
+
+func (suite *LinksTestSuite) TestUpdateVersionConflict() {
+	short := suite.shorten("https://example.com")
+	_, err := suite.Service.UpdateLink(admin, short, "https://first.com", 0)
+	require.NoError(suite.T(), err)
+
+	_, err = suite.Service.UpdateLink(admin, short, "https://second.com", 0)
+	assert.ErrorIs(suite.T(), err, ErrVersionConflict)
+
+	_, err = suite.Service.UpdateLink(admin, short, "https://second.com", AnyVersion)
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *LinksTestSuite) TestUpdateErrors() {
+	_, err := suite.Service.UpdateLink(admin, "missing", "https://example.com", AnyVersion)
+	assert.ErrorIs(suite.T(), err, ErrLinkNotFound)
+
+	short := suite.shorten("https://example.com")
+	_, err = suite.Service.UpdateLink(admin, short, "javascript:alert(1)", AnyVersion)
+	var verr *ValidationError
+	assert.True(suite.T(), errors.As(err, &verr))
+}
+
+func (suite *LinksTestSuite) TestDeleteAndRestore() {
+	short := suite.shorten("https://example.com")
+
+	deleted, err := suite.Service.DeleteLink(admin, short, AnyVersion)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), suite.Now, deleted.DeletedAt)
+	assert.Equal(suite.T(), suite.Now.Add(DefaultRestoreWindow), deleted.PurgeAt)
+	_, err = suite.Service.Resolve(short)
+	assert.ErrorIs(suite.T(), err, ErrLinkNotFound)
+	_, err = suite.Service.DeleteLink(admin, short, AnyVersion)
+	assert.ErrorIs(suite.T(), err, ErrLinkNotFound, "Deleting twice should report the link as gone")
+
+	restored, err := suite.Service.RestoreLink(admin, short)
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), restored.Deleted())
+	target, err := suite.Service.Resolve(short)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "https://example.com", target.URL)
+
+	_, err = suite.Service.RestoreLink(admin, short)
+	assert.ErrorIs(suite.T(), err, ErrLinkNotDeleted)
+	_, err = suite.Service.RestoreLink(admin, "missing")
+	assert.ErrorIs(suite.T(), err, ErrLinkNotFound)
+}
+
+func (suite *LinksTestSuite) TestDeletedAliasStaysReserved() {
+	_, err := suite.Service.ShortenURL("https://example.com", ShortenOptions{Alias: "promo"})
+	require.NoError(suite.T(), err)
+	_, err = suite.Service.DeleteLink(admin, "promo", AnyVersion)
+	require.NoError(suite.T(), err)
+
+	_, err = suite.Service.ShortenURL("https://example.com", ShortenOptions{Alias: "promo"})
+	assert.ErrorIs(suite.T(), err, ErrAliasTaken)
+}
+
+func (suite *LinksTestSuite) TestOnlyOwnerManagesLink() {
+	alice, bob := Caller{Owner: "alice"}, Caller{Owner: "bob"}
+	short, err := suite.Service.ShortenURL("https://example.com", ShortenOptions{Owner: "alice"})
+	require.NoError(suite.T(), err)
+
+	_, err = suite.Service.UpdateLink(bob, short, "https://other.com", AnyVersion)
+	assert.ErrorIs(suite.T(), err, ErrLinkNotFound, "Other owners should not see the link")
+	_, err = suite.Service.DeleteLink(bob, short, AnyVersion)
+	assert.ErrorIs(suite.T(), err, ErrLinkNotFound)
+	_, err = suite.Service.GetLinkStats(bob, short)
+	assert.ErrorIs(suite.T(), err, ErrLinkNotFound)
+
+	_, err = suite.Service.DeleteLink(alice, short, AnyVersion)
+	require.NoError(suite.T(), err)
+	_, err = suite.Service.RestoreLink(bob, short)
+	assert.ErrorIs(suite.T(), err, ErrLinkNotFound)
+	_, err = suite.Service.RestoreLink(admin, short)
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *LinksTestSuite) TestUnownedLinksNeedAdmin() {
+	short := suite.shorten("https://example.com")
+
+	_, err := suite.Service.UpdateLink(Caller{}, short, "https://other.com", AnyVersion)
+
+	assert.ErrorIs(suite.T(), err, ErrLinkNotFound)
+}
+
+func (suite *LinksTestSuite) TestOwnersDoNotShareCodes() {
+	alice, err := suite.Service.ShortenURL("https://example.com", ShortenOptions{Owner: "alice"})
+	require.NoError(suite.T(), err)
+	again, err := suite.Service.ShortenURL("https://example.com", ShortenOptions{Owner: "alice"})
+	require.NoError(suite.T(), err)
+	bob, err := suite.Service.ShortenURL("https://example.com", ShortenOptions{Owner: "bob"})
+	require.NoError(suite.T(), err)
+
+	assert.Equal(suite.T(), alice, again)
+	assert.NotEqual(suite.T(), alice, bob)
+	assert.Equal(suite.T(), "bob", suite.Store.ShortToURL[bob].Owner)
+}
+
+func (suite *LinksTestSuite) TestAliasOfAnotherOwnerIsTaken() {
+	_, err := suite.Service.ShortenURL("https://example.com", ShortenOptions{Alias: "home", Owner: "alice"})
+	require.NoError(suite.T(), err)
+
+	_, err = suite.Service.ShortenURL("https://example.com", ShortenOptions{Alias: "home", Owner: "bob"})
+
+	assert.ErrorIs(suite.T(), err, ErrAliasTaken)
+}
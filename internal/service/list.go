@@ -0,0 +1,121 @@
+package service
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"url-shortener/internal/storage"
+)
+
+const (
+	// DefaultListLimit is the page size when none is requested.
+	DefaultListLimit = 50
+	// MaxListLimit is the largest page size accepted.
+	MaxListLimit = 500
+)
+
+// ListOptions filters, orders and pages a link listing.
+type ListOptions struct {
+	Domain string
+	Tag    string
+	Owner  string
+	// CreatedFrom and CreatedTo bound the creation time as [from, to).
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	// Search matches a case-insensitive substring of the destination.
+	Search string
+	// Sort defaults to storage.SortCreatedDesc, newest first.
+	Sort storage.SortOrder
+	// Cursor continues from the NextCursor of a previous page with the same
+	// sort order.
+	Cursor string
+	Limit  int
+}
+
+// LinkSummary is a listed link with its click count.
+type LinkSummary struct {
+	storage.Link
+	Clicks int64
+}
+
+// LinkListing is one page of ListLinks. NextCursor is empty on the last
+// page.
+type LinkListing struct {
+	Links      []LinkSummary
+	NextCursor string
+}
+
+// ListLinks returns one page of live links matching opts. Callers other than
+// admins only ever see their own links, whatever owner opts asks for.
+func (s *URLService) ListLinks(caller Caller, opts ListOptions) (LinkListing, error) {
+	if !caller.Admin {
+		opts.Owner = caller.Owner
+	}
+	q := storage.LinkQuery{
+		Domain:      strings.TrimPrefix(strings.ToLower(opts.Domain), "www."),
+		Tag:         strings.ToLower(opts.Tag),
+		Owner:       opts.Owner,
+		CreatedFrom: opts.CreatedFrom,
+		CreatedTo:   opts.CreatedTo,
+		Search:      opts.Search,
+		Sort:        opts.Sort,
+		Limit:       opts.Limit,
+	}
+	if q.Sort == "" {
+		q.Sort = storage.SortCreatedDesc
+	}
+	if !q.Sort.Valid() {
+		return LinkListing{}, invalidURL(CodeInvalidQuery, "sort must be one of created, -created, clicks or -clicks")
+	}
+	switch {
+	case q.Limit == 0:
+		q.Limit = DefaultListLimit
+	case q.Limit < 0 || q.Limit > MaxListLimit:
+		return LinkListing{}, invalidURL(CodeInvalidQuery, "limit must be between 1 and %d", MaxListLimit)
+	}
+	if opts.Cursor != "" {
+		cursor, err := decodeCursor(opts.Cursor, q.Sort)
+		if err != nil {
+			return LinkListing{}, err
+		}
+		q.After = &cursor
+	}
+
+	page := s.store.QueryLinks(q)
+	listing := LinkListing{Links: make([]LinkSummary, len(page.Links))}
+	for i, link := range page.Links {
+		stats, _ := s.store.Stats(link.Short)
+		listing.Links[i] = LinkSummary{Link: link, Clicks: stats.Clicks}
+	}
+	if page.Next != nil {
+		listing.NextCursor = encodeCursor(*page.Next, q.Sort)
+	}
+	return listing, nil
+}
+
+// Cursors are opaque to clients: the sort order, key and short code joined
+// with '|' and base64url encoded. Carrying the order lets a cursor from one
+// sort be rejected rather than silently misread under another.
+func encodeCursor(c storage.Cursor, order storage.SortOrder) string {
+	raw := string(order) + "|" + strconv.FormatInt(c.Key, 10) + "|" + c.Short
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(s string, order storage.SortOrder) (storage.Cursor, error) {
+	invalid := invalidURL(CodeInvalidQuery, "cursor is malformed or belongs to a different sort order")
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return storage.Cursor{}, invalid
+	}
+	parts := strings.SplitN(string(raw), "|", 3)
+	if len(parts) != 3 || parts[0] != string(order) {
+		return storage.Cursor{}, invalid
+	}
+	key, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return storage.Cursor{}, invalid
+	}
+	return storage.Cursor{Key: key, Short: parts[2]}, nil
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"url-shortener/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type ListTestSuite struct {
+	suite.Suite
+	Service *URLService
+	Store   *mockStore
+	Now     time.Time
+}
+
+func TestListTestSuite(t *testing.T) {
+	suite.Run(t, new(ListTestSuite))
+}
+
+func (suite *ListTestSuite) SetupTest() {
+	suite.Store = newMockStore()
+	suite.Service = NewURLService(suite.Store)
+	suite.Now = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	suite.Service.now = func() time.Time { return suite.Now }
+}
+
+func (suite *ListTestSuite) shorten(original string, tags ...string) string {
+	suite.Now = suite.Now.Add(time.Minute)
+	short, err := suite.Service.ShortenURL(original, ShortenOptions{Tags: tags})
+	require.NoError(suite.T(), err)
+	return short
+}
+
+func codes(listing LinkListing) []string {
+	var out []string
+	for _, link := range listing.Links {
+		out = append(out, link.Short)
+	}
+	return out
+}
+
+func (suite *ListTestSuite) TestNewestFirstWithCursor() {
+	var created []string
+	for i := 0; i < 5; i++ {
+		created = append(created, suite.shorten(fmt.Sprintf("https://example.com/%d", i)))
+	}
+
+	first, err := suite.Service.ListLinks(admin, ListOptions{Limit: 3})
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{created[4], created[3], created[2]}, codes(first))
+	require.NotEmpty(suite.T(), first.NextCursor)
+
+	second, err := suite.Service.ListLinks(admin, ListOptions{Limit: 3, Cursor: first.NextCursor})
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{created[1], created[0]}, codes(second))
+	assert.Empty(suite.T(), second.NextCursor)
+}
+
+func (suite *ListTestSuite) TestFiltersAndClicks() {
+	a := suite.shorten("https://www.Example.com/a", "Promo")
+	suite.shorten("https://other.com/b")
+	suite.Service.RecordClick(a, "", "")
+	suite.Service.clicks.Flush()
+
+	listing, err := suite.Service.ListLinks(admin, ListOptions{Domain: "WWW.example.com", Tag: "promo"})
+
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), listing.Links, 1)
+	assert.Equal(suite.T(), a, listing.Links[0].Short)
+	assert.Equal(suite.T(), []string{"promo"}, listing.Links[0].Tags)
+	assert.Equal(suite.T(), int64(1), listing.Links[0].Clicks)
+}
+
+func (suite *ListTestSuite) TestNonAdminsListOwnLinks() {
+	mine, err := suite.Service.ShortenURL("https://example.com/a", ShortenOptions{Owner: "alice"})
+	require.NoError(suite.T(), err)
+	_, err = suite.Service.ShortenURL("https://example.com/b", ShortenOptions{Owner: "bob"})
+	require.NoError(suite.T(), err)
+
+	listing, err := suite.Service.ListLinks(Caller{Owner: "alice"}, ListOptions{Owner: "bob"})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), listing.Links, 1)
+	assert.Equal(suite.T(), mine, listing.Links[0].Short)
+
+	listing, err = suite.Service.ListLinks(admin, ListOptions{Owner: "bob"})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), listing.Links, 1)
+	assert.Equal(suite.T(), "bob", listing.Links[0].Owner)
+}
+
+func (suite *ListTestSuite) TestInvalidOptions() {
+	for _, opts := range []ListOptions{
+		{Sort: "name"},
+		{Limit: MaxListLimit + 1},
+		{Cursor: "!!"},
+		{Cursor: encodeCursor(storage.Cursor{Key: 1, Short: "abc"}, storage.SortClicksDesc)},
+	} {
+		_, err := suite.Service.ListLinks(admin, opts)
+		var verr *ValidationError
+		assert.True(suite.T(), errors.As(err, &verr), "%+v", opts)
+		assert.Equal(suite.T(), CodeInvalidQuery, verr.Code)
+	}
+}
+
+func (suite *ListTestSuite) TestCursorRoundTrip() {
+	c := storage.Cursor{Key: -42, Short: "a|b"}
+
+	decoded, err := decodeCursor(encodeCursor(c, storage.SortCreatedAsc), storage.SortCreatedAsc)
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), c, decoded)
+}
+
+func (suite *ListTestSuite) TestNormalizeTags() {
+	tags, err := NormalizeTags([]string{"b", " A ", "b"})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{"a", "b"}, tags)
+
+	for _, bad := range [][]string{{""}, {"has space"}, {"x", "y", "1", "2", "3", "4", "5", "6", "7", "8", "9"}} {
+		_, err := NormalizeTags(bad)
+		var verr *ValidationError
+		assert.True(suite.T(), errors.As(err, &verr), "%v", bad)
+	}
+}
+
+func (suite *ListTestSuite) TestTaggedLinksAreNotDeduplicated() {
+	plain := suite.shorten("https://example.com")
+	tagged := suite.shorten("https://example.com", "promo")
+
+	assert.NotEqual(suite.T(), plain, tagged)
+	assert.Equal(suite.T(), plain, suite.shorten("https://example.com"))
+}
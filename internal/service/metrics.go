@@ -0,0 +1,48 @@
+package service
+
+import "url-shortener/internal/metrics"
+
+// Metrics exposes the business metrics of every namespace's service on one
+// registry, telling them apart by a tenant label.
+type Metrics struct {
+	links        *metrics.FuncVec
+	created      *metrics.FuncVec
+	deduplicated *metrics.FuncVec
+	dedupRatio   *metrics.FuncVec
+	dropped      *metrics.FuncVec
+}
+
+// NewMetrics registers the service metric families on r.
+func NewMetrics(r *metrics.Registry) *Metrics {
+	return &Metrics{
+		links:        r.GaugeFuncVec("urlshortener_links", "Short links currently stored, by tenant.", "tenant"),
+		created:      r.CounterFuncVec("urlshortener_shorten_created_total", "Shorten requests that stored a new link, by tenant.", "tenant"),
+		deduplicated: r.CounterFuncVec("urlshortener_shorten_deduplicated_total", "Shorten requests answered with an existing link, by tenant.", "tenant"),
+		dedupRatio:   r.GaugeFuncVec("urlshortener_shorten_dedup_ratio", "Fraction of successful shorten requests answered with an existing link, by tenant.", "tenant"),
+		dropped:      r.CounterFuncVec("urlshortener_clicks_dropped_total", "Clicks discarded because the analytics buffer was full, by tenant.", "tenant"),
+	}
+}
+
+// Add exposes s's metrics under tenant, which is empty for the default
+// namespace.
+func (m *Metrics) Add(tenant string, s *URLService) {
+	m.links.Set(func() float64 {
+		return float64(s.store.Len())
+	}, tenant)
+	m.created.Set(func() float64 {
+		return float64(s.created.Load())
+	}, tenant)
+	m.deduplicated.Set(func() float64 {
+		return float64(s.deduplicated.Load())
+	}, tenant)
+	m.dedupRatio.Set(func() float64 {
+		created, deduplicated := s.created.Load(), s.deduplicated.Load()
+		if created+deduplicated == 0 {
+			return 0
+		}
+		return float64(deduplicated) / float64(created+deduplicated)
+	}, tenant)
+	m.dropped.Set(func() float64 {
+		return float64(s.clicks.Dropped())
+	}, tenant)
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"url-shortener/internal/metrics"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	svc := NewURLService(newMockStore())
+	defer svc.Close()
+	tenantSvc := NewURLService(newMockStore())
+	defer tenantSvc.Close()
+	registry := metrics.NewRegistry()
+	m := NewMetrics(registry)
+	m.Add("", svc)
+	m.Add("acme", tenantSvc)
+
+	for _, u := range []string{"https://a.com", "https://a.com", "https://a.com", "https://b.com"} {
+		_, err := svc.ShortenURL(u, ShortenOptions{})
+		require.NoError(t, err)
+	}
+	_, err := tenantSvc.ShortenURL("https://c.com", ShortenOptions{})
+	require.NoError(t, err)
+
+	var out strings.Builder
+	_, err = registry.WriteTo(&out)
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "urlshortener_links{tenant=\"\"} 2\n")
+	assert.Contains(t, out.String(), "urlshortener_links{tenant=\"acme\"} 1\n")
+	assert.Contains(t, out.String(), "urlshortener_shorten_created_total{tenant=\"\"} 2\n")
+	assert.Contains(t, out.String(), "urlshortener_shorten_created_total{tenant=\"acme\"} 1\n")
+	assert.Contains(t, out.String(), "urlshortener_shorten_deduplicated_total{tenant=\"\"} 2\n")
+	assert.Contains(t, out.String(), "urlshortener_shorten_dedup_ratio{tenant=\"\"} 0.5\n")
+	assert.Contains(t, out.String(), "urlshortener_shorten_dedup_ratio{tenant=\"acme\"} 0\n")
+	assert.Contains(t, out.String(), "urlshortener_clicks_dropped_total{tenant=\"acme\"} 0\n")
+}
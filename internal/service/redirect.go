@@ -0,0 +1,78 @@
+package service
+
+import (
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultRedirectStatus is used for links that do not pin their own.
+	DefaultRedirectStatus = http.StatusMovedPermanently
+	// DefaultPermanentMaxAge bounds how long clients may cache a permanent
+	// redirect, so a link can still be retargeted or counted eventually.
+	DefaultPermanentMaxAge = 24 * time.Hour
+)
+
+// IsRedirectStatus reports whether code is a redirect status a link may use.
+func IsRedirectStatus(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// permanentRedirect reports whether clients may cache a redirect with code.
+func permanentRedirect(code int) bool {
+	return code == http.StatusMovedPermanently || code == http.StatusPermanentRedirect
+}
+
+// WithRedirectStatus replaces DefaultRedirectStatus. Callers should check
+// the code with IsRedirectStatus first.
+func WithRedirectStatus(code int) Option {
+	return func(s *URLService) {
+		s.redirect = code
+	}
+}
+
+// WithPermanentMaxAge replaces DefaultPermanentMaxAge.
+func WithPermanentMaxAge(d time.Duration) Option {
+	return func(s *URLService) {
+		s.permanentAge = d
+	}
+}
+
+// Target is where a short code sends its visitors.
+type Target struct {
+	URL    string
+	Status int
+	// MaxAge is how long clients may cache the redirect. Zero means it must
+	// not be cached.
+	MaxAge time.Duration
+}
+
+// Resolve returns the redirect for short, or ErrLinkNotFound or
+// ErrLinkExpired. Temporary redirects are never cacheable; permanent ones
+// are cacheable for the configured max age, cut short by the link's expiry.
+func (s *URLService) Resolve(short string) (Target, error) {
+	link, exists := s.store.GetByShort(short)
+	if !exists || link.Deleted() {
+		return Target{}, ErrLinkNotFound
+	}
+	now := s.now()
+	if link.Expired(now) {
+		return Target{}, ErrLinkExpired
+	}
+
+	target := Target{URL: link.Original, Status: link.Redirect}
+	if target.Status == 0 {
+		target.Status = s.redirect
+	}
+	if permanentRedirect(target.Status) {
+		target.MaxAge = s.permanentAge
+		if !link.ExpiresAt.IsZero() {
+			target.MaxAge = min(target.MaxAge, link.ExpiresAt.Sub(now))
+		}
+	}
+	return target, nil
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type RedirectTestSuite struct {
+	suite.Suite
+	Service *URLService
+	Store   *mockStore
+	Now     time.Time
+}
+
+func TestRedirectTestSuite(t *testing.T) {
+	suite.Run(t, new(RedirectTestSuite))
+}
+
+func (suite *RedirectTestSuite) SetupTest() {
+	suite.Store = newMockStore()
+	suite.Service = NewURLService(suite.Store)
+	suite.Now = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	suite.Service.now = func() time.Time { return suite.Now }
+}
+
+func (suite *RedirectTestSuite) TestDefaultIsPermanentAndCacheable() {
+	short, err := suite.Service.ShortenURL("https://example.com", ShortenOptions{})
+	require.NoError(suite.T(), err)
+
+	target, err := suite.Service.Resolve(short)
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), Target{URL: "https://example.com", Status: http.StatusMovedPermanently, MaxAge: DefaultPermanentMaxAge}, target)
+	assert.Zero(suite.T(), suite.Store.ShortToURL[short].Redirect, "The default should not be pinned on the link")
+}
+
+func (suite *RedirectTestSuite) TestGlobalDefault() {
+	suite.Service = NewURLService(suite.Store, WithRedirectStatus(http.StatusFound))
+	short, err := suite.Service.ShortenURL("https://example.com", ShortenOptions{})
+	require.NoError(suite.T(), err)
+
+	target, err := suite.Service.Resolve(short)
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusFound, target.Status)
+	assert.Zero(suite.T(), target.MaxAge, "Temporary redirects must not be cached")
+}
+
+func (suite *RedirectTestSuite) TestPerLinkStatusIsStoredAndNotDeduplicated() {
+	plain, err := suite.Service.ShortenURL("https://example.com", ShortenOptions{})
+	require.NoError(suite.T(), err)
+	pinned, err := suite.Service.ShortenURL("https://example.com", ShortenOptions{Redirect: http.StatusTemporaryRedirect})
+	require.NoError(suite.T(), err)
+
+	assert.NotEqual(suite.T(), plain, pinned)
+	assert.Equal(suite.T(), http.StatusTemporaryRedirect, suite.Store.ShortToURL[pinned].Redirect)
+	target, err := suite.Service.Resolve(pinned)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusTemporaryRedirect, target.Status)
+
+	again, err := suite.Service.ShortenURL("https://example.com", ShortenOptions{})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), plain, again, "Pinned links should not replace the deduplicated one")
+}
+
+func (suite *RedirectTestSuite) TestMaxAgeStopsAtExpiry() {
+	short, err := suite.Service.ShortenURL("https://example.com", ShortenOptions{TTL: time.Hour, Redirect: http.StatusPermanentRedirect})
+	require.NoError(suite.T(), err)
+
+	target, err := suite.Service.Resolve(short)
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), time.Hour, target.MaxAge)
+}
+
+func (suite *RedirectTestSuite) TestInvalidStatus() {
+	_, err := suite.Service.ShortenURL("https://example.com", ShortenOptions{Redirect: http.StatusOK})
+
+	var verr *ValidationError
+	assert.True(suite.T(), errors.As(err, &verr))
+	assert.Equal(suite.T(), CodeInvalidRedirect, verr.Code)
+}
+
+func (suite *RedirectTestSuite) TestAliasWithDifferentStatusIsTaken() {
+	_, err := suite.Service.ShortenURL("https://example.com", ShortenOptions{Alias: "promo", Redirect: http.StatusFound})
+	require.NoError(suite.T(), err)
+
+	_, err = suite.Service.ShortenURL("https://example.com", ShortenOptions{Alias: "promo", Redirect: http.StatusFound})
+	assert.NoError(suite.T(), err)
+	_, err = suite.Service.ShortenURL("https://example.com", ShortenOptions{Alias: "promo"})
+	assert.ErrorIs(suite.T(), err, ErrAliasTaken)
+}
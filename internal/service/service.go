@@ -2,56 +2,212 @@ package service
 
 import (
 	"crypto/md5"
-	"encoding/hex"
-	"net/url"
-	"strings"
+	"crypto/rand"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"url-shortener/internal/storage"
 )
 
-type URLService struct {
-	store *storage.Store
+const (
+	// defaultCodeLength is the number of characters used for hash and random
+	// codes when no length is configured.
+	defaultCodeLength = 6
+	// saltsPerLength is how many salted variants are tried at a given code
+	// length before the code is extended by one character.
+	saltsPerLength = 3
+	// maxCodeAttempts bounds the collision retry loop.
+	maxCodeAttempts = 30
+)
+
+var (
+	// ErrCodeSpaceExhausted is returned when no free short code could be
+	// found for a URL within maxCodeAttempts.
+	ErrCodeSpaceExhausted = errors.New("service: could not find a free short code")
+	// ErrLinkNotFound is returned for short codes that were never issued.
+	ErrLinkNotFound = errors.New("service: short URL not found")
+	// ErrLinkExpired is returned for short codes past their expiry.
+	ErrLinkExpired = errors.New("service: short URL has expired")
+)
+
+// HashFunc digests a URL into the bytes short codes are derived from.
+type HashFunc func(data []byte) []byte
+
+func md5Hash(data []byte) []byte {
+	sum := md5.Sum(data)
+	return sum[:]
 }
 
-func NewURLService(s *storage.Store) *URLService {
-	return &URLService{store: s}
+// Option customises a URLService.
+type Option func(*URLService)
+
+// WithHashFunc switches to hash-derived codes using h instead of MD5.
+func WithHashFunc(h HashFunc) Option {
+	return func(s *URLService) {
+		s.codes = &HashGenerator{Hash: h}
+	}
 }
 
-func (s *URLService) ShortenURL(original string) string {
-	s.store.Mutex.Lock()
-	defer s.store.Mutex.Unlock()
+// WithCodeGenerator replaces the default hash-derived short codes.
+func WithCodeGenerator(g CodeGenerator) Option {
+	return func(s *URLService) {
+		s.codes = g
+	}
+}
+
+// ShortenOptions carries the optional parts of a shorten request.
+type ShortenOptions struct {
+	// Alias requests a custom short code instead of a generated one.
+	Alias string
+	// ExpiresAt sets an absolute expiry. Mutually exclusive with TTL.
+	ExpiresAt time.Time
+	// TTL sets an expiry relative to now. Mutually exclusive with ExpiresAt.
+	TTL time.Duration
+	// Redirect pins the link's redirect status (301, 302, 307 or 308).
+	// Zero follows the service default.
+	Redirect int
+	// Tags label the link for listing; see NormalizeTags.
+	Tags []string
+	// Owner records who created the link. Links are only deduplicated
+	// against others with the same owner.
+	Owner string
+}
+
+type URLService struct {
+	store         storage.Repository
+	codes         CodeGenerator
+	codesKind     string
+	reserved      map[string]bool
+	schemes       map[string]bool
+	maxURLLength  int
+	canonical     CanonicalOptions
+	now           func() time.Time
+	clickBuffer   int
+	redirect      int
+	permanentAge  time.Duration
+	restoreWindow time.Duration
+	maxBatch      int
+	maxLinks      int
+	clicks        *ClickRecorder
+	domains       *domainRanking
+	// created and deduplicated count shorten requests that stored a new link
+	// and those answered with an existing one.
+	created, deduplicated atomic.Int64
+	// mu serializes the lookup-then-save sequence in ShortenBatch so two
+	// concurrent requests for the same URL cannot both create a mapping.
+	mu sync.Mutex
+}
 
-	if short, exists := s.store.URLToShort[original]; exists {
-		return short
+func NewURLService(s storage.Repository, opts ...Option) *URLService {
+	svc := &URLService{
+		store:         s,
+		codes:         &HashGenerator{Hash: md5Hash},
+		reserved:      reservedSet(DefaultReservedAliases),
+		maxURLLength:  DefaultMaxURLLength,
+		now:           time.Now,
+		clickBuffer:   DefaultClickBuffer,
+		redirect:      DefaultRedirectStatus,
+		permanentAge:  DefaultPermanentMaxAge,
+		restoreWindow: DefaultRestoreWindow,
+		maxBatch:      DefaultMaxBatchSize,
 	}
+	WithAllowedSchemes(DefaultAllowedSchemes)(svc)
+	for _, opt := range opts {
+		opt(svc)
+	}
+	svc.clicks = NewClickRecorder(s, svc.clickBuffer)
+	svc.domains = newDomainRanking(s.DomainHits())
+	svc.codesKind = generatorKind(svc.codes)
+	return svc
+}
 
-	hash := md5.Sum([]byte(original))
-	short := hex.EncodeToString(hash[:])[:6]
+// ShortenURL returns the short code for original, creating one if needed.
+// Equivalent permanent URLs share a code because lookups use the canonical
+// form; expiring links always get a fresh code. Invalid destinations and
+// expiries are rejected with a *ValidationError.
+func (s *URLService) ShortenURL(original string, opts ShortenOptions) (string, error) {
+	results, err := s.ShortenBatch([]BatchItem{{URL: original, Options: opts}})
+	if err != nil {
+		return "", err
+	}
+	return results[0].Short, results[0].Err
+}
 
-	s.store.URLToShort[original] = short
-	s.store.ShortToURL[short] = original
+// prepare validates a shorten request into an unsaved link.
+func (s *URLService) prepare(original string, opts ShortenOptions) (storage.Link, error) {
+	link, err := s.newLink(original)
+	if err != nil {
+		return storage.Link{}, err
+	}
+	if link.ExpiresAt, err = s.expiry(opts); err != nil {
+		return storage.Link{}, err
+	}
+	if opts.Redirect != 0 && !IsRedirectStatus(opts.Redirect) {
+		return storage.Link{}, invalidURL(CodeInvalidRedirect, "redirect status must be 301, 302, 307 or 308")
+	}
+	link.Redirect = opts.Redirect
+	if link.Tags, err = NormalizeTags(opts.Tags); err != nil {
+		return storage.Link{}, err
+	}
+	link.Owner = opts.Owner
+	return link, nil
+}
 
-	u, _ := url.Parse(original)
-	domain := strings.TrimPrefix(u.Hostname(), "www.")
-	s.store.DomainHits[domain]++
+// newLink validates and canonicalizes original into an unsaved link.
+func (s *URLService) newLink(original string) (storage.Link, error) {
+	validated, err := s.ValidateURL(original)
+	if err != nil {
+		return storage.Link{}, err
+	}
+	canonical, err := s.canonical.Canonicalize(validated)
+	if err != nil {
+		return storage.Link{}, invalidURL(CodeMalformedURL, "url could not be parsed")
+	}
 
-	return short
+	link := storage.Link{Original: canonical, CreatedAt: s.now()}
+	if s.canonical.PreserveOriginal && validated != canonical {
+		link.Original = validated
+		link.Canonical = canonical
+	}
+	return link, nil
 }
 
-func (s *URLService) GetOriginalURL(short string) (string, bool) {
-	s.store.Mutex.RLock()
-	defer s.store.Mutex.RUnlock()
-	url, exists := s.store.ShortToURL[short]
-	return url, exists
+// expiry resolves the absolute expiry requested by opts, or zero for none.
+func (s *URLService) expiry(opts ShortenOptions) (time.Time, error) {
+	switch {
+	case !opts.ExpiresAt.IsZero() && opts.TTL != 0:
+		return time.Time{}, invalidURL(CodeInvalidExpiry, "set either an expiry time or a ttl, not both")
+	case opts.TTL < 0:
+		return time.Time{}, invalidURL(CodeInvalidExpiry, "ttl must be positive")
+	case opts.TTL > 0:
+		return s.now().Add(opts.TTL), nil
+	case !opts.ExpiresAt.IsZero() && !opts.ExpiresAt.After(s.now()):
+		return time.Time{}, invalidURL(CodeInvalidExpiry, "expiry time must be in the future")
+	}
+	return opts.ExpiresAt, nil
 }
 
-func (s *URLService) GetTopDomains(limit int) map[string]int {
-	s.store.Mutex.RLock()
-	defer s.store.Mutex.RUnlock()
+// codeInput is what generated codes for link are derived from. Indexed
+// links use their destination, so a repeat probes the codes of the link it
+// deduplicates to. The rest are never deduplicated: each gets a random
+// nonce, otherwise identical requests would probe the same candidates and
+// every repeat would collide with all earlier copies until the attempts
+// ran out.
+func codeInput(link storage.Link) string {
+	if link.Indexed() {
+		return link.DedupKey()
+	}
+	return link.DedupKey() + "#" + rand.Text()
+}
 
-	result := make(map[string]int)
-	for k, v := range s.store.DomainHits {
-		result[k] = v
+// GetOriginalURL returns the destination for short, or ErrLinkNotFound or
+// ErrLinkExpired.
+func (s *URLService) GetOriginalURL(short string) (string, error) {
+	target, err := s.Resolve(short)
+	if err != nil {
+		return "", err
 	}
-	return result
+	return target.URL, nil
 }
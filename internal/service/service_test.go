@@ -3,7 +3,12 @@ package service
 import (
 	"crypto/md5"
 	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 	"url-shortener/internal/storage"
 
 	"github.com/stretchr/testify/assert"
@@ -21,12 +26,8 @@ func TestURLServiceTestSuite(t *testing.T) {
 }
 
 func (suite *URLServiceTestSuite) SetupTest() {
-	suite.Store = &mockStore{
-		URLToShort: make(map[string]string),
-		ShortToURL: make(map[string]string),
-		DomainHits: make(map[string]int),
-	}
-	suite.Service = NewURLService((*storage.Store)(suite.Store))
+	suite.Store = newMockStore()
+	suite.Service = NewURLService(suite.Store)
 }
 
 func (suite *URLServiceTestSuite) TestShortenURLSuccessNewURL() {
@@ -35,12 +36,13 @@ func (suite *URLServiceTestSuite) TestShortenURLSuccessNewURL() {
 	expectedShort := hex.EncodeToString(hash[:])[:6]
 	expectedDomain := "example.com"
 
-	result := suite.Service.ShortenURL(original)
+	result, err := suite.Service.ShortenURL(original, ShortenOptions{})
 
+	assert.NoError(suite.T(), err)
 	assert.Equal(suite.T(), expectedShort, result)
 	assert.Equal(suite.T(), expectedShort, suite.Store.URLToShort[original])
-	assert.Equal(suite.T(), original, suite.Store.ShortToURL[expectedShort])
-	assert.Equal(suite.T(), 1, suite.Store.DomainHits[expectedDomain])
+	assert.Equal(suite.T(), original, suite.Store.ShortToURL[expectedShort].Original)
+	assert.Equal(suite.T(), 1, suite.Store.DomainCounts[expectedDomain])
 }
 
 func (suite *URLServiceTestSuite) TestShortenURLExistingURL() {
@@ -49,79 +51,367 @@ func (suite *URLServiceTestSuite) TestShortenURLExistingURL() {
 	expectedShort := hex.EncodeToString(hash[:])[:6]
 	expectedDomain := "example.com"
 	suite.Store.URLToShort[original] = expectedShort
-	suite.Store.ShortToURL[expectedShort] = original
-	suite.Store.DomainHits[expectedDomain] = 1
+	suite.Store.ShortToURL[expectedShort] = storage.Link{Short: expectedShort, Original: original}
+	suite.Store.DomainCounts[expectedDomain] = 1
 
-	result := suite.Service.ShortenURL(original)
+	result, err := suite.Service.ShortenURL(original, ShortenOptions{})
 
+	assert.NoError(suite.T(), err)
 	assert.Equal(suite.T(), expectedShort, result)
-	assert.Equal(suite.T(), 2, suite.Store.DomainHits[expectedDomain])
+	assert.Equal(suite.T(), 1, suite.Store.DomainCounts[expectedDomain], "Repeated URLs should not count as a new shorten")
 }
 
 func (suite *URLServiceTestSuite) TestShortenURLEmptyURL() {
-	result := suite.Service.ShortenURL("")
+	result, err := suite.Service.ShortenURL("", ShortenOptions{})
 
+	var verr *ValidationError
+	assert.ErrorAs(suite.T(), err, &verr)
+	assert.Equal(suite.T(), CodeEmptyURL, verr.Code)
 	assert.Equal(suite.T(), "", result)
 	assert.Empty(suite.T(), suite.Store.URLToShort)
 	assert.Empty(suite.T(), suite.Store.ShortToURL)
-	assert.Empty(suite.T(), suite.Store.DomainHits)
+	assert.Empty(suite.T(), suite.Store.DomainCounts)
+}
+
+func (suite *URLServiceTestSuite) TestShortenURLStoreError() {
+	suite.Store.SaveErr = errors.New("disk full")
+
+	result, err := suite.Service.ShortenURL("https://example.com", ShortenOptions{})
+
+	assert.EqualError(suite.T(), err, "disk full")
+	assert.Empty(suite.T(), result)
+	assert.Empty(suite.T(), suite.Store.DomainCounts)
+}
+
+func (suite *URLServiceTestSuite) TestShortenURLCollisionUsesSaltedCode() {
+	// Unsalted inputs all hash to the same digest; salted ones hash normally.
+	suite.Service = NewURLService(suite.Store, WithHashFunc(func(data []byte) []byte {
+		if !strings.Contains(string(data), "#") {
+			return make([]byte, 16)
+		}
+		return md5Hash(data)
+	}))
+
+	first, err := suite.Service.ShortenURL("https://first.com", ShortenOptions{})
+	assert.NoError(suite.T(), err)
+	second, err := suite.Service.ShortenURL("https://second.com", ShortenOptions{})
+	assert.NoError(suite.T(), err)
+
+	salted := md5.Sum([]byte("https://second.com#1"))
+	assert.Equal(suite.T(), "000000", first)
+	assert.Equal(suite.T(), hex.EncodeToString(salted[:])[:6], second)
+	assert.Equal(suite.T(), "https://first.com", suite.Store.ShortToURL[first].Original, "Colliding URL must not hijack the first mapping")
+	assert.Equal(suite.T(), "https://second.com", suite.Store.ShortToURL[second].Original)
+}
+
+func (suite *URLServiceTestSuite) TestShortenURLCollisionExtendsCodeLength() {
+	suite.Service = NewURLService(suite.Store, WithHashFunc(func(data []byte) []byte {
+		return []byte{0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0}
+	}))
+
+	first, err := suite.Service.ShortenURL("https://first.com", ShortenOptions{})
+	assert.NoError(suite.T(), err)
+	second, err := suite.Service.ShortenURL("https://second.com", ShortenOptions{})
+	assert.NoError(suite.T(), err)
+
+	assert.Equal(suite.T(), "123456", first)
+	assert.Equal(suite.T(), "1234567", second, "Exhausting salts should lengthen the code")
+	assert.Equal(suite.T(), "https://first.com", suite.Store.ShortToURL[first].Original)
+}
+
+func (suite *URLServiceTestSuite) TestShortenURLCollisionIsDeterministic() {
+	constant := WithHashFunc(func(data []byte) []byte { return make([]byte, 16) })
+	suite.Service = NewURLService(suite.Store, constant)
+	_, err := suite.Service.ShortenURL("https://first.com", ShortenOptions{})
+	assert.NoError(suite.T(), err)
+	second, err := suite.Service.ShortenURL("https://second.com", ShortenOptions{})
+	assert.NoError(suite.T(), err)
+
+	other := newMockStore()
+	otherService := NewURLService(other, constant)
+	_, err = otherService.ShortenURL("https://first.com", ShortenOptions{})
+	assert.NoError(suite.T(), err)
+	again, err := otherService.ShortenURL("https://second.com", ShortenOptions{})
+	assert.NoError(suite.T(), err)
+
+	assert.Equal(suite.T(), second, again, "The same state should always yield the same code")
+}
+
+func (suite *URLServiceTestSuite) TestShortenURLCodeSpaceExhausted() {
+	suite.Service = NewURLService(suite.Store, WithHashFunc(func(data []byte) []byte {
+		return []byte{0x12, 0x34, 0x56}
+	}))
+
+	_, err := suite.Service.ShortenURL("https://first.com", ShortenOptions{})
+	assert.NoError(suite.T(), err)
+	result, err := suite.Service.ShortenURL("https://second.com", ShortenOptions{})
+
+	assert.ErrorIs(suite.T(), err, ErrCodeSpaceExhausted)
+	assert.Empty(suite.T(), result)
+	assert.Len(suite.T(), suite.Store.ShortToURL, 1)
+}
+
+func (suite *URLServiceTestSuite) TestRepeatedUndeduplicatedLinksKeepGettingCodes() {
+	for _, tc := range []struct {
+		name string
+		opts ShortenOptions
+	}{
+		{"ttl", ShortenOptions{TTL: time.Hour}},
+		{"expires_at", ShortenOptions{ExpiresAt: time.Now().Add(time.Hour)}},
+		{"redirect_status", ShortenOptions{Redirect: http.StatusFound}},
+		{"tags", ShortenOptions{Tags: []string{"promo"}}},
+	} {
+		seen := make(map[string]bool)
+		for range maxCodeAttempts + 5 {
+			short, err := suite.Service.ShortenURL("https://example.com", tc.opts)
+			if !assert.NoError(suite.T(), err, tc.name) {
+				break
+			}
+			assert.False(suite.T(), seen[short], "%s: each link should get its own code", tc.name)
+			seen[short] = true
+		}
+	}
 }
 
 func (suite *URLServiceTestSuite) TestGetOriginalURLSuccess() {
 	short := "abc123"
 	original := "https://example.com"
-	suite.Store.ShortToURL[short] = original
+	suite.Store.ShortToURL[short] = storage.Link{Short: short, Original: original}
 
-	result, exists := suite.Service.GetOriginalURL(short)
+	result, err := suite.Service.GetOriginalURL(short)
 
-	assert.True(suite.T(), exists)
+	assert.NoError(suite.T(), err)
 	assert.Equal(suite.T(), original, result)
 }
 
 func (suite *URLServiceTestSuite) TestGetOriginalURLNotFound() {
-	result, exists := suite.Service.GetOriginalURL("invalid")
+	result, err := suite.Service.GetOriginalURL("invalid")
 
-	assert.False(suite.T(), exists)
+	assert.ErrorIs(suite.T(), err, ErrLinkNotFound)
 	assert.Empty(suite.T(), result)
 }
 
 func (suite *URLServiceTestSuite) TestGetTopDomainsSuccess() {
-	suite.Store.DomainHits = map[string]int{
+	suite.Store.DomainCounts = map[string]int{
 		"example.com": 10,
 		"test.com":    5,
 		"other.com":   3,
+		"more.com":    1,
 	}
+	suite.Service = NewURLService(suite.Store)
 
-	result := suite.Service.GetTopDomains(3)
+	result, err := suite.Service.GetTopDomains(3, 0)
 
-	assert.Equal(suite.T(), suite.Store.DomainHits, result)
-	assert.Len(suite.T(), result, 3)
-	assert.Equal(suite.T(), 10, result["example.com"])
-	assert.Equal(suite.T(), 5, result["test.com"])
-	assert.Equal(suite.T(), 3, result["other.com"])
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []DomainCount{
+		{Domain: "example.com", Count: 10},
+		{Domain: "test.com", Count: 5},
+		{Domain: "other.com", Count: 3},
+	}, result)
 }
 
 func (suite *URLServiceTestSuite) TestGetTopDomainsEmpty() {
-	result := suite.Service.GetTopDomains(3)
+	result, err := suite.Service.GetTopDomains(3, 0)
 
+	assert.NoError(suite.T(), err)
 	assert.Empty(suite.T(), result)
 }
 
-// mockStore mimics storage.Store
-type mockStore storage.Store
+// mockStore is a storage.Repository backed by plain maps so tests can
+// seed and inspect state directly.
+type mockStore struct {
+	URLToShort   map[string]string
+	ShortToURL   map[string]storage.Link
+	DomainCounts map[string]int
+	SaveErr      error
+	DomainsErr   error
+	// Lookups counts LookupBatch calls.
+	Lookups int
+
+	mu     sync.Mutex
+	Clicks []storage.Click
+}
+
+func newMockStore() *mockStore {
+	return &mockStore{
+		URLToShort:   make(map[string]string),
+		ShortToURL:   make(map[string]storage.Link),
+		DomainCounts: make(map[string]int),
+	}
+}
+
+func (m *mockStore) Save(link storage.Link) error {
+	if m.SaveErr != nil {
+		return m.SaveErr
+	}
+	m.ShortToURL[link.Short] = link
+	if link.Indexed() {
+		m.URLToShort[link.DedupKey()] = link.Short
+	}
+	return nil
+}
+
+func (m *mockStore) Insert(link storage.Link) error {
+	if m.SaveErr != nil {
+		return m.SaveErr
+	}
+	if _, exists := m.ShortToURL[link.Short]; exists {
+		return storage.ErrCodeTaken
+	}
+	return m.Save(link)
+}
 
-func (m *mockStore) Lock() {
-	// No-op for testing
+func (m *mockStore) InsertBatch(links []storage.Link) []error {
+	errs := make([]error, len(links))
+	for i, link := range links {
+		errs[i] = m.Insert(link)
+	}
+	return errs
 }
 
-func (m *mockStore) Unlock() {
-	// No-op for testing
+func (m *mockStore) GetByShort(short string) (storage.Link, bool) {
+	link, ok := m.ShortToURL[short]
+	return link, ok
 }
 
-func (m *mockStore) RLock() {
-	// No-op for testing
+func (m *mockStore) GetByOriginal(original string) (storage.Link, bool) {
+	short, ok := m.URLToShort[original]
+	if !ok {
+		return storage.Link{}, false
+	}
+	return m.GetByShort(short)
 }
 
-func (m *mockStore) RUnlock() {
-	// No-op for testing
+func (m *mockStore) LookupBatch(shorts, originals []string) (map[string]storage.Link, map[string]storage.Link) {
+	m.Lookups++
+	byShort := make(map[string]storage.Link)
+	for _, short := range shorts {
+		if link, ok := m.ShortToURL[short]; ok {
+			byShort[short] = link
+		}
+	}
+	byOriginal := make(map[string]storage.Link)
+	for _, original := range originals {
+		if link, ok := m.GetByOriginal(original); ok {
+			byOriginal[original] = link
+		}
+	}
+	return byShort, byOriginal
+}
+
+func (m *mockStore) IncrementDomain(domain string) (int, error) {
+	m.DomainCounts[domain]++
+	return m.DomainCounts[domain], nil
+}
+
+func (m *mockStore) IncrementDomains(counts map[string]int) (map[string]int, error) {
+	if m.DomainsErr != nil {
+		return nil, m.DomainsErr
+	}
+	totals := make(map[string]int, len(counts))
+	for domain, n := range counts {
+		m.DomainCounts[domain] += n
+		totals[domain] = m.DomainCounts[domain]
+	}
+	return totals, nil
+}
+
+func (m *mockStore) DomainHits() map[string]int {
+	result := make(map[string]int, len(m.DomainCounts))
+	for k, v := range m.DomainCounts {
+		result[k] = v
+	}
+	return result
+}
+
+func (m *mockStore) List() []storage.Link {
+	links := make([]storage.Link, 0, len(m.ShortToURL))
+	for _, link := range m.ShortToURL {
+		links = append(links, link)
+	}
+	return links
+}
+
+func (m *mockStore) Len() int {
+	return len(m.ShortToURL)
+}
+
+func (m *mockStore) Update(link storage.Link) (storage.Link, error) {
+	current, exists := m.ShortToURL[link.Short]
+	if !exists {
+		return storage.Link{}, storage.ErrNotFound
+	}
+	if current.Version != link.Version {
+		return storage.Link{}, storage.ErrVersionConflict
+	}
+	if m.URLToShort[current.DedupKey()] == current.Short {
+		delete(m.URLToShort, current.DedupKey())
+	}
+	link.Version++
+	m.ShortToURL[link.Short] = link
+	if link.Indexed() {
+		m.URLToShort[link.DedupKey()] = link.Short
+	}
+	return link, nil
+}
+
+func (m *mockStore) QueryLinks(q storage.LinkQuery) storage.LinkPage {
+	store := storage.NewStore()
+	for _, link := range m.ShortToURL {
+		store.Save(link)
+	}
+	store.RecordClicks(m.RecordedClicks())
+	return store.QueryLinks(q)
+}
+
+func (m *mockStore) Delete(short string) (bool, error) {
+	link, ok := m.ShortToURL[short]
+	if !ok {
+		return false, nil
+	}
+	delete(m.ShortToURL, short)
+	if m.URLToShort[link.DedupKey()] == short {
+		delete(m.URLToShort, link.DedupKey())
+	}
+	return true, nil
+}
+
+func (m *mockStore) DeleteExpired(now time.Time, limit int) (int, error) {
+	removed := 0
+	for short, link := range m.ShortToURL {
+		if removed == limit {
+			break
+		}
+		if link.Expired(now) {
+			m.Delete(short)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (m *mockStore) RecordClicks(clicks []storage.Click) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Clicks = append(m.Clicks, clicks...)
+	return nil
+}
+
+func (m *mockStore) RecordedClicks() []storage.Click {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]storage.Click(nil), m.Clicks...)
+}
+
+func (m *mockStore) Stats(short string) (storage.LinkStats, bool) {
+	if _, ok := m.ShortToURL[short]; !ok {
+		return storage.LinkStats{}, false
+	}
+	var stats storage.LinkStats
+	for _, c := range m.RecordedClicks() {
+		if c.Short == short {
+			stats.Clicks++
+		}
+	}
+	return stats, true
 }
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"slices"
+	"strings"
+)
+
+const (
+	// MaxTags is how many tags one link may carry.
+	MaxTags = 10
+	// MaxTagLength is the longest tag accepted.
+	MaxTagLength = 32
+)
+
+// NormalizeTags lowercases, deduplicates and sorts tags, rejecting any that
+// are empty, too long or contain characters other than letters, digits,
+// '-' and '_'.
+func NormalizeTags(tags []string) ([]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	out := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || len(tag) > MaxTagLength {
+			return nil, invalidURL(CodeInvalidTag, "tags must be 1 to %d characters", MaxTagLength)
+		}
+		for _, r := range tag {
+			if !isAliasChar(r) {
+				return nil, invalidURL(CodeInvalidTag, "tag %q may only contain letters, digits, '-' and '_'", tag)
+			}
+		}
+		out = append(out, tag)
+	}
+	slices.Sort(out)
+	out = slices.Compact(out)
+	if len(out) > MaxTags {
+		return nil, invalidURL(CodeInvalidTag, "a link may have at most %d tags", MaxTags)
+	}
+	return out, nil
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// DefaultMaxURLLength is the longest destination URL accepted by default.
+const DefaultMaxURLLength = 2048
+
+// DefaultAllowedSchemes are the destination schemes accepted by default.
+var DefaultAllowedSchemes = []string{"http", "https"}
+
+// Machine-readable codes carried by ValidationError.
+const (
+	CodeEmptyURL         = "empty_url"
+	CodeURLTooLong       = "url_too_long"
+	CodeMalformedURL     = "malformed_url"
+	CodeRelativeURL      = "relative_url"
+	CodeSchemeNotAllowed = "scheme_not_allowed"
+	CodeMissingHost      = "missing_host"
+	CodeInvalidHost      = "invalid_host"
+	CodeInvalidExpiry    = "invalid_expiry"
+	CodeInvalidRedirect  = "invalid_redirect"
+	CodeInvalidTag       = "invalid_tag"
+	CodeInvalidQuery     = "invalid_query"
+)
+
+// ValidationError reports why a shorten request was rejected.
+type ValidationError struct {
+	Code    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return "service: " + e.Message
+}
+
+func invalidURL(code, format string, args ...any) *ValidationError {
+	return &ValidationError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// WithAllowedSchemes replaces DefaultAllowedSchemes.
+func WithAllowedSchemes(schemes []string) Option {
+	return func(s *URLService) {
+		s.schemes = make(map[string]bool, len(schemes))
+		for _, scheme := range schemes {
+			s.schemes[strings.ToLower(scheme)] = true
+		}
+	}
+}
+
+// WithMaxURLLength replaces DefaultMaxURLLength.
+func WithMaxURLLength(n int) Option {
+	return func(s *URLService) {
+		s.maxURLLength = n
+	}
+}
+
+// ValidateURL checks that raw is an absolute URL with an allowed scheme and
+// a usable host, and returns it with any internationalized host converted to
+// its ASCII (punycode) form.
+func (s *URLService) ValidateURL(raw string) (string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return "", invalidURL(CodeEmptyURL, "url is required")
+	}
+	if len(raw) > s.maxURLLength {
+		return "", invalidURL(CodeURLTooLong, "url is longer than %d characters", s.maxURLLength)
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", invalidURL(CodeMalformedURL, "url could not be parsed")
+	}
+	if !u.IsAbs() {
+		return "", invalidURL(CodeRelativeURL, "url must be absolute, including a scheme")
+	}
+	if !s.schemes[strings.ToLower(u.Scheme)] {
+		return "", invalidURL(CodeSchemeNotAllowed, "scheme %q is not allowed", u.Scheme)
+	}
+	if u.Opaque != "" || u.Host == "" || u.Hostname() == "" {
+		return "", invalidURL(CodeMissingHost, "url must include a host")
+	}
+
+	hostname := u.Hostname()
+	if net.ParseIP(hostname) != nil {
+		return raw, nil
+	}
+	ascii, err := idna.Lookup.ToASCII(hostname)
+	if err != nil {
+		return "", invalidURL(CodeInvalidHost, "host %q is not valid", hostname)
+	}
+	if ascii == strings.ToLower(hostname) {
+		return raw, nil
+	}
+
+	// Location headers must be ASCII, so internationalized hosts are stored
+	// in punycode.
+	if port := u.Port(); port != "" {
+		ascii = net.JoinHostPort(ascii, port)
+	}
+	u.Host = ascii
+	return u.String(), nil
+}
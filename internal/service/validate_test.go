@@ -0,0 +1,92 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type ValidateTestSuite struct {
+	suite.Suite
+	Service *URLService
+}
+
+func TestValidateTestSuite(t *testing.T) {
+	suite.Run(t, new(ValidateTestSuite))
+}
+
+func (suite *ValidateTestSuite) SetupTest() {
+	suite.Service = NewURLService(newMockStore())
+}
+
+func (suite *ValidateTestSuite) TestRejectedURLs() {
+	cases := map[string]string{
+		"":                         CodeEmptyURL,
+		"   ":                      CodeEmptyURL,
+		"javascript:alert(1)":      CodeSchemeNotAllowed,
+		"ftp://example.com/file":   CodeSchemeNotAllowed,
+		"/relative/path":           CodeRelativeURL,
+		"example.com":              CodeRelativeURL,
+		"http://":                  CodeMissingHost,
+		"https:///path":            CodeMissingHost,
+		"http://exa mple.com":      CodeMalformedURL,
+		"http://%zz":               CodeMalformedURL,
+		"https://" + "⒈" + "x.com": CodeInvalidHost,
+		"https://example.com/" + strings.Repeat("a", DefaultMaxURLLength): CodeURLTooLong,
+	}
+	for raw, code := range cases {
+		_, err := suite.Service.ValidateURL(raw)
+
+		var verr *ValidationError
+		if assert.ErrorAs(suite.T(), err, &verr, raw) {
+			assert.Equal(suite.T(), code, verr.Code, raw)
+		}
+	}
+}
+
+func (suite *ValidateTestSuite) TestAcceptedURLs() {
+	cases := map[string]string{
+		"https://example.com":             "https://example.com",
+		"http://example.com:8080/a?b=c#d": "http://example.com:8080/a?b=c#d",
+		"HTTPS://Example.com/Path":        "HTTPS://Example.com/Path",
+		"http://127.0.0.1/":               "http://127.0.0.1/",
+		"http://[::1]:9000/x":             "http://[::1]:9000/x",
+		"https://bücher.example/katalog":  "https://xn--bcher-kva.example/katalog",
+		"https://bücher.example:444/":     "https://xn--bcher-kva.example:444/",
+	}
+	for raw, expected := range cases {
+		result, err := suite.Service.ValidateURL(raw)
+
+		assert.NoError(suite.T(), err, raw)
+		assert.Equal(suite.T(), expected, result, raw)
+	}
+}
+
+func (suite *ValidateTestSuite) TestConfiguredSchemesAndLength() {
+	svc := NewURLService(newMockStore(), WithAllowedSchemes([]string{"https", "FTP"}), WithMaxURLLength(30))
+
+	_, err := svc.ValidateURL("ftp://example.com/file")
+	assert.NoError(suite.T(), err)
+
+	_, err = svc.ValidateURL("http://example.com")
+	var verr *ValidationError
+	assert.ErrorAs(suite.T(), err, &verr)
+	assert.Equal(suite.T(), CodeSchemeNotAllowed, verr.Code)
+
+	_, err = svc.ValidateURL("https://example.com/a/very/long/path")
+	assert.ErrorAs(suite.T(), err, &verr)
+	assert.Equal(suite.T(), CodeURLTooLong, verr.Code)
+}
+
+func (suite *ValidateTestSuite) TestShortenStoresPunycodeHost() {
+	store := newMockStore()
+	svc := NewURLService(store)
+
+	short, err := svc.ShortenURL("https://bücher.example/", ShortenOptions{})
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "https://xn--bcher-kva.example", store.ShortToURL[short].Original)
+	assert.Equal(suite.T(), 1, store.DomainCounts["xn--bcher-kva.example"])
+}
@@ -0,0 +1,516 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"url-shortener/internal/health"
+)
+
+const (
+	walFileName      = "wal.log"
+	snapshotFileName = "snapshot.json"
+)
+
+// SyncPolicy controls when the write-ahead log is fsynced.
+type SyncPolicy string
+
+const (
+	// SyncAlways fsyncs after every record; no acknowledged write is lost.
+	SyncAlways SyncPolicy = "always"
+	// SyncInterval fsyncs on a timer; a crash loses at most one interval.
+	SyncInterval SyncPolicy = "interval"
+	// SyncNever leaves flushing to the operating system.
+	SyncNever SyncPolicy = "never"
+)
+
+// ParseSyncPolicy converts a configuration string into a SyncPolicy.
+func ParseSyncPolicy(s string) (SyncPolicy, error) {
+	switch p := SyncPolicy(s); p {
+	case SyncAlways, SyncInterval, SyncNever:
+		return p, nil
+	}
+	return "", fmt.Errorf("storage: unknown sync policy %q", s)
+}
+
+// FileOptions configures a FileStore.
+type FileOptions struct {
+	// Dir holds the log and snapshot files. It is created if missing.
+	Dir string
+	// Sync selects the fsync policy. Defaults to SyncAlways.
+	Sync SyncPolicy
+	// SyncEvery is the flush period for SyncInterval. Defaults to one second.
+	SyncEvery time.Duration
+	// CompactAfter is the number of log records after which the log is
+	// folded into a fresh snapshot. Zero disables automatic compaction.
+	CompactAfter int
+	// ExpiredRetention is how long expired links are kept before they may
+	// be reaped. Defaults to DefaultExpiredRetention.
+	ExpiredRetention time.Duration
+}
+
+// FileStore is a durable Repository. Reads are served from an in-memory
+// Store; every mutation is first appended to a write-ahead log and the log is
+// periodically compacted into a snapshot. OpenFileStore replays both.
+type FileStore struct {
+	*Store
+
+	opts FileOptions
+	// mu orders log appends with the in-memory updates they describe.
+	mu      sync.Mutex
+	wal     *os.File
+	records int
+	closed  bool
+	// failure is the error of the last write to the log or snapshot, or nil
+	// if it succeeded; the wal health check reports it.
+	failure error
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+var (
+	_ Repository     = (*FileStore)(nil)
+	_ health.Checker = (*FileStore)(nil)
+)
+
+type snapshot struct {
+	Links      []Link               `json:"links"`
+	DomainHits map[string]int       `json:"domain_hits"`
+	Stats      map[string]LinkStats `json:"stats,omitempty"`
+}
+
+// OpenFileStore loads the snapshot and log found in opts.Dir and returns a
+// store ready for writes. A record torn by a crash at the end of the log is
+// discarded and the log is truncated to the last intact record.
+func OpenFileStore(opts FileOptions) (*FileStore, error) {
+	if opts.Sync == "" {
+		opts.Sync = SyncAlways
+	}
+	if opts.SyncEvery <= 0 {
+		opts.SyncEvery = time.Second
+	}
+	if opts.ExpiredRetention <= 0 {
+		opts.ExpiredRetention = DefaultExpiredRetention
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("storage: create data dir: %w", err)
+	}
+
+	f := &FileStore{Store: NewStoreWithRetention(opts.ExpiredRetention), opts: opts}
+	if err := f.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	if err := f.replayWAL(); err != nil {
+		return nil, err
+	}
+
+	if opts.Sync == SyncInterval {
+		f.stop = make(chan struct{})
+		f.done = make(chan struct{})
+		go f.syncLoop()
+	}
+	return f, nil
+}
+
+func (f *FileStore) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(f.opts.Dir, snapshotFileName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("storage: read snapshot: %w", err)
+	}
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("storage: decode snapshot: %w", err)
+	}
+	for _, link := range snap.Links {
+		f.Store.Save(link)
+	}
+	for domain, count := range snap.DomainHits {
+		f.Store.setDomain(domain, count)
+	}
+	for short, st := range snap.Stats {
+		f.Store.restoreStats(short, st)
+	}
+	return nil
+}
+
+func (f *FileStore) replayWAL() error {
+	path := filepath.Join(f.opts.Dir, walFileName)
+	wal, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("storage: open wal: %w", err)
+	}
+
+	valid, err := readRecords(wal, func(rec walRecord) error {
+		f.apply(rec)
+		f.records++
+		return nil
+	})
+	if err != nil {
+		wal.Close()
+		return fmt.Errorf("storage: replay wal: %w", err)
+	}
+	if err := wal.Truncate(valid); err != nil {
+		wal.Close()
+		return fmt.Errorf("storage: truncate torn wal: %w", err)
+	}
+	f.wal = wal
+	return nil
+}
+
+func (f *FileStore) apply(rec walRecord) {
+	switch rec.Op {
+	case opSave:
+		if rec.Link != nil {
+			f.Store.Save(*rec.Link)
+		}
+	case opDelete:
+		f.Store.Delete(rec.Short)
+	case opDomain:
+		f.Store.setDomain(rec.Domain, rec.Count)
+	case opClick:
+		if rec.Click != nil {
+			f.Store.setStats(*rec.Click)
+		}
+	}
+}
+
+// append writes rec to the log and applies it to memory. Callers hold f.mu.
+func (f *FileStore) append(rec walRecord) error {
+	return f.appendBatch([]walRecord{rec})
+}
+
+// appendBatch writes recs with a single fsync and applies them to memory.
+// Callers hold f.mu.
+func (f *FileStore) appendBatch(recs []walRecord) error {
+	if f.closed {
+		return fmt.Errorf("storage: store is closed")
+	}
+	if len(recs) == 0 {
+		return nil
+	}
+	var buf []byte
+	for _, rec := range recs {
+		encoded, err := encodeRecord(rec)
+		if err != nil {
+			return err
+		}
+		buf = append(buf, encoded...)
+	}
+	if err := f.write(buf); err != nil {
+		return err
+	}
+	for _, rec := range recs {
+		f.apply(rec)
+	}
+	f.records += len(recs)
+
+	// The records are durable and applied whatever becomes of compaction, so
+	// its failure is not the caller's: it shows in the health check, and the
+	// next write tries again.
+	if f.opts.CompactAfter > 0 && f.records >= f.opts.CompactAfter {
+		if err := f.compactLocked(); err != nil {
+			slog.Error("storage: automatic compaction failed", "dir", f.opts.Dir, "err", err)
+		}
+	}
+	return nil
+}
+
+// write appends buf to the log, syncing it if the policy asks to, and
+// records the outcome for the health check. Callers hold f.mu.
+func (f *FileStore) write(buf []byte) error {
+	if _, err := f.wal.Write(buf); err != nil {
+		f.failure = fmt.Errorf("storage: append wal: %w", err)
+		return f.failure
+	}
+	if f.opts.Sync == SyncAlways {
+		if err := f.wal.Sync(); err != nil {
+			f.failure = fmt.Errorf("storage: sync wal: %w", err)
+			return f.failure
+		}
+	}
+	f.failure = nil
+	return nil
+}
+
+func (f *FileStore) Save(link Link) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.append(walRecord{Op: opSave, Link: &link})
+}
+
+func (f *FileStore) Insert(link Link) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, exists := f.Store.GetByShort(link.Short); exists {
+		return ErrCodeTaken
+	}
+	return f.append(walRecord{Op: opSave, Link: &link})
+}
+
+// InsertBatch logs every insertable link with a single write and fsync.
+func (f *FileStore) InsertBatch(links []Link) []error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	errs := make([]error, len(links))
+	claimed := make(map[string]bool, len(links))
+	recs := make([]walRecord, 0, len(links))
+	var written []int
+	for i := range links {
+		short := links[i].Short
+		if _, exists := f.Store.GetByShort(short); exists || claimed[short] {
+			errs[i] = ErrCodeTaken
+			continue
+		}
+		claimed[short] = true
+		recs = append(recs, walRecord{Op: opSave, Link: &links[i]})
+		written = append(written, i)
+	}
+	if err := f.appendBatch(recs); err != nil {
+		for _, i := range written {
+			errs[i] = err
+		}
+	}
+	return errs
+}
+
+func (f *FileStore) Update(link Link) (Link, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	next, err := f.Store.versioned(link)
+	if err != nil {
+		return Link{}, err
+	}
+	if err := f.append(walRecord{Op: opSave, Link: &next}); err != nil {
+		return Link{}, err
+	}
+	return next, nil
+}
+
+func (f *FileStore) IncrementDomain(domain string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	count := f.Store.domainCount(domain) + 1
+	if err := f.append(walRecord{Op: opDomain, Domain: domain, Count: count}); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (f *FileStore) IncrementDomains(counts map[string]int) (map[string]int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	totals := make(map[string]int, len(counts))
+	recs := make([]walRecord, 0, len(counts))
+	for domain, n := range counts {
+		totals[domain] = f.Store.domainCount(domain) + n
+		recs = append(recs, walRecord{Op: opDomain, Domain: domain, Count: totals[domain]})
+	}
+	if err := f.appendBatch(recs); err != nil {
+		return nil, err
+	}
+	return totals, nil
+}
+
+func (f *FileStore) Delete(short string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, exists := f.Store.GetByShort(short); !exists {
+		return false, nil
+	}
+	if err := f.append(walRecord{Op: opDelete, Short: short}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DeleteExpired reaps expired links from memory and logs a delete record for
+// each. Memory is updated first: if logging fails the links come back on
+// restart already expired, and the next reap removes them again.
+func (f *FileStore) DeleteExpired(now time.Time, limit int) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return 0, fmt.Errorf("storage: store is closed")
+	}
+
+	removed := f.Store.deleteExpired(now, limit)
+	recs := make([]walRecord, len(removed))
+	for i, short := range removed {
+		recs[i] = walRecord{Op: opDelete, Short: short}
+	}
+	if err := f.appendBatch(recs); err != nil {
+		return 0, err
+	}
+	return len(removed), nil
+}
+
+// RecordClicks updates statistics in memory and logs the resulting counters.
+// Like DeleteExpired it updates memory first; losing a few clicks on a
+// failed write is preferable to failing redirects.
+func (f *FileStore) RecordClicks(clicks []Click) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return fmt.Errorf("storage: store is closed")
+	}
+
+	updates := f.Store.recordClicks(clicks)
+	recs := make([]walRecord, len(updates))
+	for i := range updates {
+		recs[i] = walRecord{Op: opClick, Click: &updates[i]}
+	}
+	return f.appendBatch(recs)
+}
+
+// Compact writes the current state to a new snapshot and empties the log.
+func (f *FileStore) Compact() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return fmt.Errorf("storage: store is closed")
+	}
+	return f.compactLocked()
+}
+
+func (f *FileStore) compactLocked() error {
+	err := f.compact()
+	f.failure = err
+	return err
+}
+
+func (f *FileStore) compact() error {
+	snap := snapshot{Links: f.Store.indexedFirst(), DomainHits: f.Store.DomainHits(), Stats: f.Store.allStats()}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(f.opts.Dir, snapshotFileName)
+	tmp := path + ".tmp"
+	if err := writeFileSync(tmp, data); err != nil {
+		return fmt.Errorf("storage: write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("storage: install snapshot: %w", err)
+	}
+	if err := syncDir(f.opts.Dir); err != nil {
+		return fmt.Errorf("storage: sync data dir: %w", err)
+	}
+
+	// The snapshot now covers every logged record. A crash before the
+	// truncate below only means the log is replayed on top of it, which is
+	// safe because records are idempotent.
+	if err := f.wal.Truncate(0); err != nil {
+		return fmt.Errorf("storage: truncate wal: %w", err)
+	}
+	if err := f.wal.Sync(); err != nil {
+		return fmt.Errorf("storage: sync wal: %w", err)
+	}
+	f.records = 0
+	return nil
+}
+
+func (f *FileStore) syncLoop() {
+	defer close(f.done)
+	ticker := time.NewTicker(f.opts.SyncEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			f.mu.Lock()
+			if !f.closed {
+				if err := f.wal.Sync(); err != nil {
+					f.failure = fmt.Errorf("storage: sync wal: %w", err)
+				} else {
+					f.failure = nil
+				}
+			}
+			f.mu.Unlock()
+		case <-f.stop:
+			return
+		}
+	}
+}
+
+// HealthChecks reports the store unhealthy once it is closed, while the
+// last write to its log or snapshot has failed, or when its data directory
+// has gone away.
+func (f *FileStore) HealthChecks() map[string]health.Check {
+	return map[string]health.Check{
+		"wal": func(context.Context) error {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			if f.closed {
+				return fmt.Errorf("storage: store is closed")
+			}
+			return f.failure
+		},
+		"data_dir": func(context.Context) error {
+			info, err := os.Stat(f.opts.Dir)
+			if err != nil {
+				return fmt.Errorf("storage: data dir: %w", err)
+			}
+			if !info.IsDir() {
+				return fmt.Errorf("storage: data dir %s is not a directory", f.opts.Dir)
+			}
+			return nil
+		},
+	}
+}
+
+// Close flushes the log and releases the underlying file. The store must not
+// be used afterwards.
+func (f *FileStore) Close() error {
+	if f.stop != nil {
+		close(f.stop)
+		<-f.done
+		f.stop = nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	if err := f.wal.Sync(); err != nil {
+		f.wal.Close()
+		return err
+	}
+	return f.wal.Close()
+}
+
+func writeFileSync(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,364 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type FileStoreTestSuite struct {
+	suite.Suite
+	Dir string
+}
+
+func TestFileStoreTestSuite(t *testing.T) {
+	suite.Run(t, new(FileStoreTestSuite))
+}
+
+func (suite *FileStoreTestSuite) SetupTest() {
+	suite.Dir = suite.T().TempDir()
+}
+
+func (suite *FileStoreTestSuite) open(opts FileOptions) *FileStore {
+	opts.Dir = suite.Dir
+	store, err := OpenFileStore(opts)
+	require.NoError(suite.T(), err)
+	return store
+}
+
+func (suite *FileStoreTestSuite) walPath() string {
+	return filepath.Join(suite.Dir, walFileName)
+}
+
+func (suite *FileStoreTestSuite) TestReopenReplaysLog() {
+	store := suite.open(FileOptions{})
+	require.NoError(suite.T(), store.Save(Link{Short: "abc123", Original: "https://example.com"}))
+	require.NoError(suite.T(), store.Save(Link{Short: "def456", Original: "https://test.com"}))
+	_, err := store.IncrementDomain("example.com")
+	require.NoError(suite.T(), err)
+	_, err = store.IncrementDomain("example.com")
+	require.NoError(suite.T(), err)
+	_, err = store.Delete("def456")
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), store.Close())
+
+	reopened := suite.open(FileOptions{})
+	defer reopened.Close()
+
+	link, ok := reopened.GetByShort("abc123")
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), "https://example.com", link.Original)
+	_, ok = reopened.GetByShort("def456")
+	assert.False(suite.T(), ok, "Deleted link should stay deleted after replay")
+	assert.Equal(suite.T(), 2, reopened.DomainHits()["example.com"])
+}
+
+func (suite *FileStoreTestSuite) TestCompactWritesSnapshotAndEmptiesLog() {
+	store := suite.open(FileOptions{})
+	require.NoError(suite.T(), store.Save(Link{Short: "abc123", Original: "https://example.com"}))
+	_, err := store.IncrementDomain("example.com")
+	require.NoError(suite.T(), err)
+
+	require.NoError(suite.T(), store.Compact())
+
+	info, err := os.Stat(suite.walPath())
+	require.NoError(suite.T(), err)
+	assert.Zero(suite.T(), info.Size(), "Log should be empty after compaction")
+	_, err = os.Stat(filepath.Join(suite.Dir, snapshotFileName))
+	assert.NoError(suite.T(), err, "Snapshot should exist after compaction")
+
+	require.NoError(suite.T(), store.Save(Link{Short: "def456", Original: "https://test.com"}))
+	require.NoError(suite.T(), store.Close())
+
+	reopened := suite.open(FileOptions{})
+	defer reopened.Close()
+	assert.Len(suite.T(), reopened.List(), 2, "Snapshot and log should both be replayed")
+	assert.Equal(suite.T(), 1, reopened.DomainHits()["example.com"])
+}
+
+func (suite *FileStoreTestSuite) TestCompactionKeepsReverseIndex() {
+	store := suite.open(FileOptions{})
+	for _, short := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		require.NoError(suite.T(), store.Insert(Link{Short: short, Original: "https://example.com", Tags: []string{"promo"}}))
+	}
+	_, err := store.Update(Link{Short: "e", Original: "https://example.com"})
+	require.NoError(suite.T(), err)
+	for _, short := range []string{"a", "b", "c", "d", "f", "g", "h"} {
+		_, err := store.Update(Link{Short: short, Original: "https://example.com"})
+		require.NoError(suite.T(), err)
+	}
+	require.NoError(suite.T(), store.Compact())
+	require.NoError(suite.T(), store.Close())
+
+	reopened := suite.open(FileOptions{})
+	defer reopened.Close()
+	link, ok := reopened.GetByOriginal("https://example.com")
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), "e", link.Short, "The snapshot should restore the same reverse index")
+}
+
+func (suite *FileStoreTestSuite) TestOversizedRecordIsRejected() {
+	store := suite.open(FileOptions{})
+	huge := Link{Short: "huge", Original: "https://example.com/" + strings.Repeat("a", walMaxRecord)}
+
+	assert.ErrorIs(suite.T(), store.Save(huge), ErrRecordTooLarge)
+	require.NoError(suite.T(), store.Save(Link{Short: "a", Original: "https://a.com"}))
+	require.NoError(suite.T(), store.Save(Link{Short: "b", Original: "https://b.com"}))
+	require.NoError(suite.T(), store.Close())
+
+	reopened := suite.open(FileOptions{})
+	defer reopened.Close()
+	_, ok := reopened.GetByShort("huge")
+	assert.False(suite.T(), ok)
+	assert.Equal(suite.T(), 2, reopened.Len(), "Writes after the rejected one should survive replay")
+}
+
+func (suite *FileStoreTestSuite) TestAutomaticCompaction() {
+	store := suite.open(FileOptions{CompactAfter: 2})
+	require.NoError(suite.T(), store.Save(Link{Short: "a", Original: "https://a.com"}))
+	require.NoError(suite.T(), store.Save(Link{Short: "b", Original: "https://b.com"}))
+	require.NoError(suite.T(), store.Close())
+
+	info, err := os.Stat(suite.walPath())
+	require.NoError(suite.T(), err)
+	assert.Zero(suite.T(), info.Size(), "Reaching CompactAfter should fold the log into a snapshot")
+}
+
+func (suite *FileStoreTestSuite) TestFailedAutomaticCompactionKeepsWrite() {
+	store := suite.open(FileOptions{CompactAfter: 1})
+	// A directory where the snapshot is staged makes every compaction fail.
+	require.NoError(suite.T(), os.Mkdir(filepath.Join(suite.Dir, snapshotFileName+".tmp"), 0o755))
+
+	assert.NoError(suite.T(), store.Save(Link{Short: "abc123", Original: "https://example.com"}))
+	_, ok := store.GetByShort("abc123")
+	assert.True(suite.T(), ok)
+	assert.ErrorContains(suite.T(), store.HealthChecks()["wal"](context.Background()), "write snapshot")
+	require.NoError(suite.T(), store.Close())
+
+	reopened := suite.open(FileOptions{})
+	defer reopened.Close()
+	_, ok = reopened.GetByShort("abc123")
+	assert.True(suite.T(), ok, "The record should have been logged")
+}
+
+func (suite *FileStoreTestSuite) TestRecoveryFromTruncatedRecord() {
+	store := suite.open(FileOptions{})
+	require.NoError(suite.T(), store.Save(Link{Short: "abc123", Original: "https://example.com"}))
+	require.NoError(suite.T(), store.Close())
+	info, err := os.Stat(suite.walPath())
+	require.NoError(suite.T(), err)
+	intact := info.Size()
+
+	store = suite.open(FileOptions{})
+	require.NoError(suite.T(), store.Save(Link{Short: "def456", Original: "https://test.com"}))
+	require.NoError(suite.T(), store.Close())
+
+	// Simulate a crash halfway through writing the second record.
+	info, err = os.Stat(suite.walPath())
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), os.Truncate(suite.walPath(), intact+(info.Size()-intact)/2))
+
+	reopened := suite.open(FileOptions{})
+	_, ok := reopened.GetByShort("abc123")
+	assert.True(suite.T(), ok, "Records before the torn one should survive")
+	_, ok = reopened.GetByShort("def456")
+	assert.False(suite.T(), ok, "The torn record should be discarded")
+
+	info, err = os.Stat(suite.walPath())
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), intact, info.Size(), "Log should be truncated to the last intact record")
+
+	require.NoError(suite.T(), reopened.Save(Link{Short: "ghi789", Original: "https://other.com"}))
+	require.NoError(suite.T(), reopened.Close())
+
+	final := suite.open(FileOptions{})
+	defer final.Close()
+	_, ok = final.GetByShort("ghi789")
+	assert.True(suite.T(), ok, "Writes after recovery should be readable")
+	assert.Len(suite.T(), final.List(), 2)
+}
+
+func (suite *FileStoreTestSuite) TestRecoveryFromTruncatedHeader() {
+	store := suite.open(FileOptions{})
+	require.NoError(suite.T(), store.Save(Link{Short: "abc123", Original: "https://example.com"}))
+	require.NoError(suite.T(), store.Close())
+	info, err := os.Stat(suite.walPath())
+	require.NoError(suite.T(), err)
+
+	file, err := os.OpenFile(suite.walPath(), os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(suite.T(), err)
+	_, err = file.Write([]byte{0x10, 0x00, 0x00})
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), file.Close())
+
+	reopened := suite.open(FileOptions{})
+	defer reopened.Close()
+	assert.Len(suite.T(), reopened.List(), 1)
+	info2, err := os.Stat(suite.walPath())
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), info.Size(), info2.Size())
+}
+
+func (suite *FileStoreTestSuite) TestRecoveryFromCorruptChecksum() {
+	store := suite.open(FileOptions{})
+	require.NoError(suite.T(), store.Save(Link{Short: "abc123", Original: "https://example.com"}))
+	require.NoError(suite.T(), store.Save(Link{Short: "def456", Original: "https://test.com"}))
+	require.NoError(suite.T(), store.Close())
+
+	data, err := os.ReadFile(suite.walPath())
+	require.NoError(suite.T(), err)
+	data[len(data)-2] ^= 0xff
+	require.NoError(suite.T(), os.WriteFile(suite.walPath(), data, 0o644))
+
+	reopened := suite.open(FileOptions{})
+	defer reopened.Close()
+	_, ok := reopened.GetByShort("abc123")
+	assert.True(suite.T(), ok)
+	_, ok = reopened.GetByShort("def456")
+	assert.False(suite.T(), ok, "A record with a bad checksum should be discarded")
+}
+
+func (suite *FileStoreTestSuite) TestReplayAfterCrashDuringCompaction() {
+	store := suite.open(FileOptions{})
+	_, err := store.IncrementDomain("example.com")
+	require.NoError(suite.T(), err)
+	_, err = store.IncrementDomain("example.com")
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), store.Close())
+	wal, err := os.ReadFile(suite.walPath())
+	require.NoError(suite.T(), err)
+
+	store = suite.open(FileOptions{})
+	require.NoError(suite.T(), store.Compact())
+	require.NoError(suite.T(), store.Close())
+	// Put the log back as if the process died before truncating it.
+	require.NoError(suite.T(), os.WriteFile(suite.walPath(), wal, 0o644))
+
+	reopened := suite.open(FileOptions{})
+	defer reopened.Close()
+	assert.Equal(suite.T(), 2, reopened.DomainHits()["example.com"], "Replaying logged counters over a snapshot must not double count")
+}
+
+func (suite *FileStoreTestSuite) TestSyncIntervalPolicy() {
+	store := suite.open(FileOptions{Sync: SyncInterval})
+	require.NoError(suite.T(), store.Save(Link{Short: "abc123", Original: "https://example.com"}))
+	require.NoError(suite.T(), store.Close())
+
+	reopened := suite.open(FileOptions{Sync: SyncNever})
+	defer reopened.Close()
+	_, ok := reopened.GetByShort("abc123")
+	assert.True(suite.T(), ok)
+}
+
+func (suite *FileStoreTestSuite) TestWriteAfterCloseFails() {
+	store := suite.open(FileOptions{})
+	require.NoError(suite.T(), store.Close())
+
+	assert.Error(suite.T(), store.Save(Link{Short: "abc123", Original: "https://example.com"}))
+}
+
+func (suite *FileStoreTestSuite) TestHealthChecks() {
+	store := suite.open(FileOptions{})
+	check := func(name string) error {
+		return store.HealthChecks()[name](context.Background())
+	}
+	assert.NoError(suite.T(), check("wal"))
+	assert.NoError(suite.T(), check("data_dir"))
+
+	// Pull the log out from under the store so the next append fails.
+	require.NoError(suite.T(), store.wal.Close())
+	assert.Error(suite.T(), store.Save(Link{Short: "abc123", Original: "https://example.com"}))
+	assert.ErrorContains(suite.T(), check("wal"), "append wal")
+
+	require.NoError(suite.T(), os.RemoveAll(suite.Dir))
+	assert.Error(suite.T(), check("data_dir"))
+
+	store.Close()
+	assert.ErrorContains(suite.T(), check("wal"), "closed")
+}
+
+func (suite *FileStoreTestSuite) TestParseSyncPolicy() {
+	policy, err := ParseSyncPolicy("interval")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), SyncInterval, policy)
+
+	_, err = ParseSyncPolicy("sometimes")
+	assert.Error(suite.T(), err)
+}
+
+func (suite *FileStoreTestSuite) TestClickStatsSurviveReplayAndCompaction() {
+	at := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	store := suite.open(FileOptions{})
+	require.NoError(suite.T(), store.Save(Link{Short: "abc123", Original: "https://example.com"}))
+	require.NoError(suite.T(), store.RecordClicks([]Click{
+		{Short: "abc123", At: at, Referrer: "news.example", UserAgent: "curl"},
+		{Short: "abc123", At: at.Add(time.Minute), UserAgent: "curl"},
+	}))
+	require.NoError(suite.T(), store.Close())
+	wal, err := os.ReadFile(suite.walPath())
+	require.NoError(suite.T(), err)
+
+	store = suite.open(FileOptions{})
+	require.NoError(suite.T(), store.Compact())
+	require.NoError(suite.T(), store.Close())
+	require.NoError(suite.T(), os.WriteFile(suite.walPath(), wal, 0o644))
+
+	reopened := suite.open(FileOptions{})
+	defer reopened.Close()
+	stats, ok := reopened.Stats("abc123")
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), int64(2), stats.Clicks, "Replaying click records over a snapshot must not double count")
+	assert.True(suite.T(), at.Equal(stats.FirstClick))
+	assert.True(suite.T(), at.Add(time.Minute).Equal(stats.LastClick))
+	assert.Equal(suite.T(), int64(1), stats.Referrers["news.example"])
+	assert.Equal(suite.T(), int64(1), stats.Referrers[NoneValue])
+	assert.Equal(suite.T(), int64(2), stats.UserAgents["curl"])
+}
+
+func (suite *FileStoreTestSuite) TestUpdateSurvivesReplay() {
+	store := suite.open(FileOptions{})
+	require.NoError(suite.T(), store.Insert(Link{Short: "abc123", Original: "https://example.com"}))
+	_, err := store.Update(Link{Short: "abc123", Original: "https://other.com"})
+	require.NoError(suite.T(), err)
+	_, err = store.Update(Link{Short: "abc123", Original: "https://stale.com"})
+	assert.ErrorIs(suite.T(), err, ErrVersionConflict)
+	require.NoError(suite.T(), store.Close())
+
+	reopened := suite.open(FileOptions{})
+	defer reopened.Close()
+	link, ok := reopened.GetByShort("abc123")
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), "https://other.com", link.Original)
+	assert.Equal(suite.T(), int64(1), link.Version)
+	_, ok = reopened.GetByOriginal("https://example.com")
+	assert.False(suite.T(), ok)
+}
+
+func (suite *FileStoreTestSuite) TestInsertBatchSurvivesReplay() {
+	store := suite.open(FileOptions{})
+	require.NoError(suite.T(), store.Insert(Link{Short: "taken", Original: "https://example.com"}))
+	errs := store.InsertBatch([]Link{
+		{Short: "a", Original: "https://a.com"},
+		{Short: "a", Original: "https://b.com"},
+		{Short: "taken", Original: "https://c.com"},
+	})
+	assert.Equal(suite.T(), []error{nil, ErrCodeTaken, ErrCodeTaken}, errs)
+	_, err := store.IncrementDomains(map[string]int{"a.com": 2})
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), store.Close())
+
+	reopened := suite.open(FileOptions{})
+	defer reopened.Close()
+	link, ok := reopened.GetByShort("a")
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), "https://a.com", link.Original)
+	assert.Equal(suite.T(), 2, reopened.Len())
+	assert.Equal(suite.T(), 2, reopened.DomainHits()["a.com"])
+}
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"slices"
+	"strings"
+)
+
+// indexEntry orders one link within an orderedIndex. Short breaks ties so
+// every entry, and therefore every cursor, has a unique position.
+type indexEntry struct {
+	key   int64
+	short string
+}
+
+func compareEntries(a, b indexEntry) int {
+	switch {
+	case a.key < b.key:
+		return -1
+	case a.key > b.key:
+		return 1
+	}
+	return strings.Compare(a.short, b.short)
+}
+
+// orderedIndex keeps entries sorted in a slice. Lookups are binary searches;
+// inserts and deletes shift the tail, which is a single memmove and far
+// cheaper than sorting the whole store on every listing. Creation times
+// mostly arrive in order, so new links are usually appended.
+type orderedIndex struct {
+	entries []indexEntry
+}
+
+// search returns the position of the first entry not less than e.
+func (x *orderedIndex) search(e indexEntry) int {
+	i, _ := slices.BinarySearchFunc(x.entries, e, compareEntries)
+	return i
+}
+
+func (x *orderedIndex) insert(e indexEntry) {
+	i, found := slices.BinarySearchFunc(x.entries, e, compareEntries)
+	if !found {
+		x.entries = slices.Insert(x.entries, i, e)
+	}
+}
+
+func (x *orderedIndex) delete(e indexEntry) {
+	if i, found := slices.BinarySearchFunc(x.entries, e, compareEntries); found {
+		x.entries = slices.Delete(x.entries, i, i+1)
+	}
+}
+
+// ownerIndex holds one owner's links in the orders of Store.byCreated and
+// Store.byClicks, so an owner's listing walks only their links.
+type ownerIndex struct {
+	created orderedIndex
+	clicks  orderedIndex
+}
+
+// setIndex maps an attribute value to the short codes carrying it.
+type setIndex map[string]map[string]struct{}
+
+func (x setIndex) add(value, short string) {
+	if value == "" {
+		return
+	}
+	shorts := x[value]
+	if shorts == nil {
+		shorts = make(map[string]struct{})
+		x[value] = shorts
+	}
+	shorts[short] = struct{}{}
+}
+
+func (x setIndex) remove(value, short string) {
+	shorts := x[value]
+	delete(shorts, short)
+	if len(shorts) == 0 {
+		delete(x, value)
+	}
+}
+
+// index adds link to the secondary indexes. Callers hold s.mu.
+func (s *Store) index(link Link) {
+	s.byCreated.insert(indexEntry{key: link.CreatedAt.UnixNano(), short: link.Short})
+	s.byClicks.insert(indexEntry{key: s.clicks(link.Short), short: link.Short})
+	s.byDomain.add(link.Domain(), link.Short)
+	if link.Owner != "" {
+		owner := s.byOwner[link.Owner]
+		if owner == nil {
+			owner = &ownerIndex{}
+			s.byOwner[link.Owner] = owner
+		}
+		owner.created.insert(indexEntry{key: link.CreatedAt.UnixNano(), short: link.Short})
+		owner.clicks.insert(indexEntry{key: s.clicks(link.Short), short: link.Short})
+	}
+	for _, tag := range link.Tags {
+		s.byTag.add(tag, link.Short)
+	}
+}
+
+// unindex removes link from the secondary indexes. It must run before the
+// link's statistics are dropped. Callers hold s.mu.
+func (s *Store) unindex(link Link) {
+	s.byCreated.delete(indexEntry{key: link.CreatedAt.UnixNano(), short: link.Short})
+	s.byClicks.delete(indexEntry{key: s.clicks(link.Short), short: link.Short})
+	s.byDomain.remove(link.Domain(), link.Short)
+	if owner := s.byOwner[link.Owner]; owner != nil {
+		owner.created.delete(indexEntry{key: link.CreatedAt.UnixNano(), short: link.Short})
+		owner.clicks.delete(indexEntry{key: s.clicks(link.Short), short: link.Short})
+		if len(owner.created.entries) == 0 {
+			delete(s.byOwner, link.Owner)
+		}
+	}
+	for _, tag := range link.Tags {
+		s.byTag.remove(tag, link.Short)
+	}
+}
+
+// clicks returns the click count of short. Callers hold s.mu.
+func (s *Store) clicks(short string) int64 {
+	if st := s.stats[short]; st != nil {
+		return st.Clicks
+	}
+	return 0
+}
+
+// reindexClicks moves short within the click orders after its count
+// changed from old. Callers hold s.mu.
+func (s *Store) reindexClicks(short string, old int64) {
+	link, exists := s.shortToURL[short]
+	if !exists {
+		return
+	}
+	now := s.clicks(short)
+	if now == old {
+		return
+	}
+	s.byClicks.delete(indexEntry{key: old, short: short})
+	s.byClicks.insert(indexEntry{key: now, short: short})
+	if owner := s.byOwner[link.Owner]; owner != nil {
+		owner.clicks.delete(indexEntry{key: old, short: short})
+		owner.clicks.insert(indexEntry{key: now, short: short})
+	}
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"log/slog"
+	"runtime"
+	"time"
+)
+
+// DefaultReapBatch is how many expired links the janitor removes per
+// DeleteExpired call when no batch size is given.
+const DefaultReapBatch = 500
+
+// Janitor periodically removes expired links from a Repository. Each sweep
+// works in batches so the store's write lock is only held briefly and
+// redirects keep flowing while a large backlog is reaped.
+type Janitor struct {
+	repo     Repository
+	interval time.Duration
+	batch    int
+	now      func() time.Time
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// StartJanitor launches a janitor goroutine that sweeps repo every interval.
+func StartJanitor(repo Repository, interval time.Duration, batch int) *Janitor {
+	if batch <= 0 {
+		batch = DefaultReapBatch
+	}
+	j := &Janitor{
+		repo:     repo,
+		interval: interval,
+		batch:    batch,
+		now:      time.Now,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go j.run()
+	return j
+}
+
+func (j *Janitor) run() {
+	defer close(j.done)
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			j.Sweep()
+		case <-j.stop:
+			return
+		}
+	}
+}
+
+// Sweep reaps every link that has expired by now and returns how many were
+// removed.
+func (j *Janitor) Sweep() int {
+	total := 0
+	now := j.now()
+	for {
+		n, err := j.repo.DeleteExpired(now, j.batch)
+		total += n
+		if err != nil {
+			slog.Error("janitor: reaping expired links", "err", err)
+			return total
+		}
+		if n < j.batch {
+			return total
+		}
+		select {
+		case <-j.stop:
+			return total
+		default:
+		}
+		// Let writers waiting on the lock in before the next batch.
+		runtime.Gosched()
+	}
+}
+
+// Stop ends the janitor and waits for an in-flight sweep to finish.
+func (j *Janitor) Stop() {
+	close(j.stop)
+	<-j.done
+}
@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type JanitorTestSuite struct {
+	suite.Suite
+	Store *Store
+	Now   time.Time
+}
+
+func TestJanitorTestSuite(t *testing.T) {
+	suite.Run(t, new(JanitorTestSuite))
+}
+
+func (suite *JanitorTestSuite) SetupTest() {
+	suite.Store = NewStore()
+	suite.Now = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+}
+
+func (suite *JanitorTestSuite) TestDeleteExpiredHonoursLimit() {
+	for i := 0; i < 5; i++ {
+		require.NoError(suite.T(), suite.Store.Save(Link{
+			Short:     fmt.Sprintf("exp%d", i),
+			Original:  fmt.Sprintf("https://example.com/%d", i),
+			ExpiresAt: suite.Now.Add(-DefaultExpiredRetention - time.Minute),
+		}))
+	}
+	require.NoError(suite.T(), suite.Store.Save(Link{Short: "live", Original: "https://live.com", ExpiresAt: suite.Now.Add(time.Hour)}))
+	require.NoError(suite.T(), suite.Store.Save(Link{Short: "forever", Original: "https://forever.com"}))
+
+	n, err := suite.Store.DeleteExpired(suite.Now, 3)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 3, n)
+
+	n, err = suite.Store.DeleteExpired(suite.Now, 3)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, n)
+	assert.Len(suite.T(), suite.Store.List(), 2)
+}
+
+func (suite *JanitorTestSuite) TestDeleteExpiredSkipsReplacedLinks() {
+	require.NoError(suite.T(), suite.Store.Save(Link{Short: "abc", Original: "https://example.com", ExpiresAt: suite.Now.Add(-DefaultExpiredRetention - time.Minute)}))
+	require.NoError(suite.T(), suite.Store.Save(Link{Short: "abc", Original: "https://example.com"}))
+
+	n, err := suite.Store.DeleteExpired(suite.Now, 10)
+
+	assert.NoError(suite.T(), err)
+	assert.Zero(suite.T(), n, "A link whose expiry was cleared must not be reaped")
+	_, ok := suite.Store.GetByShort("abc")
+	assert.True(suite.T(), ok)
+}
+
+func (suite *JanitorTestSuite) TestDeleteExpiredCleansReverseIndex() {
+	require.NoError(suite.T(), suite.Store.Save(Link{Short: "abc", Original: "https://example.com"}))
+	require.NoError(suite.T(), suite.Store.Save(Link{Short: "tmp", Original: "https://example.com", ExpiresAt: suite.Now.Add(-DefaultExpiredRetention)}))
+
+	_, err := suite.Store.DeleteExpired(suite.Now, 10)
+	assert.NoError(suite.T(), err)
+
+	link, ok := suite.Store.GetByOriginal("https://example.com")
+	assert.True(suite.T(), ok, "Reaping an expiring link must keep the permanent mapping indexed")
+	assert.Equal(suite.T(), "abc", link.Short)
+	_, ok = suite.Store.GetByShort("tmp")
+	assert.False(suite.T(), ok)
+}
+
+func (suite *JanitorTestSuite) TestSweepReapsInBatches() {
+	for i := 0; i < 25; i++ {
+		require.NoError(suite.T(), suite.Store.Save(Link{
+			Short:     fmt.Sprintf("exp%d", i),
+			Original:  fmt.Sprintf("https://example.com/%d", i),
+			ExpiresAt: suite.Now.Add(-DefaultExpiredRetention - time.Second),
+		}))
+	}
+	janitor := StartJanitor(suite.Store, time.Hour, 10)
+	janitor.now = func() time.Time { return suite.Now }
+	defer janitor.Stop()
+
+	assert.Equal(suite.T(), 25, janitor.Sweep())
+	assert.Empty(suite.T(), suite.Store.List())
+}
+
+func (suite *JanitorTestSuite) TestSweepKeepsRecentlyExpiredLinks() {
+	require.NoError(suite.T(), suite.Store.Save(Link{Short: "recent", Original: "https://recent.com", ExpiresAt: suite.Now.Add(-time.Minute)}))
+	require.NoError(suite.T(), suite.Store.Save(Link{Short: "old", Original: "https://old.com", ExpiresAt: suite.Now.Add(-DefaultExpiredRetention)}))
+	janitor := StartJanitor(suite.Store, time.Hour, 0)
+	janitor.now = func() time.Time { return suite.Now }
+	defer janitor.Stop()
+
+	assert.Equal(suite.T(), 1, janitor.Sweep())
+	_, ok := suite.Store.GetByShort("recent")
+	assert.True(suite.T(), ok, "Links should be kept for the retention after they expire")
+	_, ok = suite.Store.GetByShort("old")
+	assert.False(suite.T(), ok)
+}
+
+func (suite *JanitorTestSuite) TestConfiguredRetention() {
+	store, err := OpenFileStore(FileOptions{Dir: suite.T().TempDir(), ExpiredRetention: time.Hour})
+	require.NoError(suite.T(), err)
+	defer store.Close()
+	require.NoError(suite.T(), store.Save(Link{Short: "recent", Original: "https://recent.com", ExpiresAt: suite.Now.Add(-59 * time.Minute)}))
+	require.NoError(suite.T(), store.Save(Link{Short: "old", Original: "https://old.com", ExpiresAt: suite.Now.Add(-time.Hour)}))
+
+	n, err := store.DeleteExpired(suite.Now, 10)
+
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, n)
+	_, ok := store.GetByShort("recent")
+	assert.True(suite.T(), ok)
+}
+
+func (suite *JanitorTestSuite) TestJanitorRunsOnInterval() {
+	require.NoError(suite.T(), suite.Store.Save(Link{Short: "tmp", Original: "https://example.com", ExpiresAt: time.Now().Add(-DefaultExpiredRetention - time.Second)}))
+
+	janitor := StartJanitor(suite.Store, 10*time.Millisecond, 0)
+	defer janitor.Stop()
+
+	assert.Eventually(suite.T(), func() bool {
+		_, ok := suite.Store.GetByShort("tmp")
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+}
+
+func (suite *JanitorTestSuite) TestFileStoreLogsReapedLinks() {
+	dir := suite.T().TempDir()
+	store, err := OpenFileStore(FileOptions{Dir: dir})
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), store.Save(Link{Short: "tmp", Original: "https://example.com", ExpiresAt: suite.Now.Add(-DefaultExpiredRetention)}))
+
+	n, err := store.DeleteExpired(suite.Now, 10)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, n)
+	require.NoError(suite.T(), store.Close())
+
+	reopened, err := OpenFileStore(FileOptions{Dir: dir})
+	require.NoError(suite.T(), err)
+	defer reopened.Close()
+	_, ok := reopened.GetByShort("tmp")
+	assert.False(suite.T(), ok, "Reaped links should stay deleted after replay")
+}
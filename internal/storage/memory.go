@@ -1,20 +1,403 @@
 package storage
 
 import (
+	"container/heap"
 	"sync"
+	"time"
 )
 
+// Store is the in-memory Repository implementation.
 type Store struct {
-	URLToShort map[string]string
-	ShortToURL map[string]string
-	DomainHits map[string]int
-	Mutex      sync.RWMutex
+	mu         sync.RWMutex
+	urlToShort map[string]string
+	shortToURL map[string]Link
+	domainHits map[string]int
+	stats      map[string]*LinkStats
+	// expiries orders expiring links by deadline so reaping never scans
+	// the whole map. Entries for links that were since replaced or deleted
+	// are skipped lazily.
+	expiries expiryHeap
+	// Secondary indexes serving QueryLinks.
+	byCreated orderedIndex
+	byClicks  orderedIndex
+	byDomain  setIndex
+	byTag     setIndex
+	byOwner   map[string]*ownerIndex
+	// retention is how long expired links are kept before reaping.
+	retention time.Duration
 }
 
+var _ Repository = (*Store)(nil)
+
+// NewStore returns an empty store that keeps expired links for
+// DefaultExpiredRetention.
 func NewStore() *Store {
+	return NewStoreWithRetention(DefaultExpiredRetention)
+}
+
+// NewStoreWithRetention returns an empty store that keeps expired links for
+// retention before they may be reaped.
+func NewStoreWithRetention(retention time.Duration) *Store {
 	return &Store{
-		URLToShort: make(map[string]string),
-		ShortToURL: make(map[string]string),
-		DomainHits: make(map[string]int),
+		urlToShort: make(map[string]string),
+		shortToURL: make(map[string]Link),
+		domainHits: make(map[string]int),
+		stats:      make(map[string]*LinkStats),
+		byDomain:   make(setIndex),
+		byTag:      make(setIndex),
+		byOwner:    make(map[string]*ownerIndex),
+		retention:  retention,
+	}
+}
+
+func (s *Store) Save(link Link) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.replace(link)
+	return nil
+}
+
+// replace writes link over any mapping with the same short code, dropping
+// the old reverse index entry. Callers hold s.mu.
+func (s *Store) replace(link Link) {
+	if prev, exists := s.shortToURL[link.Short]; exists {
+		if s.urlToShort[prev.DedupKey()] == prev.Short {
+			delete(s.urlToShort, prev.DedupKey())
+		}
+		s.unindex(prev)
+	}
+	s.put(link)
+}
+
+// put writes link and its reverse index entry. A destination already
+// claimed by another link keeps pointing there, so editing or restoring a
+// link into a duplicate never moves deduplication away from the code it
+// has been returning. Callers hold s.mu.
+func (s *Store) put(link Link) {
+	s.shortToURL[link.Short] = link
+	if link.Indexed() {
+		if _, claimed := s.urlToShort[link.DedupKey()]; !claimed {
+			s.urlToShort[link.DedupKey()] = link.Short
+		}
+	}
+	s.index(link)
+	if at := link.reapAt(s.retention); !at.IsZero() {
+		heap.Push(&s.expiries, expiryEntry{at: at, short: link.Short})
+	}
+}
+
+func (s *Store) Update(link Link) (Link, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next, err := s.nextVersion(link)
+	if err != nil {
+		return Link{}, err
+	}
+	s.replace(next)
+	return next, nil
+}
+
+// versioned is nextVersion for callers that do not hold s.mu.
+func (s *Store) versioned(link Link) (Link, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.nextVersion(link)
+}
+
+// nextVersion checks link against the stored version and returns it ready
+// to be written. Callers hold s.mu.
+func (s *Store) nextVersion(link Link) (Link, error) {
+	current, exists := s.shortToURL[link.Short]
+	if !exists {
+		return Link{}, ErrNotFound
+	}
+	if current.Version != link.Version {
+		return Link{}, ErrVersionConflict
+	}
+	link.Version++
+	return link, nil
+}
+
+func (s *Store) Insert(link Link) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.shortToURL[link.Short]; exists {
+		return ErrCodeTaken
 	}
+	s.put(link)
+	return nil
+}
+
+func (s *Store) InsertBatch(links []Link) []error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	errs := make([]error, len(links))
+	for i, link := range links {
+		if _, exists := s.shortToURL[link.Short]; exists {
+			errs[i] = ErrCodeTaken
+			continue
+		}
+		s.put(link)
+	}
+	return errs
+}
+
+func (s *Store) GetByShort(short string) (Link, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	link, exists := s.shortToURL[short]
+	return link, exists
+}
+
+func (s *Store) GetByOriginal(original string) (Link, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	short, exists := s.urlToShort[original]
+	if !exists {
+		return Link{}, false
+	}
+	link, exists := s.shortToURL[short]
+	return link, exists
+}
+
+func (s *Store) LookupBatch(shorts, originals []string) (byShort, byOriginal map[string]Link) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	byShort = make(map[string]Link, len(shorts))
+	for _, short := range shorts {
+		if link, exists := s.shortToURL[short]; exists {
+			byShort[short] = link
+		}
+	}
+	byOriginal = make(map[string]Link, len(originals))
+	for _, original := range originals {
+		short, exists := s.urlToShort[original]
+		if !exists {
+			continue
+		}
+		if link, exists := s.shortToURL[short]; exists {
+			byOriginal[original] = link
+		}
+	}
+	return byShort, byOriginal
+}
+
+func (s *Store) IncrementDomain(domain string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.domainHits[domain]++
+	return s.domainHits[domain], nil
+}
+
+func (s *Store) IncrementDomains(counts map[string]int) (map[string]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	totals := make(map[string]int, len(counts))
+	for domain, n := range counts {
+		s.domainHits[domain] += n
+		totals[domain] = s.domainHits[domain]
+	}
+	return totals, nil
+}
+
+func (s *Store) DomainHits() map[string]int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string]int, len(s.domainHits))
+	for k, v := range s.domainHits {
+		result[k] = v
+	}
+	return result
+}
+
+func (s *Store) List() []Link {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	links := make([]Link, 0, len(s.shortToURL))
+	for _, link := range s.shortToURL {
+		links = append(links, link)
+	}
+	return links
+}
+
+// indexedFirst returns every link, those the reverse index points at ahead
+// of the rest, so saving them in order rebuilds the same index.
+func (s *Store) indexedFirst() []Link {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	links := make([]Link, 0, len(s.shortToURL))
+	for _, short := range s.urlToShort {
+		links = append(links, s.shortToURL[short])
+	}
+	for _, link := range s.shortToURL {
+		if !link.Indexed() || s.urlToShort[link.DedupKey()] != link.Short {
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.shortToURL)
+}
+
+func (s *Store) Delete(short string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.remove(short), nil
+}
+
+// remove deletes short and its reverse index entry. Callers hold s.mu.
+func (s *Store) remove(short string) bool {
+	link, exists := s.shortToURL[short]
+	if !exists {
+		return false
+	}
+	s.unindex(link)
+	delete(s.shortToURL, short)
+	delete(s.stats, short)
+	if s.urlToShort[link.DedupKey()] == short {
+		delete(s.urlToShort, link.DedupKey())
+	}
+	return true
+}
+
+func (s *Store) DeleteExpired(now time.Time, limit int) (int, error) {
+	return len(s.deleteExpired(now, limit)), nil
+}
+
+// deleteExpired removes up to limit expired links and returns their codes.
+func (s *Store) deleteExpired(now time.Time, limit int) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var removed []string
+	for len(removed) < limit && s.expiries.Len() > 0 && !now.Before(s.expiries[0].at) {
+		entry := heap.Pop(&s.expiries).(expiryEntry)
+		link, exists := s.shortToURL[entry.short]
+		if !exists || !link.reapAt(s.retention).Equal(entry.at) {
+			continue
+		}
+		s.remove(entry.short)
+		removed = append(removed, entry.short)
+	}
+	return removed
+}
+
+func (s *Store) RecordClicks(clicks []Click) error {
+	s.recordClicks(clicks)
+	return nil
+}
+
+// recordClicks applies clicks and returns the resulting absolute updates.
+func (s *Store) recordClicks(clicks []Click) []clickUpdate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	updates := make([]clickUpdate, 0, len(clicks))
+	for _, c := range clicks {
+		if _, exists := s.shortToURL[c.Short]; !exists {
+			continue
+		}
+		st := s.stats[c.Short]
+		if st == nil {
+			st = &LinkStats{}
+			s.stats[c.Short] = st
+		}
+		old := st.Clicks
+		updates = append(updates, st.add(c))
+		s.reindexClicks(c.Short, old)
+	}
+	return updates
+}
+
+// setStats applies a replayed click update.
+func (s *Store) setStats(u clickUpdate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.stats[u.Short]
+	if st == nil {
+		st = &LinkStats{}
+		s.stats[u.Short] = st
+	}
+	old := st.Clicks
+	st.set(u)
+	s.reindexClicks(u.Short, old)
+}
+
+// restoreStats installs snapshot statistics.
+func (s *Store) restoreStats(short string, st LinkStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old := s.clicks(short)
+	st = st.clone()
+	s.stats[short] = &st
+	s.reindexClicks(short, old)
+}
+
+// allStats returns a copy of every link's statistics.
+func (s *Store) allStats() map[string]LinkStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string]LinkStats, len(s.stats))
+	for short, st := range s.stats {
+		out[short] = st.clone()
+	}
+	return out
+}
+
+func (s *Store) Stats(short string) (LinkStats, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, exists := s.shortToURL[short]; !exists {
+		return LinkStats{}, false
+	}
+	st := s.stats[short]
+	if st == nil {
+		return LinkStats{}, true
+	}
+	return st.clone(), true
+}
+
+// domainCount returns the current counter for domain.
+func (s *Store) domainCount(domain string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.domainHits[domain]
+}
+
+// setDomain overwrites the counter for domain; used when replaying
+// persisted state.
+func (s *Store) setDomain(domain string, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.domainHits[domain] = count
+}
+
+type expiryEntry struct {
+	at    time.Time
+	short string
+}
+
+// expiryHeap is a min-heap of expiryEntry ordered by deadline.
+type expiryHeap []expiryEntry
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+func (h expiryHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *expiryHeap) Push(x any)        { *h = append(*h, x.(expiryEntry)) }
+func (h *expiryHeap) Pop() any {
+	old := *h
+	entry := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return entry
 }
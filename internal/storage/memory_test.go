@@ -1,8 +1,10 @@
 package storage
 
 import (
+	"fmt"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
@@ -23,43 +25,64 @@ func (suite *StoreTestSuite) SetupTest() {
 
 func (suite *StoreTestSuite) TestNewStoreInitialization() {
 	assert.NotNil(suite.T(), suite.Store, "Store should not be nil")
-	assert.NotNil(suite.T(), suite.Store.URLToShort, "URLToShort map should be initialized")
-	assert.NotNil(suite.T(), suite.Store.ShortToURL, "ShortToURL map should be initialized")
-	assert.NotNil(suite.T(), suite.Store.DomainHits, "DomainHits map should be initialized")
-	assert.Empty(suite.T(), suite.Store.URLToShort, "URLToShort map should be empty")
-	assert.Empty(suite.T(), suite.Store.ShortToURL, "ShortToURL map should be empty")
-	assert.Empty(suite.T(), suite.Store.DomainHits, "DomainHits map should be empty")
+	assert.Empty(suite.T(), suite.Store.List(), "Store should have no links")
+	assert.Empty(suite.T(), suite.Store.DomainHits(), "DomainHits should be empty")
 }
 
-func (suite *StoreTestSuite) TestConcurrentReadWriteURLToShort() {
-	const numGoroutines = 100
-	var wg sync.WaitGroup
-	wg.Add(numGoroutines)
+func (suite *StoreTestSuite) TestSaveAndLookup() {
+	link := Link{Short: "abc123", Original: "https://example.com"}
 
-	for i := 0; i < numGoroutines; i++ {
-		go func(i int) {
-			defer wg.Done()
-			original := "https://example.com/" + string(rune(i))
-			short := "short" + string(rune(i))
+	err := suite.Store.Save(link)
 
-			suite.Store.Mutex.Lock()
-			suite.Store.URLToShort[original] = short
-			suite.Store.Mutex.Unlock()
+	assert.NoError(suite.T(), err)
+	byShort, ok := suite.Store.GetByShort("abc123")
+	assert.True(suite.T(), ok, "Link should be found by short code")
+	assert.Equal(suite.T(), link, byShort)
+	byOriginal, ok := suite.Store.GetByOriginal("https://example.com")
+	assert.True(suite.T(), ok, "Link should be found by original URL")
+	assert.Equal(suite.T(), link, byOriginal)
+}
 
-			suite.Store.Mutex.RLock()
-			result, exists := suite.Store.URLToShort[original]
-			suite.Store.Mutex.RUnlock()
+func (suite *StoreTestSuite) TestSaveReplacesReverseIndex() {
+	assert.NoError(suite.T(), suite.Store.Save(Link{Short: "abc123", Original: "https://old.com"}))
+	assert.NoError(suite.T(), suite.Store.Save(Link{Short: "abc123", Original: "https://new.com"}))
 
-			assert.True(suite.T(), exists, "URL should exist in URLToShort")
-			assert.Equal(suite.T(), short, result, "Short URL should match")
-		}(i)
-	}
+	_, ok := suite.Store.GetByOriginal("https://old.com")
+	assert.False(suite.T(), ok, "Old destination should no longer resolve")
+	link, ok := suite.Store.GetByOriginal("https://new.com")
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), "abc123", link.Short)
+}
 
-	wg.Wait()
-	assert.Len(suite.T(), suite.Store.URLToShort, numGoroutines, "URLToShort should contain all entries")
+func (suite *StoreTestSuite) TestDelete() {
+	assert.NoError(suite.T(), suite.Store.Save(Link{Short: "abc123", Original: "https://example.com"}))
+	assert.Equal(suite.T(), 1, suite.Store.Len())
+
+	deleted, err := suite.Store.Delete("abc123")
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), deleted)
+	assert.Zero(suite.T(), suite.Store.Len())
+
+	_, ok := suite.Store.GetByShort("abc123")
+	assert.False(suite.T(), ok)
+	_, ok = suite.Store.GetByOriginal("https://example.com")
+	assert.False(suite.T(), ok)
+
+	deleted, err = suite.Store.Delete("abc123")
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), deleted, "Deleting a missing link should report false")
 }
 
-func (suite *StoreTestSuite) TestConcurrentReadWriteShortToURL() {
+func (suite *StoreTestSuite) TestDomainHitsReturnsCopy() {
+	_, _ = suite.Store.IncrementDomain("example.com")
+
+	hits := suite.Store.DomainHits()
+	hits["example.com"] = 100
+
+	assert.Equal(suite.T(), 1, suite.Store.DomainHits()["example.com"], "Mutating the copy should not affect the store")
+}
+
+func (suite *StoreTestSuite) TestConcurrentSaveAndGet() {
 	const numGoroutines = 100
 	var wg sync.WaitGroup
 	wg.Add(numGoroutines)
@@ -67,53 +90,45 @@ func (suite *StoreTestSuite) TestConcurrentReadWriteShortToURL() {
 	for i := 0; i < numGoroutines; i++ {
 		go func(i int) {
 			defer wg.Done()
-			original := "https://example.com/" + string(rune(i))
-			short := "short" + string(rune(i))
+			original := fmt.Sprintf("https://example.com/%d", i)
+			short := fmt.Sprintf("short%d", i)
 
-			suite.Store.Mutex.Lock()
-			suite.Store.ShortToURL[short] = original
-			suite.Store.Mutex.Unlock()
+			assert.NoError(suite.T(), suite.Store.Save(Link{Short: short, Original: original}))
 
-			suite.Store.Mutex.RLock()
-			result, exists := suite.Store.ShortToURL[short]
-			suite.Store.Mutex.RUnlock()
+			byOriginal, exists := suite.Store.GetByOriginal(original)
+			assert.True(suite.T(), exists, "URL should exist in reverse index")
+			assert.Equal(suite.T(), short, byOriginal.Short, "Short URL should match")
 
-			assert.True(suite.T(), exists, "Short URL should exist in ShortToURL")
-			assert.Equal(suite.T(), original, result, "Original URL should match")
+			byShort, exists := suite.Store.GetByShort(short)
+			assert.True(suite.T(), exists, "Short URL should exist")
+			assert.Equal(suite.T(), original, byShort.Original, "Original URL should match")
 		}(i)
 	}
 
 	wg.Wait()
-	assert.Len(suite.T(), suite.Store.ShortToURL, numGoroutines, "ShortToURL should contain all entries")
+	assert.Len(suite.T(), suite.Store.List(), numGoroutines, "Store should contain all entries")
 }
 
-func (suite *StoreTestSuite) TestConcurrentReadWriteDomainHits() {
+func (suite *StoreTestSuite) TestConcurrentIncrementDomain() {
 	const numGoroutines = 100
 	var wg sync.WaitGroup
 	wg.Add(numGoroutines)
 
 	for i := 0; i < numGoroutines; i++ {
-		go func(i int) {
+		go func() {
 			defer wg.Done()
-			domain := "example.com"
-
-			suite.Store.Mutex.Lock()
-			suite.Store.DomainHits[domain]++
-			suite.Store.Mutex.Unlock()
-
-			suite.Store.Mutex.RLock()
-			count := suite.Store.DomainHits[domain]
-			suite.Store.Mutex.RUnlock()
+			count, err := suite.Store.IncrementDomain("example.com")
 
+			assert.NoError(suite.T(), err)
 			assert.GreaterOrEqual(suite.T(), count, 1, "Domain hit count should be at least 1")
-		}(i)
+		}()
 	}
 
 	wg.Wait()
-	assert.Equal(suite.T(), numGoroutines, suite.Store.DomainHits["example.com"], "DomainHits should reflect all increments")
+	assert.Equal(suite.T(), numGoroutines, suite.Store.DomainHits()["example.com"], "DomainHits should reflect all increments")
 }
 
-func (suite *StoreTestSuite) TestConcurrentReadEmptyMaps() {
+func (suite *StoreTestSuite) TestConcurrentReadEmptyStore() {
 	const numGoroutines = 100
 	var wg sync.WaitGroup
 	wg.Add(numGoroutines)
@@ -121,17 +136,200 @@ func (suite *StoreTestSuite) TestConcurrentReadEmptyMaps() {
 	for i := 0; i < numGoroutines; i++ {
 		go func() {
 			defer wg.Done()
-			suite.Store.Mutex.RLock()
-			urlToShortLen := len(suite.Store.URLToShort)
-			shortToURLLen := len(suite.Store.ShortToURL)
-			domainHitsLen := len(suite.Store.DomainHits)
-			suite.Store.Mutex.RUnlock()
-
-			assert.Equal(suite.T(), 0, urlToShortLen, "URLToShort should remain empty")
-			assert.Equal(suite.T(), 0, shortToURLLen, "ShortToURL should remain empty")
-			assert.Equal(suite.T(), 0, domainHitsLen, "DomainHits should remain empty")
+			assert.Empty(suite.T(), suite.Store.List(), "Store should remain empty")
+			assert.Empty(suite.T(), suite.Store.DomainHits(), "DomainHits should remain empty")
 		}()
 	}
 
 	wg.Wait()
 }
+
+func (suite *StoreTestSuite) TestInsertRejectsTakenCode() {
+	assert.NoError(suite.T(), suite.Store.Insert(Link{Short: "abc123", Original: "https://example.com"}))
+
+	err := suite.Store.Insert(Link{Short: "abc123", Original: "https://other.com"})
+
+	assert.ErrorIs(suite.T(), err, ErrCodeTaken)
+	link, _ := suite.Store.GetByShort("abc123")
+	assert.Equal(suite.T(), "https://example.com", link.Original, "Existing mapping must be kept")
+	_, ok := suite.Store.GetByOriginal("https://other.com")
+	assert.False(suite.T(), ok)
+}
+
+func (suite *StoreTestSuite) TestCustomLinkSkipsReverseIndex() {
+	assert.NoError(suite.T(), suite.Store.Insert(Link{Short: "abc123", Original: "https://example.com"}))
+	assert.NoError(suite.T(), suite.Store.Insert(Link{Short: "sale", Original: "https://example.com", Custom: true}))
+
+	link, ok := suite.Store.GetByOriginal("https://example.com")
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), "abc123", link.Short, "Aliases should not replace the generated code in the reverse index")
+	alias, ok := suite.Store.GetByShort("sale")
+	assert.True(suite.T(), ok)
+	assert.True(suite.T(), alias.Custom)
+}
+
+func (suite *StoreTestSuite) TestRecordClicks() {
+	assert.NoError(suite.T(), suite.Store.Save(Link{Short: "abc123", Original: "https://example.com"}))
+	at := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	err := suite.Store.RecordClicks([]Click{
+		{Short: "abc123", At: at.Add(time.Minute), Referrer: "a.com"},
+		{Short: "abc123", At: at, Referrer: "a.com", UserAgent: "curl"},
+		{Short: "missing", At: at},
+	})
+
+	assert.NoError(suite.T(), err)
+	stats, ok := suite.Store.Stats("abc123")
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), int64(2), stats.Clicks)
+	assert.Equal(suite.T(), at, stats.FirstClick, "First click should be the earliest even when recorded out of order")
+	assert.Equal(suite.T(), at.Add(time.Minute), stats.LastClick)
+	assert.Equal(suite.T(), int64(2), stats.Referrers["a.com"])
+	assert.Equal(suite.T(), int64(1), stats.UserAgents[NoneValue])
+	_, ok = suite.Store.Stats("missing")
+	assert.False(suite.T(), ok, "Clicks on unknown codes should be ignored")
+}
+
+func (suite *StoreTestSuite) TestClickValuesAreBounded() {
+	assert.NoError(suite.T(), suite.Store.Save(Link{Short: "abc123", Original: "https://example.com"}))
+	var clicks []Click
+	for i := 0; i < maxTrackedValues+10; i++ {
+		clicks = append(clicks, Click{Short: "abc123", At: time.Now(), Referrer: fmt.Sprintf("ref%d.com", i), UserAgent: "ua"})
+	}
+
+	assert.NoError(suite.T(), suite.Store.RecordClicks(clicks))
+
+	stats, _ := suite.Store.Stats("abc123")
+	assert.Len(suite.T(), stats.Referrers, maxTrackedValues+1)
+	assert.Equal(suite.T(), int64(10), stats.Referrers[OtherValue])
+}
+
+func (suite *StoreTestSuite) TestDeleteDropsStats() {
+	assert.NoError(suite.T(), suite.Store.Save(Link{Short: "abc123", Original: "https://example.com"}))
+	assert.NoError(suite.T(), suite.Store.RecordClicks([]Click{{Short: "abc123", At: time.Now()}}))
+
+	_, err := suite.Store.Delete("abc123")
+	assert.NoError(suite.T(), err)
+	assert.NoError(suite.T(), suite.Store.Save(Link{Short: "abc123", Original: "https://other.com"}))
+
+	stats, _ := suite.Store.Stats("abc123")
+	assert.Zero(suite.T(), stats.Clicks, "A reused code should start with fresh statistics")
+}
+
+func (suite *StoreTestSuite) TestUpdateBumpsVersionAndReindexes() {
+	assert.NoError(suite.T(), suite.Store.Insert(Link{Short: "abc123", Original: "https://example.com"}))
+
+	updated, err := suite.Store.Update(Link{Short: "abc123", Original: "https://other.com"})
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(1), updated.Version)
+	_, ok := suite.Store.GetByOriginal("https://example.com")
+	assert.False(suite.T(), ok, "The old destination should leave the reverse index")
+	link, ok := suite.Store.GetByOriginal("https://other.com")
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), "abc123", link.Short)
+}
+
+func (suite *StoreTestSuite) TestUpdateKeepsClaimedReverseIndex() {
+	assert.NoError(suite.T(), suite.Store.Insert(Link{Short: "first", Original: "https://example.com"}))
+	assert.NoError(suite.T(), suite.Store.Insert(Link{Short: "second", Original: "https://example.com", Tags: []string{"promo"}}))
+
+	_, err := suite.Store.Update(Link{Short: "second", Original: "https://example.com"})
+
+	assert.NoError(suite.T(), err)
+	link, ok := suite.Store.GetByOriginal("https://example.com")
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), "first", link.Short, "An updated link should not take over another link's destination")
+
+	_, err = suite.Store.Delete("first")
+	assert.NoError(suite.T(), err)
+	_, ok = suite.Store.GetByOriginal("https://example.com")
+	assert.False(suite.T(), ok)
+}
+
+func (suite *StoreTestSuite) TestUpdateRejectsStaleVersion() {
+	assert.NoError(suite.T(), suite.Store.Insert(Link{Short: "abc123", Original: "https://example.com"}))
+	_, err := suite.Store.Update(Link{Short: "abc123", Original: "https://first.com"})
+	assert.NoError(suite.T(), err)
+
+	_, err = suite.Store.Update(Link{Short: "abc123", Original: "https://second.com"})
+	assert.ErrorIs(suite.T(), err, ErrVersionConflict)
+
+	_, err = suite.Store.Update(Link{Short: "missing", Original: "https://second.com"})
+	assert.ErrorIs(suite.T(), err, ErrNotFound)
+}
+
+func (suite *StoreTestSuite) TestSoftDeletedLinkIsPurged() {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	assert.NoError(suite.T(), suite.Store.Insert(Link{Short: "abc123", Original: "https://example.com"}))
+	_, err := suite.Store.Update(Link{Short: "abc123", Original: "https://example.com", DeletedAt: now, PurgeAt: now.Add(time.Hour)})
+	assert.NoError(suite.T(), err)
+	_, ok := suite.Store.GetByOriginal("https://example.com")
+	assert.False(suite.T(), ok, "Deleted links should leave the reverse index")
+
+	n, err := suite.Store.DeleteExpired(now.Add(time.Minute), 10)
+	assert.NoError(suite.T(), err)
+	assert.Zero(suite.T(), n, "Links inside the restore window should be kept")
+
+	n, err = suite.Store.DeleteExpired(now.Add(time.Hour), 10)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, n)
+	assert.Zero(suite.T(), suite.Store.Len())
+}
+
+func (suite *StoreTestSuite) TestRestoredLinkIsNotPurged() {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	assert.NoError(suite.T(), suite.Store.Insert(Link{Short: "abc123", Original: "https://example.com"}))
+	deleted, err := suite.Store.Update(Link{Short: "abc123", Original: "https://example.com", DeletedAt: now, PurgeAt: now.Add(time.Hour)})
+	assert.NoError(suite.T(), err)
+	deleted.DeletedAt, deleted.PurgeAt = time.Time{}, time.Time{}
+	_, err = suite.Store.Update(deleted)
+	assert.NoError(suite.T(), err)
+
+	n, err := suite.Store.DeleteExpired(now.Add(2*time.Hour), 10)
+	assert.NoError(suite.T(), err)
+	assert.Zero(suite.T(), n)
+	_, ok := suite.Store.GetByOriginal("https://example.com")
+	assert.True(suite.T(), ok, "Restoring should put the link back in the reverse index")
+}
+
+func (suite *StoreTestSuite) TestInsertBatch() {
+	assert.NoError(suite.T(), suite.Store.Insert(Link{Short: "taken", Original: "https://example.com"}))
+
+	errs := suite.Store.InsertBatch([]Link{
+		{Short: "a", Original: "https://a.com"},
+		{Short: "taken", Original: "https://b.com"},
+		{Short: "a", Original: "https://c.com"},
+		{Short: "d", Original: "https://d.com"},
+	})
+
+	assert.Equal(suite.T(), []error{nil, ErrCodeTaken, ErrCodeTaken, nil}, errs)
+	link, _ := suite.Store.GetByShort("a")
+	assert.Equal(suite.T(), "https://a.com", link.Original, "A code repeated within the batch should keep the first link")
+	assert.Equal(suite.T(), 3, suite.Store.Len())
+}
+
+func (suite *StoreTestSuite) TestLookupBatch() {
+	assert.NoError(suite.T(), suite.Store.Insert(Link{Short: "a", Original: "https://a.com"}))
+	assert.NoError(suite.T(), suite.Store.Insert(Link{Short: "home", Original: "https://b.com", Custom: true}))
+
+	byShort, byOriginal := suite.Store.LookupBatch(
+		[]string{"home", "missing"},
+		[]string{"https://a.com", "https://b.com"},
+	)
+
+	assert.Equal(suite.T(), map[string]Link{"home": {Short: "home", Original: "https://b.com", Custom: true}}, byShort)
+	assert.Equal(suite.T(), map[string]Link{"https://a.com": {Short: "a", Original: "https://a.com"}}, byOriginal,
+		"Aliases are not in the destination index")
+}
+
+func (suite *StoreTestSuite) TestIncrementDomains() {
+	_, err := suite.Store.IncrementDomain("a.com")
+	assert.NoError(suite.T(), err)
+
+	totals, err := suite.Store.IncrementDomains(map[string]int{"a.com": 2, "b.com": 1})
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), map[string]int{"a.com": 3, "b.com": 1}, totals)
+	assert.Equal(suite.T(), totals, suite.Store.DomainHits())
+}
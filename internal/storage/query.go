@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// SortOrder selects the order QueryLinks returns links in. A leading "-"
+// means descending.
+type SortOrder string
+
+const (
+	SortCreatedAsc  SortOrder = "created"
+	SortCreatedDesc SortOrder = "-created"
+	SortClicksAsc   SortOrder = "clicks"
+	SortClicksDesc  SortOrder = "-clicks"
+)
+
+// Valid reports whether o is one of the defined orders.
+func (o SortOrder) Valid() bool {
+	switch o {
+	case SortCreatedAsc, SortCreatedDesc, SortClicksAsc, SortClicksDesc:
+		return true
+	}
+	return false
+}
+
+func (o SortOrder) descending() bool { return strings.HasPrefix(string(o), "-") }
+func (o SortOrder) byClicks() bool   { return o == SortClicksAsc || o == SortClicksDesc }
+
+// Cursor is the position of the last link on a page: its sort key (creation
+// time in Unix nanoseconds or click count) and short code. The next page
+// starts strictly after it, so links added or reordered meanwhile never
+// cause a page to repeat the previous one.
+type Cursor struct {
+	Key   int64
+	Short string
+}
+
+// LinkQuery filters and orders a listing. Zero fields do not filter.
+type LinkQuery struct {
+	Domain string
+	Tag    string
+	Owner  string
+	// CreatedFrom and CreatedTo bound the creation time as [from, to).
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	// Search matches a case-insensitive substring of the destination.
+	Search         string
+	IncludeDeleted bool
+	// Sort defaults to SortCreatedAsc.
+	Sort  SortOrder
+	After *Cursor
+	// Limit caps the page size; zero or less means no cap.
+	Limit int
+}
+
+// LinkPage is one page of a listing. Next is nil on the last page.
+type LinkPage struct {
+	Links []Link
+	Next  *Cursor
+}
+
+func (q LinkQuery) matches(link Link) bool {
+	switch {
+	case link.Deleted() && !q.IncludeDeleted:
+		return false
+	case q.Domain != "" && link.Domain() != q.Domain:
+		return false
+	case q.Owner != "" && link.Owner != q.Owner:
+		return false
+	case q.Tag != "" && !slices.Contains(link.Tags, q.Tag):
+		return false
+	case !q.CreatedFrom.IsZero() && link.CreatedAt.Before(q.CreatedFrom):
+		return false
+	case !q.CreatedTo.IsZero() && !link.CreatedAt.Before(q.CreatedTo):
+		return false
+	case q.Search != "" && !strings.Contains(strings.ToLower(link.Original), strings.ToLower(q.Search)):
+		return false
+	}
+	return true
+}
+
+// QueryLinks answers q from the secondary indexes. The ordered index for
+// the sort key, the owner's own when q filters by owner, is walked from the
+// cursor, with creation ranges narrowed by binary search. When q filters by
+// a domain or tag holding fewer links, that set is read and sorted instead.
+// Substring search has no index and is applied as a filter.
+func (s *Store) QueryLinks(q LinkQuery) LinkPage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if q.Sort == "" {
+		q.Sort = SortCreatedAsc
+	}
+	index := s.ordered(q.Owner, q.Sort)
+	if set, filtered := s.smallestSet(q); filtered && len(set) < len(index.entries) {
+		candidates := &orderedIndex{entries: make([]indexEntry, 0, len(set))}
+		for short := range set {
+			candidates.entries = append(candidates.entries, s.entryFor(short, q.Sort))
+		}
+		slices.SortFunc(candidates.entries, compareEntries)
+		index = candidates
+	}
+
+	lo, hi := 0, len(index.entries)
+	if !q.Sort.byClicks() {
+		if !q.CreatedFrom.IsZero() {
+			lo = index.search(indexEntry{key: q.CreatedFrom.UnixNano()})
+		}
+		if !q.CreatedTo.IsZero() {
+			hi = index.search(indexEntry{key: q.CreatedTo.UnixNano()})
+		}
+	}
+	if c := q.After; c != nil {
+		if q.Sort.descending() {
+			hi = min(hi, index.search(indexEntry{key: c.Key, short: c.Short}))
+		} else {
+			// The NUL suffix sorts immediately after the cursor's code.
+			lo = max(lo, index.search(indexEntry{key: c.Key, short: c.Short + "\x00"}))
+		}
+	}
+
+	var page LinkPage
+	var last indexEntry
+	visit := func(e indexEntry) bool {
+		link := s.shortToURL[e.short]
+		if !q.matches(link) {
+			return true
+		}
+		if q.Limit > 0 && len(page.Links) == q.Limit {
+			page.Next = &Cursor{Key: last.key, Short: last.short}
+			return false
+		}
+		page.Links = append(page.Links, link)
+		last = e
+		return true
+	}
+	if q.Sort.descending() {
+		for i := hi - 1; i >= lo && visit(index.entries[i]); i-- {
+		}
+	} else {
+		for i := lo; i < hi && visit(index.entries[i]); i++ {
+		}
+	}
+	return page
+}
+
+// ordered returns the index listing owner's links, or every link when owner
+// is empty, in order. Callers hold s.mu.
+func (s *Store) ordered(owner string, order SortOrder) *orderedIndex {
+	created, clicks := &s.byCreated, &s.byClicks
+	if owner != "" {
+		x := s.byOwner[owner]
+		if x == nil {
+			x = &ownerIndex{}
+		}
+		created, clicks = &x.created, &x.clicks
+	}
+	if order.byClicks() {
+		return clicks
+	}
+	return created
+}
+
+// smallestSet returns the smallest set index selected by q, and false when
+// q filters by none of them. Callers hold s.mu.
+func (s *Store) smallestSet(q LinkQuery) (map[string]struct{}, bool) {
+	var best map[string]struct{}
+	filtered := false
+	for _, c := range []struct {
+		index setIndex
+		value string
+	}{{s.byDomain, q.Domain}, {s.byTag, q.Tag}} {
+		if c.value == "" {
+			continue
+		}
+		set := c.index[c.value]
+		if !filtered || len(set) < len(best) {
+			best = set
+		}
+		filtered = true
+	}
+	return best, filtered
+}
+
+// entryFor returns short's position key under order. Callers hold s.mu.
+func (s *Store) entryFor(short string, order SortOrder) indexEntry {
+	if order.byClicks() {
+		return indexEntry{key: s.clicks(short), short: short}
+	}
+	return indexEntry{key: s.shortToURL[short].CreatedAt.UnixNano(), short: short}
+}
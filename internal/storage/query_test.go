@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type QueryTestSuite struct {
+	suite.Suite
+	Store *Store
+	Start time.Time
+}
+
+func TestQueryTestSuite(t *testing.T) {
+	suite.Run(t, new(QueryTestSuite))
+}
+
+func (suite *QueryTestSuite) SetupTest() {
+	suite.Store = NewStore()
+	suite.Start = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+}
+
+// seed stores n links created a minute apart: even ones on a.com tagged
+// "even", odd ones on www.b.com owned by "bob".
+func (suite *QueryTestSuite) seed(n int) {
+	for i := 0; i < n; i++ {
+		link := Link{Short: fmt.Sprintf("l%02d", i), CreatedAt: suite.Start.Add(time.Duration(i) * time.Minute)}
+		if i%2 == 0 {
+			link.Original = fmt.Sprintf("https://a.com/page/%d", i)
+			link.Tags = []string{"even"}
+		} else {
+			link.Original = fmt.Sprintf("https://www.b.com/item/%d", i)
+			link.Owner = "bob"
+		}
+		require.NoError(suite.T(), suite.Store.Insert(link))
+	}
+}
+
+func shorts(page LinkPage) []string {
+	var out []string
+	for _, link := range page.Links {
+		out = append(out, link.Short)
+	}
+	return out
+}
+
+func (suite *QueryTestSuite) TestPaginatesInCreationOrder() {
+	suite.seed(5)
+
+	page := suite.Store.QueryLinks(LinkQuery{Limit: 2})
+	assert.Equal(suite.T(), []string{"l00", "l01"}, shorts(page))
+	require.NotNil(suite.T(), page.Next)
+
+	page = suite.Store.QueryLinks(LinkQuery{Limit: 2, After: page.Next})
+	assert.Equal(suite.T(), []string{"l02", "l03"}, shorts(page))
+
+	page = suite.Store.QueryLinks(LinkQuery{Limit: 2, After: page.Next})
+	assert.Equal(suite.T(), []string{"l04"}, shorts(page))
+	assert.Nil(suite.T(), page.Next, "The last page should not have a cursor")
+}
+
+func (suite *QueryTestSuite) TestNewestFirst() {
+	suite.seed(5)
+
+	page := suite.Store.QueryLinks(LinkQuery{Sort: SortCreatedDesc, Limit: 3})
+	assert.Equal(suite.T(), []string{"l04", "l03", "l02"}, shorts(page))
+
+	page = suite.Store.QueryLinks(LinkQuery{Sort: SortCreatedDesc, Limit: 3, After: page.Next})
+	assert.Equal(suite.T(), []string{"l01", "l00"}, shorts(page))
+}
+
+func (suite *QueryTestSuite) TestFilters() {
+	suite.seed(6)
+
+	assert.Equal(suite.T(), []string{"l01", "l03", "l05"}, shorts(suite.Store.QueryLinks(LinkQuery{Domain: "b.com"})))
+	assert.Equal(suite.T(), []string{"l00", "l02", "l04"}, shorts(suite.Store.QueryLinks(LinkQuery{Tag: "even"})))
+	assert.Equal(suite.T(), []string{"l01", "l03", "l05"}, shorts(suite.Store.QueryLinks(LinkQuery{Owner: "bob"})))
+	assert.Empty(suite.T(), shorts(suite.Store.QueryLinks(LinkQuery{Owner: "bob", Tag: "even"})))
+	assert.Empty(suite.T(), shorts(suite.Store.QueryLinks(LinkQuery{Domain: "c.com"})))
+	assert.Equal(suite.T(), []string{"l03"}, shorts(suite.Store.QueryLinks(LinkQuery{Search: "ITEM/3"})))
+	assert.Equal(suite.T(), []string{"l02", "l03"}, shorts(suite.Store.QueryLinks(LinkQuery{
+		CreatedFrom: suite.Start.Add(2 * time.Minute),
+		CreatedTo:   suite.Start.Add(4 * time.Minute),
+	})))
+	assert.Equal(suite.T(), []string{"l02"}, shorts(suite.Store.QueryLinks(LinkQuery{
+		Tag:         "even",
+		CreatedFrom: suite.Start.Add(time.Minute),
+		CreatedTo:   suite.Start.Add(4 * time.Minute),
+	})))
+}
+
+func (suite *QueryTestSuite) TestFilteredPagination() {
+	suite.seed(10)
+
+	page := suite.Store.QueryLinks(LinkQuery{Domain: "a.com", Sort: SortCreatedDesc, Limit: 2})
+	assert.Equal(suite.T(), []string{"l08", "l06"}, shorts(page))
+	page = suite.Store.QueryLinks(LinkQuery{Domain: "a.com", Sort: SortCreatedDesc, Limit: 2, After: page.Next})
+	assert.Equal(suite.T(), []string{"l04", "l02"}, shorts(page))
+}
+
+func (suite *QueryTestSuite) TestSortByClicks() {
+	suite.seed(4)
+	at := suite.Start.Add(time.Hour)
+	require.NoError(suite.T(), suite.Store.RecordClicks([]Click{
+		{Short: "l02", At: at}, {Short: "l02", At: at}, {Short: "l02", At: at},
+		{Short: "l00", At: at},
+		{Short: "l03", At: at}, {Short: "l03", At: at},
+	}))
+
+	page := suite.Store.QueryLinks(LinkQuery{Sort: SortClicksDesc, Limit: 2})
+	assert.Equal(suite.T(), []string{"l02", "l03"}, shorts(page))
+	page = suite.Store.QueryLinks(LinkQuery{Sort: SortClicksDesc, Limit: 2, After: page.Next})
+	assert.Equal(suite.T(), []string{"l00", "l01"}, shorts(page))
+
+	assert.Equal(suite.T(), []string{"l02", "l00"}, shorts(suite.Store.QueryLinks(LinkQuery{Tag: "even", Sort: SortClicksDesc})))
+	assert.Equal(suite.T(), []string{"l01", "l00", "l03", "l02"}, shorts(suite.Store.QueryLinks(LinkQuery{Sort: SortClicksAsc})))
+}
+
+func (suite *QueryTestSuite) TestIndexesFollowUpdatesAndDeletes() {
+	suite.seed(4)
+
+	link, _ := suite.Store.GetByShort("l00")
+	link.Original = "https://c.com/"
+	link.Tags = nil
+	_, err := suite.Store.Update(link)
+	require.NoError(suite.T(), err)
+	_, err = suite.Store.Delete("l01")
+	require.NoError(suite.T(), err)
+
+	assert.Equal(suite.T(), []string{"l00"}, shorts(suite.Store.QueryLinks(LinkQuery{Domain: "c.com"})))
+	assert.Equal(suite.T(), []string{"l02"}, shorts(suite.Store.QueryLinks(LinkQuery{Tag: "even"})))
+	assert.Equal(suite.T(), []string{"l03"}, shorts(suite.Store.QueryLinks(LinkQuery{Owner: "bob"})))
+	assert.Len(suite.T(), suite.Store.byCreated.entries, 3)
+	assert.Len(suite.T(), suite.Store.byClicks.entries, 3)
+	assert.Len(suite.T(), suite.Store.byOwner["bob"].created.entries, 1)
+	assert.Len(suite.T(), suite.Store.byOwner["bob"].clicks.entries, 1)
+}
+
+func (suite *QueryTestSuite) TestOwnerPagesWalkTheOwnersIndex() {
+	suite.seed(10)
+	require.NoError(suite.T(), suite.Store.RecordClicks([]Click{{Short: "l07", At: suite.Start}, {Short: "l07", At: suite.Start}, {Short: "l03", At: suite.Start}}))
+
+	page := suite.Store.QueryLinks(LinkQuery{Owner: "bob", Sort: SortCreatedDesc, Limit: 2})
+	assert.Equal(suite.T(), []string{"l09", "l07"}, shorts(page))
+	page = suite.Store.QueryLinks(LinkQuery{Owner: "bob", Sort: SortCreatedDesc, Limit: 2, After: page.Next})
+	assert.Equal(suite.T(), []string{"l05", "l03"}, shorts(page))
+
+	assert.Equal(suite.T(), []string{"l07", "l03", "l09", "l05", "l01"}, shorts(suite.Store.QueryLinks(LinkQuery{Owner: "bob", Sort: SortClicksDesc})))
+	assert.Empty(suite.T(), shorts(suite.Store.QueryLinks(LinkQuery{Owner: "alice"})))
+	assert.Len(suite.T(), suite.Store.byOwner["bob"].created.entries, 5, "Only bob's links are in his index")
+}
+
+func (suite *QueryTestSuite) TestDeletedLinksAreHidden() {
+	suite.seed(2)
+	link, _ := suite.Store.GetByShort("l00")
+	link.DeletedAt = suite.Start
+	_, err := suite.Store.Update(link)
+	require.NoError(suite.T(), err)
+
+	assert.Equal(suite.T(), []string{"l01"}, shorts(suite.Store.QueryLinks(LinkQuery{})))
+	assert.Equal(suite.T(), []string{"l00", "l01"}, shorts(suite.Store.QueryLinks(LinkQuery{IncludeDeleted: true})))
+}
@@ -0,0 +1,123 @@
+package storage
+
+import "time"
+
+const (
+	// maxTrackedValues bounds how many distinct referrers or user agents are
+	// counted per link; the rest are folded into OtherValue.
+	maxTrackedValues = 50
+	// maxValueLength truncates very long referrers and user agents.
+	maxValueLength = 256
+	// OtherValue collects referrers and user agents beyond maxTrackedValues.
+	OtherValue = "(other)"
+	// NoneValue counts clicks that carried no referrer or user agent.
+	NoneValue = "(none)"
+)
+
+// Click is a single followed redirect.
+type Click struct {
+	Short     string
+	At        time.Time
+	Referrer  string
+	UserAgent string
+}
+
+// LinkStats aggregates the clicks on one short link.
+type LinkStats struct {
+	Clicks     int64            `json:"clicks"`
+	FirstClick time.Time        `json:"first_click,omitzero"`
+	LastClick  time.Time        `json:"last_click,omitzero"`
+	Referrers  map[string]int64 `json:"referrers,omitempty"`
+	UserAgents map[string]int64 `json:"user_agents,omitempty"`
+}
+
+func (st LinkStats) clone() LinkStats {
+	st.Referrers = cloneCounts(st.Referrers)
+	st.UserAgents = cloneCounts(st.UserAgents)
+	return st
+}
+
+func cloneCounts(m map[string]int64) map[string]int64 {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]int64, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+// clickUpdate is the absolute state a click leaves behind. Logging it
+// instead of the click itself keeps replay idempotent.
+type clickUpdate struct {
+	Short      string    `json:"short"`
+	Clicks     int64     `json:"clicks"`
+	FirstClick time.Time `json:"first_click"`
+	LastClick  time.Time `json:"last_click"`
+	Referrer   string    `json:"referrer"`
+	RefCount   int64     `json:"ref_count"`
+	UserAgent  string    `json:"user_agent"`
+	UACount    int64     `json:"ua_count"`
+}
+
+// add folds c into st and returns the resulting absolute update.
+func (st *LinkStats) add(c Click) clickUpdate {
+	st.Clicks++
+	if st.FirstClick.IsZero() || c.At.Before(st.FirstClick) {
+		st.FirstClick = c.At
+	}
+	if c.At.After(st.LastClick) {
+		st.LastClick = c.At
+	}
+	if st.Referrers == nil {
+		st.Referrers = make(map[string]int64)
+	}
+	if st.UserAgents == nil {
+		st.UserAgents = make(map[string]int64)
+	}
+	ref := bucket(st.Referrers, c.Referrer)
+	st.Referrers[ref]++
+	ua := bucket(st.UserAgents, c.UserAgent)
+	st.UserAgents[ua]++
+
+	return clickUpdate{
+		Short:      c.Short,
+		Clicks:     st.Clicks,
+		FirstClick: st.FirstClick,
+		LastClick:  st.LastClick,
+		Referrer:   ref,
+		RefCount:   st.Referrers[ref],
+		UserAgent:  ua,
+		UACount:    st.UserAgents[ua],
+	}
+}
+
+// set applies a logged update.
+func (st *LinkStats) set(u clickUpdate) {
+	st.Clicks = u.Clicks
+	st.FirstClick = u.FirstClick
+	st.LastClick = u.LastClick
+	if st.Referrers == nil {
+		st.Referrers = make(map[string]int64)
+	}
+	if st.UserAgents == nil {
+		st.UserAgents = make(map[string]int64)
+	}
+	st.Referrers[u.Referrer] = u.RefCount
+	st.UserAgents[u.UserAgent] = u.UACount
+}
+
+// bucket picks the key a value is counted under.
+func bucket(counts map[string]int64, value string) string {
+	if value == "" {
+		return NoneValue
+	}
+	if len(value) > maxValueLength {
+		value = value[:maxValueLength]
+	}
+	if _, tracked := counts[value]; tracked || len(counts) < maxTrackedValues {
+		return value
+	}
+	return OtherValue
+}
@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrCodeTaken is returned by Insert when the short code already maps to
+	// a destination.
+	ErrCodeTaken = errors.New("storage: short code already in use")
+	// ErrNotFound is returned by Update for short codes that are not stored.
+	ErrNotFound = errors.New("storage: short code not found")
+	// ErrVersionConflict is returned by Update when the stored link has
+	// changed since the caller read it.
+	ErrVersionConflict = errors.New("storage: link was modified concurrently")
+)
+
+// DefaultExpiredRetention is how long an expired link is kept before it may
+// be reaped, so its code keeps answering 410 Gone instead of 404 for a while
+// after it stops redirecting.
+const DefaultExpiredRetention = 30 * 24 * time.Hour
+
+// Link is a single short code to destination mapping as held by a Repository.
+type Link struct {
+	Short    string `json:"short"`
+	Original string `json:"original"`
+	// Canonical is the normalized destination used for deduplication. Empty
+	// means Original is already canonical.
+	Canonical string    `json:"canonical,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// Custom marks a user-chosen alias.
+	Custom bool `json:"custom,omitempty"`
+	// Generator names the kind of generator that made the code. Empty for
+	// aliases, codes from unknown generators and links stored before it
+	// was recorded.
+	Generator string `json:"generator,omitempty"`
+	// ExpiresAt is when the link stops redirecting. Zero means never.
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+	// Redirect is the HTTP status the link redirects with. Zero means the
+	// service default.
+	Redirect int `json:"redirect,omitzero"`
+	// Version counts updates to the link and guards Update against lost
+	// writes.
+	Version int64 `json:"version,omitzero"`
+	// DeletedAt marks a soft-deleted link. It stops redirecting but can be
+	// restored until PurgeAt, when it becomes eligible for reaping.
+	DeletedAt time.Time `json:"deleted_at,omitzero"`
+	PurgeAt   time.Time `json:"purge_at,omitzero"`
+	// Tags are free-form labels for finding links again.
+	Tags []string `json:"tags,omitempty"`
+	// Owner identifies who created the link. Empty means unowned.
+	Owner string `json:"owner,omitempty"`
+}
+
+// Expired reports whether the link has passed its expiry at now.
+func (l Link) Expired(now time.Time) bool {
+	return !l.ExpiresAt.IsZero() && !now.Before(l.ExpiresAt)
+}
+
+// Deleted reports whether the link has been soft-deleted.
+func (l Link) Deleted() bool {
+	return !l.DeletedAt.IsZero()
+}
+
+// Indexed reports whether the link takes part in the destination to code
+// index. Aliases and links with an expiry, redirect status or tags of their
+// own are left out so deduplication keeps returning the plain generated
+// code; deleted links are left out so it never returns a dead one.
+func (l Link) Indexed() bool {
+	return !l.Custom && l.ExpiresAt.IsZero() && l.Redirect == 0 && len(l.Tags) == 0 && !l.Deleted()
+}
+
+// Domain returns the lowercased host of the destination without a leading
+// "www.", or "" if it cannot be parsed.
+func (l Link) Domain() string {
+	u, err := url.Parse(l.Key())
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// reapAt is when the link may be removed for good: the earlier of its expiry
+// plus retention and its purge deadline, or zero for never.
+func (l Link) reapAt(retention time.Duration) time.Time {
+	expired := l.ExpiresAt
+	if !expired.IsZero() {
+		expired = expired.Add(retention)
+	}
+	switch {
+	case l.PurgeAt.IsZero():
+		return expired
+	case expired.IsZero() || l.PurgeAt.Before(expired):
+		return l.PurgeAt
+	}
+	return expired
+}
+
+// Key returns the destination the link is deduplicated by.
+func (l Link) Key() string {
+	if l.Canonical != "" {
+		return l.Canonical
+	}
+	return l.Original
+}
+
+// DedupKey is Key scoped to the link's owner, so owners never share codes.
+// It equals Key for unowned links.
+func (l Link) DedupKey() string {
+	if l.Owner == "" {
+		return l.Key()
+	}
+	return l.Owner + "\x00" + l.Key()
+}
+
+// Repository is the contract every storage backend implements. Callers must
+// not assume anything about how a backend lays out its data.
+type Repository interface {
+	// Save stores link, replacing any mapping that uses the same short code.
+	Save(link Link) error
+	// Insert stores link only if its short code is unused, otherwise it
+	// returns ErrCodeTaken and leaves the existing mapping untouched.
+	Insert(link Link) error
+	// InsertBatch inserts links in order under a single lock acquisition and
+	// returns one error per link: nil, ErrCodeTaken (including codes claimed
+	// earlier in the same batch) or a storage failure.
+	InsertBatch(links []Link) []error
+	// GetByShort returns the link stored under short.
+	GetByShort(short string) (Link, bool)
+	// GetByOriginal returns the indexed link whose DedupKey is original.
+	GetByOriginal(original string) (Link, bool)
+	// LookupBatch resolves short codes as GetByShort and originals as
+	// GetByOriginal under a single lock acquisition. The maps hold only
+	// what was found, keyed by the value looked up.
+	LookupBatch(shorts, originals []string) (byShort, byOriginal map[string]Link)
+	// IncrementDomain bumps the shorten counter for domain and returns the new value.
+	IncrementDomain(domain string) (int, error)
+	// IncrementDomains adds counts to the shorten counters in one step and
+	// returns the new values.
+	IncrementDomains(counts map[string]int) (map[string]int, error)
+	// DomainHits returns a copy of all domain counters.
+	DomainHits() map[string]int
+	// List returns every stored link.
+	List() []Link
+	// Len returns the number of stored links.
+	Len() int
+	// QueryLinks returns one page of the links matching q.
+	QueryLinks(q LinkQuery) LinkPage
+	// Update replaces the stored link with the same short code if its
+	// Version still equals link.Version, and returns the stored result with
+	// Version incremented. It fails with ErrNotFound or ErrVersionConflict.
+	Update(link Link) (Link, error)
+	// Delete removes the link stored under short and reports whether it existed.
+	Delete(short string) (bool, error)
+	// RecordClicks folds clicks into the per-link statistics. Clicks on
+	// unknown short codes are ignored.
+	RecordClicks(clicks []Click) error
+	// Stats returns the click statistics for short.
+	Stats(short string) (LinkStats, bool)
+	// DeleteExpired removes at most limit links that expired longer ago
+	// than the backend's retention, or whose purge deadline has passed, at now and
+	// returns how many were removed. Implementations hold any write lock only
+	// for the batch, so callers reap large backlogs in several calls.
+	DeleteExpired(now time.Time, limit int) (int, error)
+}
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// Write-ahead log framing: every record is a little-endian uint32 payload
+// length, a CRC32 (IEEE) of the payload, then the JSON payload itself. A
+// record that is cut short or fails its checksum marks the end of the valid
+// log; everything after it is discarded on replay.
+const (
+	walHeaderSize = 8
+	// walMaxRecord guards against allocating huge buffers when a corrupted
+	// length prefix is read. Larger records are refused when written, since
+	// replay would discard them along with everything after them.
+	walMaxRecord = 1 << 20
+)
+
+const (
+	opSave   = "save"
+	opDelete = "delete"
+	opDomain = "domain"
+	opClick  = "click"
+)
+
+var errTornRecord = errors.New("storage: torn or corrupt wal record")
+
+// ErrRecordTooLarge is returned for writes whose log record would exceed
+// the largest record replay accepts.
+var ErrRecordTooLarge = errors.New("storage: wal record too large")
+
+// walRecord is one logged mutation. Domain and click records carry absolute
+// counter values rather than deltas so replaying a record twice (after a
+// crash between snapshot and log truncation) is harmless.
+type walRecord struct {
+	Op     string       `json:"op"`
+	Link   *Link        `json:"link,omitempty"`
+	Short  string       `json:"short,omitempty"`
+	Domain string       `json:"domain,omitempty"`
+	Count  int          `json:"count,omitempty"`
+	Click  *clickUpdate `json:"click,omitempty"`
+}
+
+func encodeRecord(rec walRecord) ([]byte, error) {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) > walMaxRecord {
+		return nil, fmt.Errorf("%w: %d bytes exceeds %d", ErrRecordTooLarge, len(payload), walMaxRecord)
+	}
+	buf := make([]byte, walHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(payload))
+	copy(buf[walHeaderSize:], payload)
+	return buf, nil
+}
+
+// readRecords calls fn for every intact record in r and returns the byte
+// offset just past the last one. A torn tail is not an error: the caller is
+// expected to truncate the log to the returned offset.
+func readRecords(r io.Reader, fn func(walRecord) error) (int64, error) {
+	br := bufio.NewReader(r)
+	var offset int64
+	header := make([]byte, walHeaderSize)
+	for {
+		rec, n, err := readRecord(br, header)
+		if err == io.EOF || errors.Is(err, errTornRecord) {
+			return offset, nil
+		}
+		if err != nil {
+			return offset, err
+		}
+		if err := fn(rec); err != nil {
+			return offset, err
+		}
+		offset += int64(n)
+	}
+}
+
+func readRecord(br *bufio.Reader, header []byte) (walRecord, int, error) {
+	var rec walRecord
+	if _, err := io.ReadFull(br, header); err != nil {
+		if err == io.EOF {
+			return rec, 0, io.EOF
+		}
+		if err == io.ErrUnexpectedEOF {
+			return rec, 0, errTornRecord
+		}
+		return rec, 0, err
+	}
+	size := binary.LittleEndian.Uint32(header[0:4])
+	sum := binary.LittleEndian.Uint32(header[4:8])
+	if size > walMaxRecord {
+		return rec, 0, errTornRecord
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(br, payload); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return rec, 0, errTornRecord
+		}
+		return rec, 0, err
+	}
+	if crc32.ChecksumIEEE(payload) != sum {
+		return rec, 0, errTornRecord
+	}
+	if err := json.Unmarshal(payload, &rec); err != nil {
+		return rec, 0, fmt.Errorf("%w: %v", errTornRecord, err)
+	}
+	return rec, walHeaderSize + int(size), nil
+}
@@ -0,0 +1,98 @@
+// Package tenant describes the namespaces one instance serves. Each tenant
+// has its own short codes, domain statistics and link quota; see the
+// handler package for how requests are routed to one.
+package tenant
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// maxIDLength bounds tenant IDs, which appear in paths and directory names.
+const maxIDLength = 32
+
+// Tenant is one namespace.
+type Tenant struct {
+	ID string `json:"id"`
+	// BaseURL is the tenant's short domain, such as "https://go.acme.com".
+	// Requests for its host act in the tenant and returned short URLs are
+	// built on it. Empty means the tenant is only reachable by path prefix
+	// or API key, and its short URLs live under /t/{id}.
+	BaseURL string `json:"base_url,omitempty"`
+	// MaxLinks caps how many links the tenant may hold. Zero means no cap.
+	MaxLinks int `json:"max_links,omitempty"`
+}
+
+// Validate checks that the ID is usable in paths and file names and that
+// BaseURL is an absolute http(s) URL.
+func (t Tenant) Validate() error {
+	if t.ID == "" || len(t.ID) > maxIDLength {
+		return fmt.Errorf("tenant: id must be 1 to %d characters", maxIDLength)
+	}
+	for _, c := range t.ID {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+			return fmt.Errorf("tenant %q: id may only contain a-z, 0-9 and '-'", t.ID)
+		}
+	}
+	if t.BaseURL != "" {
+		u, err := url.Parse(t.BaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("tenant %q: base_url must be an absolute http or https URL", t.ID)
+		}
+	}
+	if t.MaxLinks < 0 {
+		return fmt.Errorf("tenant %q: max_links must not be negative", t.ID)
+	}
+	return nil
+}
+
+// Host returns the lowercased host of BaseURL, port included, or "".
+func (t Tenant) Host() string {
+	if t.BaseURL == "" {
+		return ""
+	}
+	u, err := url.Parse(t.BaseURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
+
+// file is the layout of a tenants file.
+type file struct {
+	Tenants []Tenant `json:"tenants"`
+}
+
+// Load reads and validates a JSON tenants file of the form
+// {"tenants": [{"id": "acme", "base_url": "https://go.acme.com"}]}.
+func Load(path string) ([]Tenant, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("tenant: %w", err)
+	}
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("tenant: decode %s: %w", path, err)
+	}
+	ids := make(map[string]bool, len(f.Tenants))
+	hosts := make(map[string]string, len(f.Tenants))
+	for _, t := range f.Tenants {
+		if err := t.Validate(); err != nil {
+			return nil, err
+		}
+		if ids[t.ID] {
+			return nil, fmt.Errorf("tenant %q: defined twice", t.ID)
+		}
+		ids[t.ID] = true
+		if host := t.Host(); host != "" {
+			if other, taken := hosts[host]; taken {
+				return nil, fmt.Errorf("tenant %q: host %s is already used by %q", t.ID, host, other)
+			}
+			hosts[host] = t.ID
+		}
+	}
+	return f.Tenants, nil
+}
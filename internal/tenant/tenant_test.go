@@ -0,0 +1,59 @@
+package tenant
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type TenantTestSuite struct {
+	suite.Suite
+	Dir string
+}
+
+func TestTenantTestSuite(t *testing.T) {
+	suite.Run(t, new(TenantTestSuite))
+}
+
+func (suite *TenantTestSuite) SetupTest() {
+	suite.Dir = suite.T().TempDir()
+}
+
+func (suite *TenantTestSuite) write(content string) string {
+	path := filepath.Join(suite.Dir, "tenants.json")
+	require.NoError(suite.T(), os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func (suite *TenantTestSuite) TestLoad() {
+	path := suite.write(`{"tenants":[{"id":"acme","base_url":"https://Go.Acme.com/","max_links":100},{"id":"beta"}]}`)
+
+	tenants, err := Load(path)
+
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), tenants, 2)
+	assert.Equal(suite.T(), "go.acme.com", tenants[0].Host())
+	assert.Equal(suite.T(), 100, tenants[0].MaxLinks)
+	assert.Equal(suite.T(), "https://Go.Acme.com/", tenants[0].BaseURL)
+	assert.Empty(suite.T(), tenants[1].Host())
+}
+
+func (suite *TenantTestSuite) TestLoadRejectsBadTenants() {
+	for _, content := range []string{
+		`{"tenants":[{"id":""}]}`,
+		`{"tenants":[{"id":"Acme"}]}`,
+		`{"tenants":[{"id":"../etc"}]}`,
+		`{"tenants":[{"id":"acme","base_url":"go.acme.com"}]}`,
+		`{"tenants":[{"id":"acme","max_links":-1}]}`,
+		`{"tenants":[{"id":"acme"},{"id":"acme"}]}`,
+		`{"tenants":[{"id":"a","base_url":"https://x.com"},{"id":"b","base_url":"http://X.com"}]}`,
+		`not json`,
+	} {
+		_, err := Load(suite.write(content))
+		assert.Error(suite.T(), err, content)
+	}
+}
@@ -1,9 +1,118 @@
 package model
 
+import "time"
+
 type URLRequest struct {
 	OriginalURL string `json:"original_url"`
+	Alias       string `json:"alias,omitempty"`
+	// ExpiresAt and TTLSeconds are mutually exclusive ways to make the link
+	// stop redirecting.
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	TTLSeconds int64      `json:"ttl_seconds,omitempty"`
+	// RedirectStatus pins the redirect to 301, 302, 307 or 308. Omitted
+	// means the server default.
+	RedirectStatus int `json:"redirect_status,omitempty"`
+	// Tags label the link for GET /links?tag=.
+	Tags []string `json:"tags,omitempty"`
 }
 
+// URLResponse describes a shortened URL. ShortURL is the clickable link;
+// Code is its last path segment.
 type URLResponse struct {
-	ShortURL string `json:"short_url"`
+	ShortURL    string `json:"short_url"`
+	Code        string `json:"code"`
+	OriginalURL string `json:"original_url"`
+}
+
+// BatchResult is the outcome of the request at Index in a batch: either a
+// short URL or the reason it was rejected.
+type BatchResult struct {
+	Index       int    `json:"index"`
+	ShortURL    string `json:"short_url,omitempty"`
+	Code        string `json:"code,omitempty"`
+	OriginalURL string `json:"original_url"`
+	Error       *Error `json:"error,omitempty"`
+}
+
+// ErrorResponse is the body of every failed request.
+type ErrorResponse struct {
+	Error Error `json:"error"`
+}
+
+// Error says why a request, or one item of a batch, failed. Code is stable
+// and meant for programs; Message is meant for people and may change.
+type Error struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// HealthResponse is the body of GET /healthz and GET /readyz. Status is
+// "ok" or "unavailable"; Checks maps each readiness check to "ok" or
+// "failed".
+type HealthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// LinkStatsResponse reports how often a short link has been followed.
+type LinkStatsResponse struct {
+	Short      string           `json:"short"`
+	Clicks     int64            `json:"clicks"`
+	FirstClick time.Time        `json:"first_click,omitzero"`
+	LastClick  time.Time        `json:"last_click,omitzero"`
+	Referrers  map[string]int64 `json:"referrers,omitempty"`
+	UserAgents map[string]int64 `json:"user_agents,omitempty"`
+}
+
+// LinkUpdateRequest retargets an existing short link.
+type LinkUpdateRequest struct {
+	OriginalURL string `json:"original_url"`
+	// Version, when set, must equal the link's current version or the
+	// update is rejected with 409 Conflict.
+	Version *int64 `json:"version,omitempty"`
+}
+
+// LinkResponse describes a stored short link.
+type LinkResponse struct {
+	Short          string    `json:"short"`
+	OriginalURL    string    `json:"original_url"`
+	CreatedAt      time.Time `json:"created_at"`
+	ExpiresAt      time.Time `json:"expires_at,omitzero"`
+	RedirectStatus int       `json:"redirect_status,omitempty"`
+	Tags           []string  `json:"tags,omitempty"`
+	Owner          string    `json:"owner,omitempty"`
+	Version        int64     `json:"version"`
+	// Clicks is only reported by listings.
+	Clicks *int64 `json:"clicks,omitempty"`
+	// DeletedAt and RestorableUntil are set on soft-deleted links.
+	DeletedAt       time.Time `json:"deleted_at,omitzero"`
+	RestorableUntil time.Time `json:"restorable_until,omitzero"`
+}
+
+// LinkListResponse is one page of GET /links. Pass NextCursor back as
+// ?cursor= to fetch the following page; it is omitted on the last one.
+type LinkListResponse struct {
+	Links      []LinkResponse `json:"links"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// APIKeyRequest creates an API key. Role is "user" (the default), "admin"
+// or "metrics". Tenant confines the key to one tenant.
+type APIKeyRequest struct {
+	Name   string `json:"name"`
+	Role   string `json:"role,omitempty"`
+	Tenant string `json:"tenant,omitempty"`
+}
+
+// APIKeyResponse describes an API key. Key is only set in the response that
+// created it.
+type APIKeyResponse struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	Tenant    string    `json:"tenant,omitempty"`
+	Key       string    `json:"key,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	RevokedAt time.Time `json:"revoked_at,omitzero"`
 }
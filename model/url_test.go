@@ -37,6 +37,17 @@ func (suite *ModelTestSuite) TestURLRequestUnmarshalJSON() {
 	assert.Equal(suite.T(), expected, req, "Unmarshalled URLRequest should match expected")
 }
 
+func (suite *ModelTestSuite) TestURLRequestWithAliasJSON() {
+	jsonStr := `{"original_url":"https://example.com","alias":"summer-sale"}`
+	expected := URLRequest{OriginalURL: "https://example.com", Alias: "summer-sale"}
+
+	var req URLRequest
+	err := json.Unmarshal([]byte(jsonStr), &req)
+
+	assert.NoError(suite.T(), err, "Unmarshalling a request with an alias should not error")
+	assert.Equal(suite.T(), expected, req, "Alias should be parsed")
+}
+
 func (suite *ModelTestSuite) TestURLRequestUnmarshalInvalidJSON() {
 	jsonStr := `{invalid json}`
 
@@ -59,8 +70,8 @@ func (suite *ModelTestSuite) TestURLRequestUnmarshalEmptyURL() {
 }
 
 func (suite *ModelTestSuite) TestURLResponseMarshalJSON() {
-	resp := URLResponse{ShortURL: "abc123"}
-	expectedJSON := `{"short_url":"abc123"}`
+	resp := URLResponse{ShortURL: "http://localhost:8080/abc123", Code: "abc123", OriginalURL: "https://example.com"}
+	expectedJSON := `{"short_url":"http://localhost:8080/abc123","code":"abc123","original_url":"https://example.com"}`
 
 	data, err := json.Marshal(resp)
 
@@ -99,3 +110,13 @@ func (suite *ModelTestSuite) TestURLResponseUnmarshalEmptyShortURL() {
 	assert.NoError(suite.T(), err, "Unmarshalling empty short URL should not error")
 	assert.Equal(suite.T(), expected, resp, "Unmarshalled URLResponse should have empty ShortURL")
 }
+
+func (suite *ModelTestSuite) TestErrorResponseMarshalJSON() {
+	resp := ErrorResponse{Error: Error{Code: "invalid_url", Message: "url is empty", RequestID: "req-1"}}
+	expectedJSON := `{"error":{"code":"invalid_url","message":"url is empty","request_id":"req-1"}}`
+
+	data, err := json.Marshal(resp)
+
+	assert.NoError(suite.T(), err, "Marshalling ErrorResponse should not error")
+	assert.JSONEq(suite.T(), expectedJSON, string(data), "Errors should be nested under \"error\"")
+}